@@ -0,0 +1,225 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+const (
+	DefaultSampleRate = beep.SampleRate(44100)
+	// DefaultBuffer is how much sound is handed to the output at a time, and where auto-tuning
+	// starts from.
+	// Bigger -> less CPU, slower response
+	// Lower -> more CPU, faster response
+	DefaultBuffer = 20 * time.Millisecond
+	// Buffer range.
+	MinBuffer = 5 * time.Millisecond
+	MaxBuffer = 200 * time.Millisecond
+)
+
+// SampleRates are the sample rates the jam can play at.
+var SampleRates = []beep.SampleRate{44100, 48000, 96000}
+
+type (
+	// Config is how the jam's sound is played.
+	Config struct {
+		// Where the sound goes: "speaker", "null" to throw it away, or "wav:<path>" to write it to
+		// a WAV file. Empty is the speaker.
+		Output string
+		// One of SampleRates, zero is DefaultSampleRate.
+		SampleRate beep.SampleRate
+		// Zero is DefaultBuffer.
+		Buffer time.Duration
+		// Grow the buffer when the sound can't keep up, and shrink it again when it can.
+		AutoTune bool
+	}
+
+	// Backend is where the mix ends up.
+	Backend interface {
+		// Play starts playing s, alongside anything already playing.
+		Play(s beep.Streamer)
+		SampleRate() beep.SampleRate
+		// Buffer returns how much sound is handed to the output at a time, which is how far
+		// behind the mix the output plays.
+		Buffer() time.Duration
+		Close() error
+	}
+
+	speakerBackend struct {
+		sr     beep.SampleRate
+		buffer time.Duration
+	}
+
+	// ClockedBackend pulls sound in real time, as a sound card would, without one.
+	clockedBackend struct {
+		sr     beep.SampleRate
+		buffer time.Duration
+		mu     sync.Mutex
+		mixer  beep.Mixer
+		// Where the sound is written, nil to throw it away.
+		wav  *WAVWriter
+		err  error
+		stop chan struct{}
+		done chan struct{}
+	}
+)
+
+// ParseSampleRate reads a sample rate like "48000" or "48k".
+func ParseSampleRate(s string) (beep.SampleRate, error) {
+	s = strings.TrimSuffix(strings.ToLower(s), "hz")
+	scale := 1.0
+	if strings.HasSuffix(s, "k") {
+		s, scale = strings.TrimSuffix(s, "k"), 1000
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("sample rate %q should be a number, ex: 48000", s)
+	}
+	sr := beep.SampleRate(f * scale)
+	for _, r := range SampleRates {
+		if sr == r {
+			return sr, nil
+		}
+	}
+	return 0, fmt.Errorf("sample rate %d should be one of %v", sr, SampleRates)
+}
+
+// ParseBuffer reads a buffer length like "20ms", or "auto" to tune it as the jam plays.
+func ParseBuffer(s string) (buffer time.Duration, auto bool, err error) {
+	if s == "auto" {
+		return DefaultBuffer, true, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false, fmt.Errorf("buffer %q should be a duration like 20ms, or auto", s)
+	}
+	if d < MinBuffer || d > MaxBuffer {
+		return 0, false, fmt.Errorf("buffer should be %v to %v", MinBuffer, MaxBuffer)
+	}
+	return d, false, nil
+}
+
+// Open starts the output c asks for.
+func Open(c Config) (Backend, error) {
+	if c.SampleRate == 0 {
+		c.SampleRate = DefaultSampleRate
+	}
+	if _, err := ParseSampleRate(strconv.Itoa(int(c.SampleRate))); err != nil {
+		return nil, err
+	}
+	if c.Buffer == 0 {
+		c.Buffer = DefaultBuffer
+	}
+
+	var b Backend
+	switch {
+	case c.Output == "" || c.Output == "speaker":
+		if err := speaker.Init(c.SampleRate, c.SampleRate.N(c.Buffer)); err != nil {
+			return nil, fmt.Errorf("speaker.Init: %w (no sound card? try --audio=null)", err)
+		}
+		b = &speakerBackend{sr: c.SampleRate, buffer: c.Buffer}
+	case c.Output == "null":
+		b = startClocked(c, nil)
+	case strings.HasPrefix(c.Output, "wav:"):
+		path := strings.TrimPrefix(c.Output, "wav:")
+		if path == "" {
+			return nil, fmt.Errorf("audio output %q needs a file path, ex: wav:jam.wav", c.Output)
+		}
+		w, err := CreateWAV(path, c.SampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("create WAV: %w", err)
+		}
+		b = startClocked(c, w)
+	default:
+		return nil, fmt.Errorf("unknown audio output %q, should be speaker, null or wav:<path>", c.Output)
+	}
+
+	if c.AutoTune {
+		return NewAutoTuned(b), nil
+	}
+	return b, nil
+}
+
+func (b *speakerBackend) Play(s beep.Streamer) {
+	speaker.Play(s)
+}
+
+func (b *speakerBackend) SampleRate() beep.SampleRate {
+	return b.sr
+}
+
+func (b *speakerBackend) Buffer() time.Duration {
+	return b.buffer
+}
+
+func (b *speakerBackend) Close() error {
+	speaker.Close()
+	return nil
+}
+
+func startClocked(c Config, w *WAVWriter) *clockedBackend {
+	b := &clockedBackend{
+		sr:     c.SampleRate,
+		buffer: c.Buffer,
+		wav:    w,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Run streams a buffer's worth of sound every buffer, until stopped.
+func (b *clockedBackend) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.buffer)
+	defer ticker.Stop()
+	samples := make([][2]float64, b.sr.N(b.buffer))
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		b.mu.Lock()
+		b.mixer.Stream(samples)
+		b.mu.Unlock()
+		if b.wav == nil || b.err != nil {
+			continue
+		}
+		b.err = b.wav.Write(samples)
+	}
+}
+
+func (b *clockedBackend) Play(s beep.Streamer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mixer.Add(s)
+}
+
+func (b *clockedBackend) SampleRate() beep.SampleRate {
+	return b.sr
+}
+
+func (b *clockedBackend) Buffer() time.Duration {
+	return b.buffer
+}
+
+// Close stops the clock and finishes the WAV file, if there is one.
+func (b *clockedBackend) Close() error {
+	close(b.stop)
+	<-b.done
+	if b.wav == nil {
+		return nil
+	}
+	if err := b.wav.Close(); err != nil {
+		return fmt.Errorf("close WAV: %w", err)
+	}
+	return b.err
+}
@@ -0,0 +1,122 @@
+package audio_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/stretchr/testify/require"
+)
+
+// DecodeWAV checks a WAV file's header and returns its samples. beep's decoder reads
+// 16-bit samples at half their level, so they're decoded here.
+func decodeWAV(t *testing.T, b []byte) (beep.Format, [][2]float64) {
+	t.Helper()
+	s, format, err := wav.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, 2, format.NumChannels)
+	require.Equal(t, 2, format.Precision)
+
+	data := b[44:]
+	require.Equal(t, s.Len()*format.Width(), len(data))
+	frames := make([][2]float64, 0, s.Len())
+	for len(data) > 0 {
+		frame, n := format.DecodeSigned(data)
+		frames = append(frames, frame)
+		data = data[n:]
+	}
+	return format, frames
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("null", func(t *testing.T) {
+		b, err := audio.Open(audio.Config{Output: "null"})
+		require.NoError(t, err)
+		require.Equal(t, audio.DefaultSampleRate, b.SampleRate())
+
+		// The null output still plays, so finished sounds are let go.
+		done := make(chan struct{})
+		b.Play(beep.Seq(beep.Take(100, constant(0.5)), beep.Callback(func() { close(done) })))
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("sound never played")
+		}
+		require.NoError(t, b.Close())
+	})
+
+	t.Run("wav", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jam.wav")
+		b, err := audio.Open(audio.Config{Output: "wav:" + path, SampleRate: 48000, Buffer: 10 * time.Millisecond})
+		require.NoError(t, err)
+		b.Play(constant(0.5))
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, b.Close())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		format, frames := decodeWAV(t, data)
+		require.Equal(t, beep.SampleRate(48000), format.SampleRate)
+		require.NotEmpty(t, frames)
+		require.InDelta(t, 0.5, frames[0][0], 0.001)
+	})
+
+	for _, bad := range []string{"wav:", "alsa", "wav"} {
+		_, err := audio.Open(audio.Config{Output: bad})
+		require.Error(t, err, bad)
+	}
+}
+
+// WriteSeeker is an in-memory file.
+type writeSeeker struct {
+	buf []byte
+	pos int
+}
+
+func (w *writeSeeker) Write(p []byte) (int, error) {
+	if end := w.pos + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	copy(w.buf[w.pos:], p)
+	w.pos += len(p)
+	return len(p), nil
+}
+
+func (w *writeSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 0:
+		w.pos = int(offset)
+	case 1:
+		w.pos += int(offset)
+	case 2:
+		w.pos = len(w.buf) + int(offset)
+	}
+	return int64(w.pos), nil
+}
+
+func TestWAVWriter(t *testing.T) {
+	var f writeSeeker
+	w, err := audio.NewWAVWriter(&f, sampleRate)
+	require.NoError(t, err)
+	in := [][2]float64{{0, 0}, {0.25, -0.25}, {1, -1}, {3, -3}}
+	require.NoError(t, w.Write(in[:2]))
+	require.NoError(t, w.Write(in[2:]))
+	require.Equal(t, 4, w.Frames())
+	require.NoError(t, w.Close())
+	require.EqualValues(t, len(f.buf), w.Size())
+
+	format, out := decodeWAV(t, f.buf)
+	require.Equal(t, sampleRate, format.SampleRate)
+	require.Len(t, out, 4)
+	// Samples out of range are clipped.
+	want := [][2]float64{{0, 0}, {0.25, -0.25}, {1, -1}, {1, -1}}
+	for i := range want {
+		require.InDelta(t, want[i][0], out[i][0], 1.0/32767)
+		require.InDelta(t, want[i][1], out[i][1], 1.0/32767)
+	}
+}
@@ -0,0 +1,185 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/config"
+)
+
+// Blocks of sound a capture holds while its file catches up, about 5s at the default buffer.
+const captureBacklog = 256
+
+type (
+	// SampleWriter is a file sound is written to as it's played, like a WAVWriter.
+	SampleWriter interface {
+		Write(samples [][2]float64) error
+		// Frames returns how many frames have been written.
+		Frames() int
+		// Size returns the file's size so far, in bytes.
+		Size() int64
+		Close() error
+	}
+
+	// Tap hands every block its streamer plays to the running capture, if there is one.
+	// With no capture running it costs a lock and a nil check.
+	Tap struct {
+		mu       sync.Mutex
+		streamer beep.Streamer
+		capture  *Capture
+	}
+
+	// Capture writes what a tap hears to a file. The file is written from its own goroutine,
+	// so a slow disk drops sound from the capture rather than from the output.
+	Capture struct {
+		tap    *Tap
+		w      SampleWriter
+		blocks chan [][2]float64
+		// Blocks written, to be used again.
+		free chan [][2]float64
+		// Frames heard and frames dropped, by the tap.
+		frames  atomic.Int64
+		dropped atomic.Int64
+		// File size, by the writer.
+		size atomic.Int64
+		err  error
+		stop sync.Once
+		done chan struct{}
+	}
+)
+
+// CapturePath returns a path for a new capture of the given Jam Session in the rmxtui config
+// directory. ext is "wav" or "flac".
+func CapturePath(roomID string, now time.Time, ext string) (string, error) {
+	return config.Path("recordings", fmt.Sprintf("%s-%s.%s", config.FileName(roomID), now.Format("20060102-150405"), ext))
+}
+
+// CreateRecording creates a WAV or FLAC file at path, going by its extension.
+func CreateRecording(path string, sr beep.SampleRate) (SampleWriter, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		return CreateWAV(path, sr)
+	case ".flac":
+		return CreateFLAC(path, sr)
+	}
+	return nil, fmt.Errorf("can't record to %q, should be a .wav or .flac file", path)
+}
+
+// NewTap returns a tap on the streamer.
+func NewTap(s beep.Streamer) *Tap {
+	return &Tap{streamer: s}
+}
+
+func (t *Tap) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = t.streamer.Stream(samples)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.capture != nil {
+		t.capture.push(samples[:n])
+	}
+	return n, ok
+}
+
+func (t *Tap) Err() error {
+	return t.streamer.Err()
+}
+
+// Start captures everything streamed from now on to w, until the capture is stopped. Only one
+// capture runs at a time; starting another stops the last.
+func (t *Tap) Start(w SampleWriter) *Capture {
+	c := &Capture{
+		tap:    t,
+		w:      w,
+		blocks: make(chan [][2]float64, captureBacklog),
+		free:   make(chan [][2]float64, captureBacklog),
+		done:   make(chan struct{}),
+	}
+	c.size.Store(w.Size())
+	go c.run()
+
+	t.mu.Lock()
+	last := t.capture
+	t.capture = c
+	t.mu.Unlock()
+	if last != nil {
+		last.Stop()
+	}
+	return c
+}
+
+// Push queues a copy of the samples for the file, without waiting for it.
+func (c *Capture) push(samples [][2]float64) {
+	var block [][2]float64
+	select {
+	case block = <-c.free:
+	default:
+	}
+	if cap(block) < len(samples) {
+		block = make([][2]float64, len(samples))
+	}
+	block = block[:len(samples)]
+	copy(block, samples)
+
+	select {
+	case c.blocks <- block:
+		c.frames.Add(int64(len(samples)))
+	default:
+		c.dropped.Add(int64(len(samples)))
+	}
+}
+
+// Run writes the blocks pushed until the capture stops. After an error the rest are dropped.
+func (c *Capture) run() {
+	defer close(c.done)
+	for block := range c.blocks {
+		if c.err == nil {
+			c.err = c.w.Write(block)
+			c.size.Store(c.w.Size())
+		}
+		select {
+		case c.free <- block:
+		default:
+		}
+	}
+}
+
+// Frames returns how many frames have been captured.
+func (c *Capture) Frames() int {
+	return int(c.frames.Load())
+}
+
+// Dropped returns how many frames were left out because the file couldn't keep up.
+func (c *Capture) Dropped() int {
+	return int(c.dropped.Load())
+}
+
+// Size returns the file's size so far, in bytes.
+func (c *Capture) Size() int64 {
+	return c.size.Load()
+}
+
+// Stop ends the capture, waiting for what's been captured to be written and the file closed.
+// It can be called more than once.
+func (c *Capture) Stop() error {
+	c.stop.Do(func() {
+		c.tap.mu.Lock()
+		if c.tap.capture == c {
+			c.tap.capture = nil
+		}
+		close(c.blocks)
+		c.tap.mu.Unlock()
+		<-c.done
+
+		err := c.w.Close()
+		if c.err == nil {
+			c.err = err
+		}
+		c.size.Store(c.w.Size())
+	})
+	return c.err
+}
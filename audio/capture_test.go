@@ -0,0 +1,376 @@
+package audio_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/config"
+	"github.com/stretchr/testify/require"
+)
+
+// BitReader reads a FLAC stream, most significant bit first.
+type bitReader struct {
+	b   []byte
+	pos int // In bits.
+}
+
+func (r *bitReader) bits(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit := r.b[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) signed(n int) int64 {
+	v := r.bits(n)
+	return int64(v<<(64-n)) >> (64 - n)
+}
+
+func (r *bitReader) unary() uint64 {
+	var n uint64
+	for r.bits(1) == 0 {
+		n++
+	}
+	return n
+}
+
+func crc(p []byte, width int, poly uint32) uint32 {
+	var c uint32
+	top := uint32(1) << (width - 1)
+	mask := top<<1 - 1
+	for _, b := range p {
+		c ^= uint32(b) << (width - 8)
+		for i := 0; i < 8; i++ {
+			if c&top != 0 {
+				c = (c<<1 ^ poly) & mask
+			} else {
+				c = c << 1 & mask
+			}
+		}
+	}
+	return c
+}
+
+// DecodeFLAC checks a FLAC file written by FLACWriter and returns its sample rate and samples.
+// It only decodes what FLACWriter writes: a STREAMINFO block, then fixed-size frames of 16-bit
+// stereo in verbatim or fixed predictor subframes.
+func decodeFLAC(t *testing.T, b []byte) (beep.SampleRate, [][2]int64) {
+	t.Helper()
+	require.Equal(t, "fLaC", string(b[:4]))
+	r := &bitReader{b: b, pos: 32}
+	require.EqualValues(t, 1, r.bits(1), "last metadata block")
+	require.EqualValues(t, 0, r.bits(7), "STREAMINFO")
+	require.EqualValues(t, 34, r.bits(24))
+	r.bits(16 + 16 + 24 + 24)
+	sr := beep.SampleRate(r.bits(20))
+	require.EqualValues(t, 1, r.bits(3), "2 channels")
+	require.EqualValues(t, 15, r.bits(5), "16 bits")
+	total := int(r.bits(36))
+	r.bits(128)
+
+	var frames [][2]int64
+	for number := uint64(0); r.pos/8 < len(b); number++ {
+		start := r.pos / 8
+		require.EqualValues(t, 0xfff8, r.bits(16), "frame sync")
+		require.EqualValues(t, 7, r.bits(4))
+		r.bits(4)
+		require.EqualValues(t, 1, r.bits(4), "independent channels")
+		require.EqualValues(t, 4, r.bits(3), "16 bits")
+		r.bits(1)
+		// Frame number, UTF-8 style.
+		first := r.bits(8)
+		extra := 0
+		for first&(0x80>>extra) != 0 {
+			extra++
+		}
+		if extra > 0 {
+			extra--
+		}
+		n := first & (0xff >> (extra + 1))
+		if extra == 0 {
+			n = first
+		}
+		for i := 0; i < extra; i++ {
+			n = n<<6 | r.bits(8)&0x3f
+		}
+		require.Equal(t, number, n, "frame number")
+		size := int(r.bits(16)) + 1
+		require.EqualValues(t, crc(b[start:r.pos/8], 8, 0x07), r.bits(8), "header CRC-8")
+
+		var channels [2][]int64
+		for c := range channels {
+			require.EqualValues(t, 0, r.bits(1))
+			kind := int(r.bits(6))
+			require.EqualValues(t, 0, r.bits(1), "no wasted bits")
+			s := make([]int64, 0, size)
+			switch {
+			case kind == 1:
+				for i := 0; i < size; i++ {
+					s = append(s, r.signed(16))
+				}
+			case kind&0x38 == 8:
+				order := kind & 7
+				for i := 0; i < order; i++ {
+					s = append(s, r.signed(16))
+				}
+				require.EqualValues(t, 0, r.bits(2), "4-bit Rice parameters")
+				partitions := int(r.bits(4))
+				for p := 0; p < 1<<partitions; p++ {
+					k := int(r.bits(4))
+					count := size >> partitions
+					if p == 0 {
+						count -= order
+					}
+					for i := 0; i < count; i++ {
+						u := r.unary()<<k | r.bits(k)
+						res := int64(u>>1) ^ -int64(u&1)
+						j := len(s)
+						var pred int64
+						switch order {
+						case 1:
+							pred = s[j-1]
+						case 2:
+							pred = 2*s[j-1] - s[j-2]
+						case 3:
+							pred = 3*s[j-1] - 3*s[j-2] + s[j-3]
+						case 4:
+							pred = 4*s[j-1] - 6*s[j-2] + 4*s[j-3] - s[j-4]
+						}
+						s = append(s, pred+res)
+					}
+				}
+			default:
+				t.Fatalf("unexpected subframe type %d", kind)
+			}
+			channels[c] = s
+		}
+		if r.pos%8 != 0 {
+			r.pos += 8 - r.pos%8
+		}
+		require.EqualValues(t, crc(b[start:r.pos/8], 16, 0x8005), r.bits(16), "frame CRC-16")
+		for i := range channels[0] {
+			frames = append(frames, [2]int64{channels[0][i], channels[1][i]})
+		}
+	}
+	require.Equal(t, total, len(frames))
+	return sr, frames
+}
+
+// Music is a stretch of sound that compresses like music does, with some noise and silence.
+func music(sr beep.SampleRate, n int) [][2]float64 {
+	samples := make([][2]float64, n)
+	seed := uint32(1)
+	for i := range samples {
+		if i > n*3/4 {
+			break // Silence at the end.
+		}
+		seed = seed*1664525 + 1013904223
+		noise := float64(seed>>16)/65536 - 0.5
+		x := float64(i) / float64(sr)
+		samples[i] = [2]float64{
+			0.5*math.Sin(2*math.Pi*440*x) + 0.01*noise,
+			0.4*math.Sin(2*math.Pi*660*x) + 0.3*math.Sin(2*math.Pi*110*x),
+		}
+	}
+	// A loud click in the middle, past full scale, to be clipped.
+	samples[n/2] = [2]float64{1.5, -1.5}
+	return samples
+}
+
+func TestFLACWriter(t *testing.T) {
+	for _, sr := range audio.SampleRates {
+		sr := sr
+		t.Run(fmt.Sprint(int(sr)), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jam.flac")
+			w, err := audio.CreateFLAC(path, sr)
+			require.NoError(t, err)
+
+			// Written in odd sized chunks, as a buffer at a time, ending part way through a block.
+			in := music(sr, 3*4096+1000)
+			for at := 0; at < len(in); at += 441 {
+				require.NoError(t, w.Write(in[at:min(at+441, len(in))]))
+			}
+			require.Equal(t, len(in), w.Frames())
+			require.NoError(t, w.Close())
+
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.EqualValues(t, len(b), w.Size())
+			// Compressed well below 16-bit PCM.
+			require.Less(t, len(b), len(in)*4*3/4)
+
+			gotRate, out := decodeFLAC(t, b)
+			require.Equal(t, sr, gotRate)
+			require.Len(t, out, len(in))
+			for i, s := range in {
+				for c := range s {
+					want := int64(math.Round(math.Max(-1, math.Min(1, s[c])) * math.MaxInt16))
+					if out[i][c] != want {
+						t.Fatalf("sample %d channel %d: got %d, want %d", i, c, out[i][c], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFLACWriterLong(t *testing.T) {
+	// Frame numbers past 127 take more than a byte.
+	path := filepath.Join(t.TempDir(), "long.flac")
+	w, err := audio.CreateFLAC(path, 44100)
+	require.NoError(t, err)
+	silence := make([][2]float64, 4096)
+	for i := 0; i < 130; i++ {
+		require.NoError(t, w.Write(silence))
+	}
+	require.NoError(t, w.Close())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, out := decodeFLAC(t, b)
+	require.Len(t, out, 130*4096)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestCreateRecording(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"jam.wav", "jam.FLAC"} {
+		w, err := audio.CreateRecording(filepath.Join(dir, name), 48000)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+	_, err := audio.CreateRecording(filepath.Join(dir, "jam.mp3"), 48000)
+	require.Error(t, err)
+}
+
+func TestCapturePath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir, err := config.Dir()
+	require.NoError(t, err)
+	at := time.Date(2023, 1, 31, 20, 15, 0, 0, time.UTC)
+
+	// A room ID from the server can't reach outside the recordings directory.
+	path, err := audio.CapturePath("../../escape", at, "wav")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "recordings", "escape-20230131-201500.wav"), path)
+}
+
+func TestTap(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	in := music(sr, 10000)
+	buf := make([][2]float64, 512)
+
+	t.Run("passes sound through, capturing only while started", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jam.wav")
+		tap := audio.NewTap(sliceStreamer(in))
+
+		// Heard before the capture starts.
+		n, ok := tap.Stream(buf)
+		require.True(t, ok)
+		require.Equal(t, in[:n], buf[:n])
+
+		w, err := audio.CreateRecording(path, sr)
+		require.NoError(t, err)
+		c := tap.Start(w)
+		captured := 0
+		for i := 0; i < 4; i++ {
+			n, _ := tap.Stream(buf)
+			captured += n
+		}
+		require.NoError(t, c.Stop())
+		require.NoError(t, c.Stop(), "stopping twice")
+		tap.Stream(buf)
+
+		require.Equal(t, captured, c.Frames())
+		require.Zero(t, c.Dropped())
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.EqualValues(t, len(b), c.Size())
+
+		_, frames := decodeWAV(t, b)
+		require.Len(t, frames, captured)
+		for i, f := range frames {
+			want := in[len(buf)+i]
+			for ch := range f {
+				require.InDelta(t, math.Max(-1, math.Min(1, want[ch])), f[ch], 1.0/(1<<14))
+			}
+		}
+	})
+
+	t.Run("starting again stops the last capture", func(t *testing.T) {
+		dir := t.TempDir()
+		tap := audio.NewTap(beep.Silence(-1))
+		first, err := audio.CreateRecording(filepath.Join(dir, "first.wav"), sr)
+		require.NoError(t, err)
+		c1 := tap.Start(first)
+		tap.Stream(buf)
+		second, err := audio.CreateRecording(filepath.Join(dir, "second.wav"), sr)
+		require.NoError(t, err)
+		c2 := tap.Start(second)
+		tap.Stream(buf)
+		tap.Stream(buf)
+		require.NoError(t, c2.Stop())
+
+		require.Equal(t, len(buf), c1.Frames())
+		require.Equal(t, 2*len(buf), c2.Frames())
+		require.NoError(t, c1.Stop())
+	})
+
+	t.Run("drops sound rather than wait for a slow file", func(t *testing.T) {
+		w := &slowWriter{release: make(chan struct{})}
+		tap := audio.NewTap(beep.Silence(-1))
+		c := tap.Start(w)
+		// Far more than the capture holds, none of which waits on the file.
+		for i := 0; i < 1000; i++ {
+			tap.Stream(buf)
+		}
+		require.Greater(t, c.Dropped(), 0)
+		require.Equal(t, 1000*len(buf), c.Frames()+c.Dropped())
+
+		close(w.release)
+		require.NoError(t, c.Stop())
+		require.Equal(t, c.Frames(), w.frames)
+	})
+}
+
+// SliceStreamer streams samples once.
+func sliceStreamer(samples [][2]float64) beep.Streamer {
+	return beep.StreamerFunc(func(out [][2]float64) (int, bool) {
+		if len(samples) == 0 {
+			return 0, false
+		}
+		n := copy(out, samples)
+		samples = samples[n:]
+		return n, true
+	})
+}
+
+// SlowWriter is a file that doesn't take anything until released.
+type slowWriter struct {
+	release chan struct{}
+	frames  int
+}
+
+func (w *slowWriter) Write(samples [][2]float64) error {
+	<-w.release
+	w.frames += len(samples)
+	return nil
+}
+
+func (w *slowWriter) Frames() int  { return w.frames }
+func (w *slowWriter) Size() int64  { return int64(w.frames) * 4 }
+func (w *slowWriter) Close() error { return nil }
@@ -0,0 +1,248 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/config"
+)
+
+const (
+	// EffectsFile is the name of the saved effects settings in the rmxtui config directory.
+	EffectsFile = "effects.json"
+	// Gain range of each EQ band, in decibels.
+	MaxEQ = 12.0
+	// Delay time range.
+	MinDelay = 50 * time.Millisecond
+	MaxDelay = time.Second
+	// Most feedback the delay takes, so its echoes always die away.
+	MaxFeedback = 0.9
+	// MaxSend is the most reverb or chorus a note gets, as a MIDI controller value.
+	MaxSend = 127
+	// Corner frequencies of the EQ bands.
+	lowFreq  = 200.0
+	midFreq  = 1000.0
+	highFreq = 4000.0
+)
+
+type (
+	// EffectsSettings are the local sound's effects, kept across sessions.
+	EffectsSettings struct {
+		// How much of the synth's reverb and chorus each note gets, 0 to MaxSend. These are
+		// applied by the synth as it renders notes, the rest by the Effects streamer.
+		Reverb int   `json:"reverb"`
+		Chorus int   `json:"chorus"`
+		EQ     EQ    `json:"eq"`
+		Delay  Delay `json:"delay"`
+	}
+
+	// EQ is a 3-band equalizer's gain per band, in decibels.
+	EQ struct {
+		Low  float64 `json:"low"`
+		Mid  float64 `json:"mid"`
+		High float64 `json:"high"`
+	}
+
+	// Delay echoes the mix.
+	Delay struct {
+		On   bool          `json:"on"`
+		Time time.Duration `json:"time"`
+		// How much of each echo is fed back into the next, 0 to MaxFeedback.
+		Feedback float64 `json:"feedback"`
+		// Level of the echoes against the dry mix, 0 to 1.
+		Mix float64 `json:"mix"`
+	}
+
+	// Effects is a beep.Streamer running the master bus through the EQ and delay.
+	Effects struct {
+		mu       sync.Mutex
+		streamer beep.Streamer
+		sr       beep.SampleRate
+		settings EffectsSettings
+		bands    [3]biquad
+		// Echoes waiting to be heard, long enough for the longest delay.
+		echoes [][2]float64
+		pos    int
+	}
+
+	// Biquad is a second order filter, with state for each channel.
+	biquad struct {
+		b0, b1, b2, a1, a2 float64
+		x1, x2, y1, y2     [2]float64
+	}
+)
+
+// DefaultEffects leaves the sound dry, with settings ready for when the delay is turned on.
+func DefaultEffects() EffectsSettings {
+	return EffectsSettings{
+		Delay: Delay{Time: 375 * time.Millisecond, Feedback: 0.35, Mix: 0.3},
+	}
+}
+
+// EffectsPath returns where the effects settings are saved.
+func EffectsPath() (string, error) {
+	return config.Path(EffectsFile)
+}
+
+// LoadEffects reads the settings saved at path. Nothing saved yet is the default settings, and
+// so are settings that can't be read, returned with the error.
+func LoadEffects(path string) (EffectsSettings, error) {
+	s := DefaultEffects()
+	if err := config.LoadJSON(path, &s); err != nil {
+		return DefaultEffects(), fmt.Errorf("load effects settings: %w", err)
+	}
+	return s.Clamp(), nil
+}
+
+// Save writes the settings to path, replacing any saved before.
+func (s EffectsSettings) Save(path string) error {
+	return config.SaveJSON(path, s)
+}
+
+// Clamp keeps every setting in range.
+func (s EffectsSettings) Clamp() EffectsSettings {
+	clampInt := func(v, lo, hi int) int {
+		if v < lo {
+			return lo
+		}
+		if v > hi {
+			return hi
+		}
+		return v
+	}
+	clamp := func(v, lo, hi float64) float64 {
+		return math.Max(lo, math.Min(hi, v))
+	}
+	s.Reverb = clampInt(s.Reverb, 0, MaxSend)
+	s.Chorus = clampInt(s.Chorus, 0, MaxSend)
+	s.EQ.Low = clamp(s.EQ.Low, -MaxEQ, MaxEQ)
+	s.EQ.Mid = clamp(s.EQ.Mid, -MaxEQ, MaxEQ)
+	s.EQ.High = clamp(s.EQ.High, -MaxEQ, MaxEQ)
+	if s.Delay.Time < MinDelay {
+		s.Delay.Time = MinDelay
+	}
+	if s.Delay.Time > MaxDelay {
+		s.Delay.Time = MaxDelay
+	}
+	s.Delay.Feedback = clamp(s.Delay.Feedback, 0, MaxFeedback)
+	s.Delay.Mix = clamp(s.Delay.Mix, 0, 1)
+	return s
+}
+
+// NewEffects returns the effects for a streamer played at sample rate sr.
+func NewEffects(s beep.Streamer, sr beep.SampleRate, settings EffectsSettings) *Effects {
+	e := &Effects{streamer: s, sr: sr, echoes: make([][2]float64, sr.N(MaxDelay))}
+	e.Set(settings)
+	return e
+}
+
+// Settings returns the effects' settings.
+func (e *Effects) Settings() EffectsSettings {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.settings
+}
+
+// Set changes the effects' settings.
+func (e *Effects) Set(s EffectsSettings) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s = s.Clamp()
+	if !s.Delay.On && e.settings.Delay.On {
+		// Turning the delay back on shouldn't bring back old echoes.
+		for i := range e.echoes {
+			e.echoes[i] = [2]float64{}
+		}
+	}
+	e.settings = s
+	rate := float64(e.sr)
+	e.bands[0].lowShelf(rate, lowFreq, s.EQ.Low)
+	e.bands[1].peak(rate, midFreq, s.EQ.Mid)
+	e.bands[2].highShelf(rate, highFreq, s.EQ.High)
+}
+
+func (e *Effects) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = e.streamer.Stream(samples)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	eq := e.settings.EQ != EQ{}
+	d := e.settings.Delay
+	delay := e.sr.N(d.Time)
+	for i := range samples[:n] {
+		s := samples[i]
+		if eq {
+			for b := range e.bands {
+				s = e.bands[b].process(s)
+			}
+		}
+		if d.On {
+			// The echo due now was written delay samples ago.
+			at := (e.pos - delay + len(e.echoes)) % len(e.echoes)
+			echo := e.echoes[at]
+			for c := range s {
+				e.echoes[e.pos][c] = s[c] + echo[c]*d.Feedback
+				s[c] += echo[c] * d.Mix
+			}
+			e.pos = (e.pos + 1) % len(e.echoes)
+		}
+		samples[i] = s
+	}
+	return n, ok
+}
+
+func (e *Effects) Err() error {
+	return e.streamer.Err()
+}
+
+// The filters below are from Robert Bristow-Johnson's Audio EQ Cookbook, with a slope or Q
+// that suits broad tone shaping.
+
+func (f *biquad) lowShelf(rate, freq, gain float64) {
+	a := math.Pow(10, gain/40)
+	w := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w) / math.Sqrt2
+	cos, sq := math.Cos(w), 2*math.Sqrt(a)*alpha
+	f.set(
+		a*((a+1)-(a-1)*cos+sq), 2*a*((a-1)-(a+1)*cos), a*((a+1)-(a-1)*cos-sq),
+		(a+1)+(a-1)*cos+sq, -2*((a-1)+(a+1)*cos), (a+1)+(a-1)*cos-sq,
+	)
+}
+
+func (f *biquad) highShelf(rate, freq, gain float64) {
+	a := math.Pow(10, gain/40)
+	w := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w) / math.Sqrt2
+	cos, sq := math.Cos(w), 2*math.Sqrt(a)*alpha
+	f.set(
+		a*((a+1)+(a-1)*cos+sq), -2*a*((a-1)+(a+1)*cos), a*((a+1)+(a-1)*cos-sq),
+		(a+1)-(a-1)*cos+sq, 2*((a-1)-(a+1)*cos), (a+1)-(a-1)*cos-sq,
+	)
+}
+
+func (f *biquad) peak(rate, freq, gain float64) {
+	a := math.Pow(10, gain/40)
+	w := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w) / (2 * 0.7)
+	cos := math.Cos(w)
+	f.set(1+alpha*a, -2*cos, 1-alpha*a, 1+alpha/a, -2*cos, 1-alpha/a)
+}
+
+// Set normalizes the coefficients by a0, keeping the filter's state.
+func (f *biquad) set(b0, b1, b2, a0, a1, a2 float64) {
+	f.b0, f.b1, f.b2 = b0/a0, b1/a0, b2/a0
+	f.a1, f.a2 = a1/a0, a2/a0
+}
+
+func (f *biquad) process(in [2]float64) [2]float64 {
+	var out [2]float64
+	for c := range in {
+		out[c] = f.b0*in[c] + f.b1*f.x1[c] + f.b2*f.x2[c] - f.a1*f.y1[c] - f.a2*f.y2[c]
+		f.x2[c], f.x1[c] = f.x1[c], in[c]
+		f.y2[c], f.y1[c] = f.y1[c], out[c]
+	}
+	return out
+}
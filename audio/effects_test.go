@@ -0,0 +1,102 @@
+package audio_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/stretchr/testify/require"
+)
+
+// RMS measures the left channel of n frames from s, after letting it settle.
+func rms(s beep.Streamer, n int) float64 {
+	drain(s, sampleRate.N(100*time.Millisecond))
+	buf := make([][2]float64, n)
+	s.Stream(buf)
+	var sum float64
+	for _, f := range buf {
+		sum += f[0] * f[0]
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+func TestEQ(t *testing.T) {
+	tests := []struct {
+		name string
+		eq   audio.EQ
+		freq float64
+		// Change in level, in decibels.
+		want float64
+	}{
+		{name: "flat", freq: 1000, want: 0},
+		{name: "bass boost", eq: audio.EQ{Low: 12}, freq: 40, want: 12},
+		{name: "bass cut", eq: audio.EQ{Low: -12}, freq: 40, want: -12},
+		{name: "bass cut leaves the treble", eq: audio.EQ{Low: -12}, freq: 10000, want: 0},
+		{name: "mid boost", eq: audio.EQ{Mid: 6}, freq: 1000, want: 6},
+		{name: "treble cut", eq: audio.EQ{High: -9}, freq: 16000, want: -9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := audio.DefaultEffects()
+			settings.EQ = tt.eq
+			e := audio.NewEffects(sine(tt.freq, 0.5), sampleRate, settings)
+			got := audio.Decibels(rms(e, 44100) / (0.5 / math.Sqrt2))
+			require.InDelta(t, tt.want, got, 0.5)
+		})
+	}
+}
+
+func TestDelay(t *testing.T) {
+	delay := 100 * time.Millisecond
+	n := sampleRate.N(delay)
+	in := make([][2]float64, 3*n+1)
+	in[0] = [2]float64{1, 1}
+
+	settings := audio.DefaultEffects()
+	settings.Delay = audio.Delay{On: true, Time: delay, Feedback: 0.5, Mix: 0.8}
+	e := audio.NewEffects(frames(in...), sampleRate, settings)
+	out := drain(e, len(in))
+
+	require.Equal(t, 1.0, out[0][0], "the dry sound is untouched")
+	require.Equal(t, 0.8, out[n][0])
+	require.Equal(t, 0.4, out[2*n][1], "each echo is fed back at half")
+	require.Equal(t, 0.2, out[3*n][0])
+	require.Zero(t, out[n/2][0])
+
+	// Turning the delay off and on again forgets the echoes.
+	settings.Delay.On = false
+	e.Set(settings)
+	settings.Delay.On = true
+	e.Set(settings)
+	require.Equal(t, settings.Delay, e.Settings().Delay)
+}
+
+func TestEffectsSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), audio.EffectsFile)
+	s, err := audio.LoadEffects(path)
+	require.NoError(t, err)
+	require.Equal(t, audio.DefaultEffects(), s)
+
+	s.Reverb = 200
+	s.EQ = audio.EQ{Low: 3, Mid: -30, High: 1.5}
+	s.Delay = audio.Delay{On: true, Time: 5 * time.Second, Feedback: 2, Mix: 0.5}
+	require.NoError(t, s.Save(path))
+
+	got, err := audio.LoadEffects(path)
+	require.NoError(t, err)
+	require.Equal(t, audio.EffectsSettings{
+		Reverb: audio.MaxSend,
+		EQ:     audio.EQ{Low: 3, Mid: -audio.MaxEQ, High: 1.5},
+		Delay:  audio.Delay{On: true, Time: audio.MaxDelay, Feedback: audio.MaxFeedback, Mix: 0.5},
+	}, got)
+
+	// Settings that can't be read are the defaults.
+	require.NoError(t, os.WriteFile(path, []byte("{\"reverb\": 4"), 0o600))
+	got, err = audio.LoadEffects(path)
+	require.Error(t, err)
+	require.Equal(t, audio.DefaultEffects(), got)
+}
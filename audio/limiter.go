@@ -0,0 +1,235 @@
+package audio
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	// DefaultCeiling is the loudest the limiter lets through, in dBFS.
+	DefaultCeiling = -1.0
+	// DefaultLookahead is how far ahead the limiter looks for peaks. The mix is delayed by as much.
+	DefaultLookahead = 5 * time.Millisecond
+	// DefaultRelease is how long the limiter takes to let go after a peak.
+	DefaultRelease = 150 * time.Millisecond
+)
+
+type (
+	NewLimiterOpts struct {
+		// Sound to limit, usually the mixer.
+		Streamer   beep.Streamer
+		SampleRate beep.SampleRate
+		// Loudest sample let through in dBFS, 0 is full scale. Zero uses DefaultCeiling.
+		Ceiling float64
+		// Zero uses DefaultLookahead and DefaultRelease.
+		Lookahead time.Duration
+		Release   time.Duration
+	}
+
+	// Limiter is a beep.Streamer keeping a loud mix under its ceiling without clipping.
+	//
+	// It turns the mix down slightly ahead of each peak, so the peak fits, then lets the
+	// volume back up over the release time. A soft clipper after it rounds off anything that
+	// still gets through, so every sample it streams is within [-1, 1].
+	Limiter struct {
+		mu       sync.Mutex
+		streamer beep.Streamer
+		sr       beep.SampleRate
+		ceiling  float64
+		release  float64
+		// Frames waiting their turn, so the gain can drop before a peak arrives.
+		delay [][2]float64
+		// Gain each frame needs to fit under the ceiling, over the lookahead.
+		needs minWindow
+		// Gain after the release, and the last lookahead's worth of it to average.
+		env     float64
+		gains   []float64
+		gainSum float64
+		// Next slot in the delay and gains rings.
+		pos int
+		// Frames of silence left to push through once the streamer is drained.
+		drain int
+		// Most gain reduction since Reduction was called.
+		reduction float64
+	}
+
+	// MinWindow keeps the smallest of the last n values pushed.
+	minWindow struct {
+		n      int
+		pushed int
+		// Indexes and values that could still be the smallest, oldest first, in a ring.
+		idx  []int
+		val  []float64
+		head int
+		len  int
+	}
+)
+
+// NewLimiter returns a limiter for the streamer.
+func NewLimiter(o NewLimiterOpts) *Limiter {
+	if o.Ceiling == 0 {
+		o.Ceiling = DefaultCeiling
+	}
+	if o.Lookahead == 0 {
+		o.Lookahead = DefaultLookahead
+	}
+	if o.Release == 0 {
+		o.Release = DefaultRelease
+	}
+	n := o.SampleRate.N(o.Lookahead)
+	if n < 1 {
+		n = 1
+	}
+	l := &Limiter{
+		streamer: o.Streamer,
+		sr:       o.SampleRate,
+		ceiling:  math.Min(1, math.Pow(10, o.Ceiling/20)),
+		// Recover about two thirds of the way back to unity each release.
+		release: 1 - math.Exp(-1/math.Max(1, float64(o.SampleRate.N(o.Release)))),
+		delay:   make([][2]float64, n),
+		// One more than the delay, so the frame coming out is in every window averaged.
+		needs:   minWindow{n: n + 1, idx: make([]int, n+1), val: make([]float64, n+1)},
+		env:     1,
+		gains:   make([]float64, n),
+		gainSum: float64(n),
+	}
+	for i := range l.gains {
+		l.gains[i] = 1
+	}
+	return l
+}
+
+// Stream limits the streamer's samples. Once it's drained, the frames still in the lookahead
+// are played out before Stream reports it's done.
+func (l *Limiter) Stream(samples [][2]float64) (n int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n = len(samples)
+	if l.drain == 0 {
+		var read int
+		read, ok = l.streamer.Stream(samples)
+		if !ok {
+			read = 0
+			l.drain = len(l.delay)
+		}
+		for i := read; i < len(samples); i++ {
+			samples[i] = [2]float64{}
+		}
+	}
+	if l.drain > 0 {
+		if n > l.drain {
+			n = l.drain
+		}
+		l.drain -= n
+		if l.drain == 0 {
+			// Nothing left to play, stream nothing next time.
+			l.drain = -1
+		}
+	} else if l.drain < 0 {
+		return 0, false
+	}
+
+	for i := range samples[:n] {
+		samples[i] = l.process(samples[i])
+	}
+	return n, true
+}
+
+func (l *Limiter) Err() error {
+	return l.streamer.Err()
+}
+
+// Latency returns how far the limiter delays the mix to look ahead.
+func (l *Limiter) Latency() time.Duration {
+	return l.sr.D(len(l.delay))
+}
+
+// Reduction returns the most the limiter turned the mix down, in decibels, since it was last
+// called. 0 means it didn't have to.
+func (l *Limiter) Reduction() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := l.reduction
+	l.reduction = 0
+	return r
+}
+
+// Process takes a frame in and returns the frame a lookahead ago, turned down to fit.
+func (l *Limiter) process(in [2]float64) [2]float64 {
+	for c := range in {
+		// A broken streamer's NaNs and infinities would poison the gain for good.
+		if math.IsNaN(in[c]) || math.IsInf(in[c], 0) {
+			in[c] = 0
+		}
+	}
+	need := 1.0
+	if peak := math.Max(math.Abs(in[0]), math.Abs(in[1])); peak > l.ceiling {
+		need = l.ceiling / peak
+	}
+
+	// The lowest gain any frame in the lookahead needs, released slowly once they've gone by.
+	l.env = math.Min(l.needs.push(need), l.env+(1-l.env)*l.release)
+	// Averaging over the lookahead smooths the drop without letting any peak through: every
+	// gain averaged saw the frame coming out now.
+	l.gainSum += l.env - l.gains[l.pos]
+	l.gains[l.pos] = l.env
+	gain := l.gainSum / float64(len(l.gains))
+
+	out := l.delay[l.pos]
+	l.delay[l.pos] = in
+	l.pos = (l.pos + 1) % len(l.delay)
+	if l.pos == 0 {
+		// Running sums drift, start afresh once around the ring.
+		l.gainSum = 0
+		for _, g := range l.gains {
+			l.gainSum += g
+		}
+	}
+
+	if db := -Decibels(gain); db > l.reduction {
+		l.reduction = db
+	}
+	return [2]float64{SoftClip(out[0] * gain), SoftClip(out[1] * gain)}
+}
+
+// Push adds a value and returns the smallest of the last n.
+func (w *minWindow) push(v float64) float64 {
+	// Larger values pushed earlier can never be the smallest again.
+	for w.len > 0 && w.val[(w.head+w.len-1)%w.n] >= v {
+		w.len--
+	}
+	// Neither can values older than the window.
+	if w.len > 0 && w.idx[w.head] <= w.pushed-w.n {
+		w.head = (w.head + 1) % w.n
+		w.len--
+	}
+	tail := (w.head + w.len) % w.n
+	w.idx[tail], w.val[tail] = w.pushed, v
+	w.len++
+	w.pushed++
+	return w.val[w.head]
+}
+
+// SoftClip passes samples under -1 dBFS through untouched and bends louder ones smoothly
+// towards full scale, so they never go past it.
+func SoftClip(x float64) float64 {
+	const knee = 0.891 // -1 dBFS
+	a := math.Abs(x)
+	if a <= knee {
+		return x
+	}
+	y := knee + (1-knee)*math.Tanh((a-knee)/(1-knee))
+	if y > 1 {
+		y = 1
+	}
+	return math.Copysign(y, x)
+}
+
+// Decibels converts an amplitude to decibels relative to full scale. Silence is -Inf.
+func Decibels(amplitude float64) float64 {
+	return 20 * math.Log10(amplitude)
+}
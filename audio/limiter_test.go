@@ -0,0 +1,206 @@
+package audio_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleRate = beep.SampleRate(44100)
+
+// Sine streams a tone on both channels forever.
+func sine(freq, amplitude float64) beep.Streamer {
+	var n int
+	return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			v := amplitude * math.Sin(2*math.Pi*freq*float64(n)/float64(sampleRate))
+			samples[i] = [2]float64{v, v}
+			n++
+		}
+		return len(samples), true
+	})
+}
+
+// Frames streams the given samples, then is drained.
+func frames(f ...[2]float64) beep.Streamer {
+	return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		if len(f) == 0 {
+			return 0, false
+		}
+		n := copy(samples, f)
+		f = f[n:]
+		return n, true
+	})
+}
+
+// Drain streams everything from s, a few frames at a time, up to max frames.
+func drain(s beep.Streamer, max int) [][2]float64 {
+	var out [][2]float64
+	buf := make([][2]float64, 100)
+	for len(out) < max {
+		n, ok := s.Stream(buf)
+		out = append(out, buf[:n]...)
+		if !ok {
+			break
+		}
+	}
+	return out
+}
+
+func requireInRange(t *testing.T, samples [][2]float64, limit float64) {
+	t.Helper()
+	for i, s := range samples {
+		for c := range s {
+			if math.IsNaN(s[c]) || math.Abs(s[c]) > limit {
+				require.Failf(t, "out of range", "sample %d is %v, limit %v", i, s[c], limit)
+			}
+		}
+	}
+}
+
+func TestLimiterStaysInRange(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	ceiling := math.Pow(10, audio.DefaultCeiling/20)
+
+	tests := []struct {
+		name     string
+		streamer beep.Streamer
+	}{
+		{name: "quiet", streamer: sine(440, 0.5)},
+		{name: "just over", streamer: sine(440, 1.1)},
+		{
+			// A room full of chords at full velocity.
+			name: "summed chords",
+			streamer: beep.Mix(
+				sine(261.63, 0.9), sine(329.63, 0.9), sine(392, 0.9),
+				sine(220, 0.9), sine(277.18, 0.9), sine(329.63, 0.9),
+			),
+		},
+		{
+			name: "square wave",
+			streamer: beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+				for i := range samples {
+					samples[i] = [2]float64{4, -4}
+					if i%50 < 25 {
+						samples[i] = [2]float64{-4, 4}
+					}
+				}
+				return len(samples), true
+			}),
+		},
+		{
+			name: "noise",
+			streamer: beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+				for i := range samples {
+					samples[i] = [2]float64{rnd.NormFloat64() * 3, rnd.NormFloat64() * 3}
+				}
+				return len(samples), true
+			}),
+		},
+		{
+			name: "impulses",
+			streamer: beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+				for i := range samples {
+					samples[i] = [2]float64{}
+					if rnd.Intn(500) == 0 {
+						samples[i] = [2]float64{50, -0.1}
+					}
+				}
+				return len(samples), true
+			}),
+		},
+		{
+			name: "broken samples",
+			streamer: frames(
+				[2]float64{math.NaN(), 0.2}, [2]float64{math.Inf(1), math.Inf(-1)}, [2]float64{3, 3},
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := audio.NewLimiter(audio.NewLimiterOpts{Streamer: tt.streamer, SampleRate: sampleRate})
+			out := drain(l, sampleRate.N(2*time.Second))
+			require.NotEmpty(t, out)
+			requireInRange(t, out, 1)
+			// The lookahead turns peaks down before they arrive, so the soft clipper is only a backstop.
+			requireInRange(t, out, ceiling+1e-9)
+		})
+	}
+}
+
+func TestLimiterLeavesQuietSoundAlone(t *testing.T) {
+	in := make([][2]float64, 1000)
+	for i := range in {
+		in[i] = [2]float64{0.5 * math.Sin(float64(i)/10), 0.25}
+	}
+	lookahead := sampleRate.N(audio.DefaultLookahead)
+
+	l := audio.NewLimiter(audio.NewLimiterOpts{Streamer: frames(in...), SampleRate: sampleRate})
+	out := drain(l, 10000)
+	// Delayed by the lookahead, then played out to the end.
+	require.Len(t, out, len(in)+lookahead)
+	for i := range in {
+		require.InDelta(t, in[i][0], out[i+lookahead][0], 1e-12)
+		require.InDelta(t, in[i][1], out[i+lookahead][1], 1e-12)
+	}
+	require.Zero(t, l.Reduction())
+
+	n, ok := l.Stream(make([][2]float64, 10))
+	require.False(t, ok)
+	require.Zero(t, n)
+}
+
+func TestLimiterReleases(t *testing.T) {
+	// A loud burst, then a steady quiet tone.
+	burst := sampleRate.N(50 * time.Millisecond)
+	var n int
+	s := beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			samples[i] = [2]float64{0.5, 0.5}
+			if n < burst {
+				samples[i] = [2]float64{2, 2}
+			}
+			n++
+		}
+		return len(samples), true
+	})
+	l := audio.NewLimiter(audio.NewLimiterOpts{Streamer: s, SampleRate: sampleRate, Ceiling: -6})
+	out := drain(l, sampleRate.N(time.Second))
+	require.InDelta(t, 20*math.Log10(2)+6, l.Reduction(), 0.01)
+	require.Zero(t, l.Reduction(), "reading the reduction starts it afresh")
+
+	// A second after the burst, the quiet tone is back to its own level.
+	require.InDelta(t, 0.5, out[len(out)-1][0], 0.001)
+}
+
+func TestSoftClip(t *testing.T) {
+	require.Equal(t, 0.5, audio.SoftClip(0.5))
+	require.Equal(t, -0.8, audio.SoftClip(-0.8))
+	prev := audio.SoftClip(0.9)
+	for _, x := range []float64{0.95, 1, 1.5, 3, 100, math.MaxFloat64} {
+		y := audio.SoftClip(x)
+		require.LessOrEqual(t, y, 1.0)
+		require.GreaterOrEqual(t, y, prev, "louder in stays louder out")
+		require.Equal(t, -y, audio.SoftClip(-x))
+		prev = y
+	}
+}
+
+func TestMeter(t *testing.T) {
+	m := audio.NewMeter(sine(441, 0.8))
+	drain(m, 4410)
+	levels := m.Levels()
+	require.InDelta(t, 0.8, levels.Peak[0], 0.001)
+	require.InDelta(t, 0.8/math.Sqrt2, levels.RMS[1], 0.001)
+	require.Equal(t, audio.Levels{}, m.Levels(), "nothing streamed since")
+
+	m = audio.NewMeter(frames([2]float64{-0.5, 0}, [2]float64{0.5, 0}))
+	drain(m, 10)
+	require.Equal(t, audio.Levels{Peak: [2]float64{0.5, 0}, RMS: [2]float64{0.5, 0}}, m.Levels())
+}
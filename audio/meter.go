@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"math"
+	"sync"
+
+	"github.com/faiface/beep"
+)
+
+type (
+	// Levels are how loud each channel was over a stretch of samples, as amplitudes from 0 to 1.
+	Levels struct {
+		Peak [2]float64
+		RMS  [2]float64
+	}
+
+	// Meter keeps a running peak and sum of squares per channel of what its streamer plays,
+	// until Levels collects them.
+	Meter struct {
+		mu       sync.Mutex
+		streamer beep.Streamer
+		peak     [2]float64
+		squares  [2]float64
+		count    int
+	}
+)
+
+// NewMeter returns a meter on the streamer.
+func NewMeter(s beep.Streamer) *Meter {
+	return &Meter{streamer: s}
+}
+
+func (m *Meter) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = m.streamer.Stream(samples)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range samples[:n] {
+		for c := range s {
+			a := math.Abs(s[c])
+			if a > m.peak[c] {
+				m.peak[c] = a
+			}
+			m.squares[c] += s[c] * s[c]
+		}
+	}
+	m.count += n
+	return n, ok
+}
+
+func (m *Meter) Err() error {
+	return m.streamer.Err()
+}
+
+// Levels returns the levels of the samples streamed since it was last called, and starts
+// measuring afresh.
+func (m *Meter) Levels() Levels {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var l Levels
+	if m.count > 0 {
+		l.Peak = m.peak
+		for c := range l.RMS {
+			l.RMS[c] = math.Sqrt(m.squares[c] / float64(m.count))
+		}
+	}
+	m.peak, m.squares, m.count = [2]float64{}, [2]float64{}, 0
+	return l
+}
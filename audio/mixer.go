@@ -0,0 +1,228 @@
+// Package audio mixes the jam's sound before it reaches the speaker.
+//
+// Every player gets a bus with a volume, pan, mute and solo control, and the buses are summed
+// through a master fader.
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/config"
+)
+
+const (
+	// Fader range, in decibels.
+	MinVolume = -48.0
+	MaxVolume = 6.0
+	// SettingsFile is the name of the saved mixer settings in the rmxtui config directory.
+	SettingsFile = "mixer.json"
+)
+
+type (
+	// Strip is a mixer channel's controls.
+	Strip struct {
+		// Gain in decibels, 0 leaves the sound as it is.
+		Volume float64 `json:"volume"`
+		// -1 is hard left, 1 hard right.
+		Pan  float64 `json:"pan"`
+		Mute bool    `json:"mute,omitempty"`
+		// Soloing is for the moment, it isn't saved.
+		Solo bool `json:"-"`
+	}
+
+	// Settings are every strip's controls, kept across sessions.
+	Settings struct {
+		Master  Strip               `json:"master"`
+		Players map[uuid.UUID]Strip `json:"players"`
+	}
+
+	// Mixer is a beep.Streamer playing every player's bus through the master fader.
+	Mixer struct {
+		mu       sync.Mutex
+		settings Settings
+		buses    map[uuid.UUID]*bus
+		sum      beep.Mixer
+		// Master fader, wrapping the sum.
+		out effects.Volume
+		pan effects.Pan
+	}
+
+	bus struct {
+		in  beep.Mixer
+		pan effects.Pan
+		vol effects.Volume
+	}
+)
+
+// NewSettings returns settings with every strip at unity.
+func NewSettings() Settings {
+	return Settings{Players: make(map[uuid.UUID]Strip)}
+}
+
+// SettingsPath returns where the mixer settings are saved.
+func SettingsPath() (string, error) {
+	return config.Path(SettingsFile)
+}
+
+// LoadSettings reads the settings saved at path. Nothing saved yet is the default settings, and
+// so are settings that can't be read, returned with the error.
+func LoadSettings(path string) (Settings, error) {
+	s := NewSettings()
+	if err := config.LoadJSON(path, &s); err != nil {
+		return NewSettings(), fmt.Errorf("load mixer settings: %w", err)
+	}
+	if s.Players == nil {
+		s.Players = make(map[uuid.UUID]Strip)
+	}
+	s.Master = s.Master.Clamp()
+	for id, strip := range s.Players {
+		s.Players[id] = strip.Clamp()
+	}
+	return s, nil
+}
+
+// Save writes the settings to path, replacing any saved before.
+func (s Settings) Save(path string) error {
+	return config.SaveJSON(path, s)
+}
+
+// Clamp keeps the strip's volume and pan in range.
+func (s Strip) Clamp() Strip {
+	s.Volume = math.Max(MinVolume, math.Min(MaxVolume, s.Volume))
+	s.Pan = math.Max(-1, math.Min(1, s.Pan))
+	return s
+}
+
+// NewMixer returns a mixer using the given settings.
+func NewMixer(s Settings) *Mixer {
+	if s.Players == nil {
+		s.Players = make(map[uuid.UUID]Strip)
+	}
+	m := &Mixer{settings: s, buses: make(map[uuid.UUID]*bus)}
+	m.pan.Streamer = &m.sum
+	// Base 10 with the volume in dB/20 scales the amplitude by exactly the decibels.
+	m.out = effects.Volume{Streamer: &m.pan, Base: 10}
+	m.apply()
+	return m
+}
+
+// Add plays a streamer on a player's bus. Sounds that aren't from a player, like the metronome,
+// use uuid.Nil, which only the master fader controls.
+func (m *Mixer) Add(player uuid.UUID, s beep.Streamer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buses[player]
+	if !ok {
+		b = &bus{}
+		b.pan.Streamer = &b.in
+		b.vol = effects.Volume{Streamer: &b.pan, Base: 10}
+		m.buses[player] = b
+		m.sum.Add(&b.vol)
+		m.applyBus(player, b, m.soloing())
+	}
+	b.in.Add(s)
+}
+
+// Strip returns a player's controls.
+func (m *Mixer) Strip(player uuid.UUID) Strip {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings.Players[player]
+}
+
+// SetStrip changes a player's controls.
+func (m *Mixer) SetStrip(player uuid.UUID, s Strip) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings.Players[player] = s.Clamp()
+	m.apply()
+}
+
+// Master returns the master fader's controls.
+func (m *Mixer) Master() Strip {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings.Master
+}
+
+// SetMaster changes the master fader. It has no solo.
+func (m *Mixer) SetMaster(s Strip) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Solo = false
+	m.settings.Master = s.Clamp()
+	m.apply()
+}
+
+// Audible reports whether a player can be heard, so their notes needn't be rendered if not.
+func (m *Mixer) Audible(player uuid.UUID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.settings.Master.Mute && !m.silenced(player, m.soloing())
+}
+
+// Settings returns a copy of every strip's controls, for saving.
+func (m *Mixer) Settings() Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := Settings{Master: m.settings.Master, Players: make(map[uuid.UUID]Strip, len(m.settings.Players))}
+	for id, strip := range m.settings.Players {
+		s.Players[id] = strip
+	}
+	return s
+}
+
+// Stream mixes the buses into samples.
+func (m *Mixer) Stream(samples [][2]float64) (n int, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.out.Stream(samples)
+}
+
+func (m *Mixer) Err() error {
+	return nil
+}
+
+// Apply sets the effects from the settings.
+func (m *Mixer) apply() {
+	master := m.settings.Master
+	m.out.Volume = master.Volume / 20
+	m.out.Silent = master.Mute
+	m.pan.Pan = master.Pan
+	soloing := m.soloing()
+	for id, b := range m.buses {
+		m.applyBus(id, b, soloing)
+	}
+}
+
+func (m *Mixer) applyBus(player uuid.UUID, b *bus, soloing bool) {
+	s := m.settings.Players[player]
+	b.vol.Volume = s.Volume / 20
+	b.vol.Silent = m.silenced(player, soloing)
+	b.pan.Pan = s.Pan
+}
+
+// Silenced reports whether a player is muted, or someone else is soloed.
+func (m *Mixer) silenced(player uuid.UUID, soloing bool) bool {
+	s := m.settings.Players[player]
+	// The metronome and other local sounds aren't a player, soloing doesn't silence them.
+	if player == uuid.Nil {
+		return s.Mute
+	}
+	return s.Mute || (soloing && !s.Solo)
+}
+
+// Soloing reports whether any player is soloed.
+func (m *Mixer) soloing() bool {
+	for _, s := range m.settings.Players {
+		if s.Solo {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package audio_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faiface/beep"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/stretchr/testify/require"
+)
+
+// Constant streams the same sample on both channels forever.
+func constant(v float64) beep.Streamer {
+	return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			samples[i] = [2]float64{v, v}
+		}
+		return len(samples), true
+	})
+}
+
+// Sample streams one frame from the mixer.
+func sample(m *audio.Mixer) [2]float64 {
+	buf := make([][2]float64, 1)
+	m.Stream(buf)
+	return buf[0]
+}
+
+func TestMixer(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	m := audio.NewMixer(audio.NewSettings())
+	m.Add(alice, constant(0.25))
+	m.Add(bob, constant(0.5))
+	require.Equal(t, [2]float64{0.75, 0.75}, sample(m))
+
+	// -6dB is about half.
+	m.SetStrip(bob, audio.Strip{Volume: -6})
+	require.InDelta(t, 0.25+0.5*0.501, sample(m)[0], 0.001)
+
+	// Hard left moves the right channel over.
+	m.SetStrip(alice, audio.Strip{Pan: -1})
+	m.SetStrip(bob, audio.Strip{Mute: true})
+	require.Equal(t, [2]float64{0.5, 0}, sample(m))
+	require.False(t, m.Audible(bob))
+
+	// Soloing one player silences the rest, but not the metronome.
+	m.SetStrip(alice, audio.Strip{})
+	m.SetStrip(bob, audio.Strip{Solo: true})
+	m.Add(uuid.Nil, constant(0.125))
+	require.Equal(t, [2]float64{0.625, 0.625}, sample(m))
+	require.False(t, m.Audible(alice))
+	require.True(t, m.Audible(bob))
+
+	m.SetMaster(audio.Strip{Volume: 6})
+	require.InDelta(t, 0.625*1.995, sample(m)[0], 0.001)
+	m.SetMaster(audio.Strip{Mute: true})
+	require.Equal(t, [2]float64{0, 0}, sample(m))
+	require.False(t, m.Audible(bob))
+}
+
+func TestStripClamp(t *testing.T) {
+	require.Equal(t, audio.Strip{Volume: audio.MaxVolume, Pan: -1}, audio.Strip{Volume: 20, Pan: -3}.Clamp())
+	require.Equal(t, audio.Strip{Volume: audio.MinVolume, Pan: 1}, audio.Strip{Volume: -100, Pan: 2}.Clamp())
+}
+
+func TestSettingsSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), audio.SettingsFile)
+
+	// Nothing saved yet.
+	s, err := audio.LoadSettings(path)
+	require.NoError(t, err)
+	require.Equal(t, audio.NewSettings(), s)
+
+	alice := uuid.New()
+	m := audio.NewMixer(s)
+	m.SetStrip(alice, audio.Strip{Volume: -3, Pan: 0.5, Mute: true, Solo: true})
+	m.SetMaster(audio.Strip{Volume: -1.5})
+	require.NoError(t, m.Settings().Save(path))
+
+	s, err = audio.LoadSettings(path)
+	require.NoError(t, err)
+	require.Equal(t, audio.Strip{Volume: -1.5}, s.Master)
+	// Solo isn't kept.
+	require.Equal(t, audio.Strip{Volume: -3, Pan: 0.5, Mute: true}, s.Players[alice])
+
+	// Settings that can't be read are the defaults.
+	require.NoError(t, os.WriteFile(path, []byte(`{"master": {"volume": -1`), 0o600))
+	s, err = audio.LoadSettings(path)
+	require.Error(t, err)
+	require.Equal(t, audio.NewSettings(), s)
+}
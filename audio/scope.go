@@ -0,0 +1,132 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+
+	"github.com/faiface/beep"
+)
+
+// ScopeFrames is how many of the latest frames a scope keeps, enough for a spectrum's lowest
+// bands at 96 kHz.
+const ScopeFrames = 4096
+
+// Scope remembers the last ScopeFrames frames its streamer played in a ring, for the
+// oscilloscope and spectrum views to read with Latest.
+type Scope struct {
+	mu       sync.Mutex
+	streamer beep.Streamer
+	ring     [ScopeFrames][2]float64
+	pos      int
+}
+
+// NewScope returns a scope on the streamer.
+func NewScope(s beep.Streamer) *Scope {
+	return &Scope{streamer: s}
+}
+
+func (s *Scope) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = s.streamer.Stream(samples)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in := samples[:n]
+	if len(in) > ScopeFrames {
+		in = in[len(in)-ScopeFrames:]
+	}
+	for len(in) > 0 {
+		k := copy(s.ring[s.pos:], in)
+		s.pos = (s.pos + k) % ScopeFrames
+		in = in[k:]
+	}
+	return n, ok
+}
+
+func (s *Scope) Err() error {
+	return s.streamer.Err()
+}
+
+// Latest fills buf with the latest frames streamed, oldest first. It holds at most ScopeFrames,
+// the rest of a longer buf is left alone.
+func (s *Scope) Latest(buf [][2]float64) [][2]float64 {
+	if len(buf) > ScopeFrames {
+		buf = buf[:ScopeFrames]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := (s.pos - len(buf) + ScopeFrames) % ScopeFrames
+	n := copy(buf, s.ring[start:])
+	copy(buf[n:], s.ring[:])
+	return buf
+}
+
+// Spectrum returns how loud the samples are in each of bands, in dBFS, from minHz to maxHz. The
+// bands are spaced evenly in pitch, as heard, so each octave gets as many. len(samples) should be
+// a power of two, or it's cut down to one.
+func Spectrum(samples []float64, sr beep.SampleRate, bands int, minHz, maxHz float64) []float64 {
+	n := 1
+	for n*2 <= len(samples) {
+		n *= 2
+	}
+	out := make([]float64, bands)
+	for i := range out {
+		out[i] = math.Inf(-1)
+	}
+	if n < 2 || bands == 0 {
+		return out
+	}
+
+	// A Hann window keeps a loud band from smearing over its neighbours. It halves the level
+	// of a steady tone, which the scale makes up for.
+	x := make([]complex128, n)
+	for i := range x {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		x[i] = complex(samples[len(samples)-n+i]*w, 0)
+	}
+	fft(x)
+	scale := 4 / float64(n)
+
+	binHz := float64(sr) / float64(n)
+	ratio := math.Pow(maxHz/minHz, 1/float64(bands))
+	for b := range out {
+		lo, hi := minHz*math.Pow(ratio, float64(b)), minHz*math.Pow(ratio, float64(b+1))
+		// Low bands narrower than a bin take the bin they fall in.
+		first := int(math.Round(lo / binHz))
+		last := int(math.Round(hi/binHz)) - 1
+		if last < first {
+			last = first
+		}
+		peak := 0.0
+		for k := first; k <= last && k < n/2; k++ {
+			peak = math.Max(peak, cmplx.Abs(x[k])*scale)
+		}
+		out[b] = 20 * math.Log10(peak)
+	}
+	return out
+}
+
+// FFT transforms x in place. len(x) is a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package audio_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope(t *testing.T) {
+	var in [][2]float64
+	for i := 0; i < audio.ScopeFrames+1000; i++ {
+		in = append(in, [2]float64{float64(i), -float64(i)})
+	}
+	s := audio.NewScope(frames(in...))
+
+	buf := make([][2]float64, 10)
+	require.Equal(t, make([][2]float64, 10), s.Latest(buf), "nothing streamed yet")
+
+	// Passed through untouched, wrapping around the scope's buffer.
+	out := drain(s, len(in))
+	require.Equal(t, in, out)
+	require.Equal(t, in[len(in)-10:], s.Latest(buf))
+
+	all := s.Latest(make([][2]float64, audio.ScopeFrames+5))
+	require.Len(t, all, audio.ScopeFrames, "only so much is kept")
+	require.Equal(t, in[len(in)-audio.ScopeFrames:], all)
+}
+
+func TestScopeLongBuffer(t *testing.T) {
+	// A stream bigger than the scope only keeps its end.
+	in := drain(sine(440, 0.5), audio.ScopeFrames*2+7)
+	s := audio.NewScope(frames(in...))
+	buf := make([][2]float64, len(in))
+	n, _ := s.Stream(buf)
+	require.Equal(t, len(in), n)
+	require.Equal(t, in[len(in)-100:], s.Latest(make([][2]float64, 100)))
+}
+
+func TestSpectrum(t *testing.T) {
+	samples := make([]float64, 2048)
+	for _, s := range drain(sine(1000, 0.5), len(samples)) {
+		samples = append(samples[1:], s[0])
+	}
+	bands := audio.Spectrum(samples, sampleRate, 24, 50, 16000)
+	require.Len(t, bands, 24)
+
+	loudest := 0
+	for i := range bands {
+		if bands[i] > bands[loudest] {
+			loudest = i
+		}
+	}
+	// The band holding 1 kHz, at the sine's level.
+	ratio := math.Pow(16000.0/50, 1.0/24)
+	lo, hi := 50*math.Pow(ratio, float64(loudest)), 50*math.Pow(ratio, float64(loudest+1))
+	require.True(t, lo <= 1000 && 1000 < hi, "loudest band %.0f-%.0f Hz", lo, hi)
+	require.InDelta(t, 20*math.Log10(0.5), bands[loudest], 1.5)
+	// Far from the tone is far quieter.
+	require.Less(t, bands[0], bands[loudest]-40)
+	require.Less(t, bands[23], bands[loudest]-40)
+
+	silent := audio.Spectrum(make([]float64, 1024), sampleRate, 8, 50, 16000)
+	for _, b := range silent {
+		require.True(t, math.IsInf(b, -1))
+	}
+}
@@ -0,0 +1,231 @@
+package audio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/clock"
+)
+
+const (
+	// Underruns within underrunWindow that grow the buffer.
+	underrunsToGrow = 3
+	underrunWindow  = 5 * time.Second
+	// How long the sound has to keep up before the buffer shrinks again.
+	shrinkAfter = 30 * time.Second
+	// Most of the mix rendered ahead at a time, so the output never waits long for a render.
+	aheadChunk = 5 * time.Millisecond
+)
+
+type (
+	// Tuner picks a buffer length from how long the mix takes to stream. It grows the buffer
+	// when the mix can't keep up with the output, and slowly shrinks it while it can, but never
+	// back to a length that couldn't keep up.
+	Tuner struct {
+		buffer time.Duration
+		// The output's own buffer, which each stream has to be done within and the buffer
+		// never shrinks below. Zero is the buffer itself.
+		output time.Duration
+		// Longest buffer that couldn't keep up.
+		tooShort time.Duration
+		// Start of the last stream, and when the buffer last changed.
+		last      time.Time
+		changedAt time.Time
+		// Underruns ever, and in the current window.
+		underruns    int
+		recent       int
+		windowStart  time.Time
+		lastUnderrun time.Time
+	}
+
+	// AutoTuned is a Backend whose buffer is tuned as it plays. The output's buffer stays as it
+	// was opened, restarting a sound card mid-jam would glitch. Instead the mix is rendered
+	// ahead of the output, as far ahead as the tuner's buffer is longer than the output's.
+	AutoTuned struct {
+		Backend
+		clock clock.Clock
+		mu    sync.Mutex
+		// Signalled when the output takes from ahead, or it's wanted longer.
+		wake   *sync.Cond
+		tuner  *Tuner
+		mixer  beep.Mixer
+		ahead  [][2]float64
+		closed bool
+		done   chan struct{}
+	}
+)
+
+// NewTuner returns a tuner starting from buffer.
+func NewTuner(buffer time.Duration) *Tuner {
+	return &Tuner{buffer: buffer}
+}
+
+// NewOutputTuner returns a tuner for an output with a fixed buffer, which only tunes how much
+// more is buffered ahead of it.
+func NewOutputTuner(output time.Duration) *Tuner {
+	return &Tuner{buffer: output, output: output}
+}
+
+// Buffer returns the buffer length picked.
+func (t *Tuner) Buffer() time.Duration {
+	return t.buffer
+}
+
+// Underruns returns how many times the mix couldn't keep up.
+func (t *Tuner) Underruns() int {
+	return t.underruns
+}
+
+// Observe records a buffer's worth of mix streamed at time at, which took took. It returns the
+// buffer length to use from now on, and whether it's changed.
+func (t *Tuner) Observe(at time.Time, took time.Duration) (time.Duration, bool) {
+	if t.changedAt.IsZero() {
+		t.changedAt, t.lastUnderrun = at, at
+	}
+	// The output asks for the next buffer as the last one runs out, so asking late, or taking
+	// longer than a buffer to stream it, leaves the output with nothing to play.
+	limit := t.buffer
+	if t.output > 0 {
+		limit = t.output
+	}
+	late := !t.last.IsZero() && at.Sub(t.last) > limit*3/2
+	t.last = at
+
+	if took > limit || late {
+		t.underruns++
+		t.lastUnderrun = at
+		if at.Sub(t.windowStart) > underrunWindow {
+			t.windowStart, t.recent = at, 0
+		}
+		t.recent++
+		if t.recent < underrunsToGrow || t.buffer >= MaxBuffer {
+			return t.buffer, false
+		}
+		if t.buffer > t.tooShort {
+			t.tooShort = t.buffer
+		}
+		return t.set(t.buffer*3/2, at), true
+	}
+
+	if at.Sub(t.lastUnderrun) < shrinkAfter || at.Sub(t.changedAt) < shrinkAfter {
+		return t.buffer, false
+	}
+	shorter := (t.buffer * 4 / 5).Round(time.Millisecond)
+	if shorter < MinBuffer {
+		shorter = MinBuffer
+	}
+	if shorter < t.output {
+		shorter = t.output
+	}
+	if shorter <= t.tooShort || shorter >= t.buffer {
+		return t.buffer, false
+	}
+	return t.set(shorter, at), true
+}
+
+func (t *Tuner) set(d time.Duration, at time.Time) time.Duration {
+	d = d.Round(time.Millisecond)
+	if d > MaxBuffer {
+		d = MaxBuffer
+	}
+	t.buffer = d
+	t.changedAt = at
+	t.recent = 0
+	// The output restarting isn't the mix falling behind.
+	t.last = time.Time{}
+	return d
+}
+
+// NewAutoTuned tunes b's buffer as it plays.
+func NewAutoTuned(b Backend) *AutoTuned {
+	a := &AutoTuned{
+		Backend: b,
+		clock:   clock.Wall,
+		tuner:   NewOutputTuner(b.Buffer()),
+		done:    make(chan struct{}),
+	}
+	a.wake = sync.NewCond(&a.mu)
+	go a.run()
+	b.Play(a)
+	return a
+}
+
+// Run renders the mix ahead of the output, a little at a time, until closed.
+func (a *AutoTuned) run() {
+	defer close(a.done)
+	sr := a.Backend.SampleRate()
+	buf := make([][2]float64, sr.N(aheadChunk))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for {
+		want := sr.N(a.tuner.Buffer() - a.Backend.Buffer())
+		for !a.closed && len(a.ahead) >= want {
+			a.wake.Wait()
+			want = sr.N(a.tuner.Buffer() - a.Backend.Buffer())
+		}
+		if a.closed {
+			return
+		}
+		part := buf[:min(len(buf), want-len(a.ahead))]
+		n, _ := a.mixer.Stream(part)
+		a.ahead = append(a.ahead, part[:n]...)
+	}
+}
+
+func (a *AutoTuned) Play(s beep.Streamer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mixer.Add(s)
+}
+
+// Stream plays what's been rendered ahead, rendering the rest itself, timing how long it takes.
+func (a *AutoTuned) Stream(samples [][2]float64) (n int, ok bool) {
+	start := a.clock.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n = copy(samples, a.ahead)
+	a.ahead = a.ahead[:copy(a.ahead, a.ahead[n:])]
+	if n < len(samples) {
+		a.mixer.Stream(samples[n:])
+	}
+	if _, changed := a.tuner.Observe(start, a.clock.Now().Sub(start)); changed || n > 0 {
+		a.wake.Signal()
+	}
+	return len(samples), true
+}
+
+func (a *AutoTuned) Err() error {
+	return nil
+}
+
+// Buffer returns how far behind the mix the output plays: the output's buffer, and what's
+// rendered ahead of it.
+func (a *AutoTuned) Buffer() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tuner.Buffer()
+}
+
+// Underruns returns how many times the sound couldn't keep up with the output.
+func (a *AutoTuned) Underruns() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tuner.Underruns()
+}
+
+func (a *AutoTuned) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.wake.Broadcast()
+	a.mu.Unlock()
+	<-a.done
+	return a.Backend.Close()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
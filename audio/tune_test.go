@@ -0,0 +1,140 @@
+package audio_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSampleRate(t *testing.T) {
+	for in, want := range map[string]beep.SampleRate{"44100": 44100, "48k": 48000, "96kHz": 96000, "44.1k": 44100} {
+		got, err := audio.ParseSampleRate(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	for _, bad := range []string{"22050", "fast", "192k"} {
+		_, err := audio.ParseSampleRate(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestParseBuffer(t *testing.T) {
+	d, auto, err := audio.ParseBuffer("auto")
+	require.NoError(t, err)
+	require.True(t, auto)
+	require.Equal(t, audio.DefaultBuffer, d)
+
+	d, auto, err = audio.ParseBuffer("50ms")
+	require.NoError(t, err)
+	require.False(t, auto)
+	require.Equal(t, 50*time.Millisecond, d)
+
+	for _, bad := range []string{"1ms", "2s", "big"} {
+		_, _, err := audio.ParseBuffer(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestTuner(t *testing.T) {
+	start := time.Date(2022, 12, 1, 20, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+	tuner := audio.NewTuner(20 * ms)
+	now := start
+
+	// Streams a buffer's worth, taking took, and moves on by the buffer.
+	stream := func(took time.Duration) (time.Duration, bool) {
+		d, changed := tuner.Observe(now, took)
+		now = now.Add(tuner.Buffer())
+		return d, changed
+	}
+
+	for i := 0; i < 100; i++ {
+		_, changed := stream(5 * ms)
+		require.False(t, changed, "keeping up")
+	}
+
+	// Two underruns aren't enough to grow.
+	stream(25 * ms)
+	_, changed := stream(25 * ms)
+	require.False(t, changed)
+	d, changed := stream(25 * ms)
+	require.True(t, changed)
+	require.Equal(t, 30*ms, d)
+	require.Equal(t, 3, tuner.Underruns())
+
+	// Asking late is an underrun too.
+	stream(ms)
+	for i := 0; i < 3; i++ {
+		now = now.Add(40 * ms)
+		d, changed = stream(ms)
+	}
+	require.True(t, changed)
+	require.Equal(t, 45*ms, d)
+
+	// It grows no further than the most.
+	for i := 0; i < 100; i++ {
+		stream(time.Second)
+	}
+	require.Equal(t, audio.MaxBuffer, tuner.Buffer())
+
+	// Once it keeps up for a while it shrinks, but not back to a length that couldn't keep up.
+	for now.Before(start.Add(10 * time.Minute)) {
+		stream(ms)
+	}
+	require.Greater(t, tuner.Buffer(), audio.MaxBuffer*2/3)
+	require.Less(t, tuner.Buffer(), audio.MaxBuffer)
+}
+
+func TestOutputTuner(t *testing.T) {
+	now := time.Date(2022, 12, 1, 20, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+	tuner := audio.NewOutputTuner(20 * ms)
+
+	// The output asks every 20ms however much is buffered ahead of it, and each stream has to
+	// be done within that.
+	stream := func(took time.Duration) (time.Duration, bool) {
+		d, changed := tuner.Observe(now, took)
+		now = now.Add(20 * ms)
+		return d, changed
+	}
+	var d time.Duration
+	for i := 0; i < 6; i++ {
+		d, _ = stream(25 * ms)
+	}
+	require.Equal(t, 45*ms, d)
+	d, changed := stream(25 * ms)
+	require.False(t, changed, "still judged against the output's 20ms")
+
+	// Keeping up, it shrinks, but not back to a length that couldn't keep up.
+	for i := 0; i < 10000; i++ {
+		stream(ms)
+	}
+	require.Less(t, tuner.Buffer(), 45*ms)
+	require.Greater(t, tuner.Buffer(), 30*ms)
+
+	// Nor below the output's buffer.
+	tuner = audio.NewOutputTuner(20 * ms)
+	for i := 0; i < 10000; i++ {
+		stream(ms)
+	}
+	require.Equal(t, 20*ms, tuner.Buffer())
+}
+
+func TestAutoTuned(t *testing.T) {
+	b, err := audio.Open(audio.Config{Output: "null", Buffer: 10 * time.Millisecond, AutoTune: true})
+	require.NoError(t, err)
+	a, ok := b.(*audio.AutoTuned)
+	require.True(t, ok)
+
+	// A mix too slow for the buffer.
+	a.Play(beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		time.Sleep(15 * time.Millisecond)
+		return len(samples), true
+	}))
+	require.Eventually(t, func() bool { return a.Buffer() > 10*time.Millisecond }, 2*time.Second, 10*time.Millisecond)
+	require.GreaterOrEqual(t, a.Underruns(), 3)
+	require.NoError(t, a.Close())
+}
@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/faiface/beep"
+)
+
+// Size of a WAV file's header, up to the first sample.
+const wavHeaderSize = 44
+
+// WAVWriter writes 16-bit stereo samples to a WAV file as they're played, for sound that
+// has no end like the mix.
+type WAVWriter struct {
+	w      io.WriteSeeker
+	format beep.Format
+	frames int
+	buf    []byte
+	// Closes the file when the writer is closed, nil if the caller owns it.
+	closer io.Closer
+}
+
+// CreateWAV creates a WAV file at path, replacing any already there.
+func CreateWAV(path string, sr beep.SampleRate) (*WAVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWAVWriter(f, sr)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// NewWAVWriter starts a WAV file on w. The sizes in its header are filled in on Close.
+func NewWAVWriter(w io.WriteSeeker, sr beep.SampleRate) (*WAVWriter, error) {
+	ww := &WAVWriter{w: w, format: beep.Format{SampleRate: sr, NumChannels: 2, Precision: 2}}
+	if err := ww.writeHeader(); err != nil {
+		return nil, fmt.Errorf("write WAV header: %w", err)
+	}
+	return ww, nil
+}
+
+// Write adds samples to the file, clipping any outside [-1, 1].
+func (w *WAVWriter) Write(samples [][2]float64) error {
+	size := len(samples) * w.format.Width()
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+	buf := w.buf[:size]
+	p := buf
+	for _, s := range samples {
+		for c := range s {
+			s[c] = math.Max(-1, math.Min(1, s[c]))
+		}
+		p = p[w.format.EncodeSigned(p, s):]
+	}
+	if _, err := w.w.Write(buf); err != nil {
+		return err
+	}
+	w.frames += len(samples)
+	return nil
+}
+
+// Frames returns how many frames have been written.
+func (w *WAVWriter) Frames() int {
+	return w.frames
+}
+
+// Size returns the file's size so far, in bytes.
+func (w *WAVWriter) Size() int64 {
+	return wavHeaderSize + int64(w.frames*w.format.Width())
+}
+
+// Close fills in the header's sizes and closes the file if the writer created it.
+func (w *WAVWriter) Close() error {
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return fmt.Errorf("write WAV header: %w", err)
+	}
+	if _, err := w.w.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+func (w *WAVWriter) writeHeader() error {
+	data := uint32(w.frames * w.format.Width())
+	header := struct {
+		RIFF          [4]byte
+		Size          uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		Size:          wavHeaderSize - 8 + data,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   1, // PCM
+		Channels:      uint16(w.format.NumChannels),
+		SampleRate:    uint32(w.format.SampleRate),
+		ByteRate:      uint32(int(w.format.SampleRate) * w.format.Width()),
+		BlockAlign:    uint16(w.format.Width()),
+		BitsPerSample: uint16(8 * w.format.Precision),
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      data,
+	}
+	return binary.Write(w.w, binary.LittleEndian, header)
+}
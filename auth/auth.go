@@ -0,0 +1,310 @@
+// Package auth handles logging in to the RMX API and attaching bearer tokens to requests.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tokens expiring within this window are refreshed before use.
+const refreshLeeway = 30 * time.Second
+
+var (
+	// ErrNotLoggedIn is returned when a token is required but the user has not logged in.
+	ErrNotLoggedIn = errors.New("not logged in")
+	// ErrInvalidCredentials is returned when the server rejects the username or password.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrSessionExpired is returned when the refresh token is no longer accepted.
+	ErrSessionExpired = errors.New("session expired, please log in again")
+)
+
+type (
+	Token struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+		// Zero if the access token doesn't expire.
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+
+	// Client logs in to the RMX API and keeps the current token fresh.
+	// A Client without a token is anonymous: requests are sent without credentials.
+	Client struct {
+		apiURL string
+		store  *Store
+		http   *http.Client
+
+		mu    sync.Mutex
+		token Token
+		// Held for the whole of a refresh, so concurrent ones are coalesced.
+		refreshMu sync.Mutex
+	}
+
+	credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	refreshReq struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	tokenResp struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+		// Lifetime of the access token in seconds. 0 means it doesn't expire.
+		ExpiresIn int `json:"expiresIn"`
+	}
+
+	// transport attaches the client's bearer token to outgoing requests.
+	transport struct {
+		client *Client
+		base   http.RoundTripper
+	}
+)
+
+// NewClient creates a client for the RMX API at apiURL, ex: "https://rmx.fly.dev/api/v1".
+// A previously stored token is loaded from the store, if there is one.
+// The store may be nil, in which case tokens only live in memory.
+// A token file readable by others is ignored: the client is returned anonymous,
+// along with an error wrapping ErrInsecureFile to warn about it. Logging in again replaces the file.
+func NewClient(apiURL string, store *Store) (*Client, error) {
+	c := &Client{
+		apiURL: apiURL,
+		store:  store,
+		http:   &http.Client{Timeout: 10 * time.Second},
+	}
+	if store == nil {
+		return c, nil
+	}
+
+	t, err := store.Load()
+	if errors.Is(err, ErrInsecureFile) {
+		return c, fmt.Errorf("ignoring stored token: %w", err)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load token: %w", err)
+	}
+	c.token = t
+	return c, nil
+}
+
+// LoggedIn reports whether the client has a token to authenticate with.
+func (c *Client) LoggedIn() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token.AccessToken != ""
+}
+
+// Login exchanges a username and password for a token and stores it.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	t, err := c.requestToken(ctx, "/auth/login", credentials{Username: username, Password: password})
+	if errors.Is(err, errUnauthorized) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	return c.setToken(t)
+}
+
+// Logout forgets the current token.
+func (c *Client) Logout() error {
+	c.mu.Lock()
+	c.token = Token{}
+	c.mu.Unlock()
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Clear()
+}
+
+// Token returns a valid access token, refreshing it first if it is about to expire.
+func (c *Client) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	t := c.token
+	c.mu.Unlock()
+
+	if t.AccessToken == "" {
+		return Token{}, ErrNotLoggedIn
+	}
+	if !t.expiring() {
+		return t, nil
+	}
+	return c.refresh(ctx, t.AccessToken)
+}
+
+// Refresh exchanges the refresh token for a new access token.
+func (c *Client) Refresh(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	stale := c.token.AccessToken
+	c.mu.Unlock()
+	return c.refresh(ctx, stale)
+}
+
+// Refresh replaces the stale access token. Servers that rotate refresh tokens only accept each
+// one once, so a caller that waited on another refresh takes its token instead of refreshing again.
+func (c *Client) refresh(ctx context.Context, stale string) (Token, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.Lock()
+	cur := c.token
+	c.mu.Unlock()
+	if cur.AccessToken != "" && cur.AccessToken != stale {
+		return cur, nil
+	}
+
+	refresh := cur.RefreshToken
+	if refresh == "" {
+		return Token{}, ErrNotLoggedIn
+	}
+	t, err := c.requestToken(ctx, "/auth/refresh", refreshReq{RefreshToken: refresh})
+	if errors.Is(err, errUnauthorized) {
+		// Refresh token revoked or expired. Drop it so we stop retrying.
+		if err := c.Logout(); err != nil {
+			return Token{}, err
+		}
+		return Token{}, ErrSessionExpired
+	}
+	if err != nil {
+		return Token{}, fmt.Errorf("refresh: %w", err)
+	}
+	if t.RefreshToken == "" {
+		// Server did not rotate the refresh token, keep using the old one.
+		t.RefreshToken = refresh
+	}
+	return t, c.setToken(t)
+}
+
+// Header returns the headers to authenticate a websocket dial.
+// Anonymous clients get an empty header.
+func (c *Client) Header(ctx context.Context) (http.Header, error) {
+	h := http.Header{}
+	if !c.LoggedIn() {
+		return h, nil
+	}
+	t, err := c.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.Set("Authorization", "Bearer "+t.AccessToken)
+	return h, nil
+}
+
+// HTTPClient returns an http.Client that authenticates requests with the client's token.
+// Requests rejected with 401 are retried once after refreshing the token.
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &transport{client: c, base: http.DefaultTransport},
+	}
+}
+
+func (c *Client) setToken(t Token) error {
+	c.mu.Lock()
+	c.token = t
+	c.mu.Unlock()
+	if c.store == nil {
+		return nil
+	}
+	if err := c.store.Save(t); err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	return nil
+}
+
+var errUnauthorized = errors.New("unauthorized")
+
+func (c *Client) requestToken(ctx context.Context, path string, body any) (Token, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return Token{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+path, bytes.NewReader(b))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return Token{}, errUnauthorized
+	}
+	if res.StatusCode >= 400 {
+		return Token{}, fmt.Errorf("unexpected status: %d", res.StatusCode)
+	}
+
+	var tr tokenResp
+	if err := json.NewDecoder(res.Body).Decode(&tr); err != nil {
+		return Token{}, fmt.Errorf("decode: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return Token{}, errors.New("server returned an empty access token")
+	}
+	t := Token{
+		AccessToken:  tr.AccessToken,
+		RefreshToken: tr.RefreshToken,
+	}
+	if tr.ExpiresIn > 0 {
+		t.ExpiresAt = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// Expiring reports whether the access token is about to expire. A token without an expiry never does.
+func (t Token) expiring() bool {
+	return !t.ExpiresAt.IsZero() && time.Until(t.ExpiresAt) <= refreshLeeway
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.client.LoggedIn() {
+		return t.base.RoundTrip(req)
+	}
+
+	tok, err := t.client.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.base.RoundTrip(withBearer(req, tok.AccessToken))
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	// The token may have been revoked before it expired. Refresh and try once more,
+	// as long as the request body can be replayed.
+	if req.Body != nil && req.GetBody == nil {
+		return res, nil
+	}
+	tok, err = t.client.refresh(req.Context(), tok.AccessToken)
+	if err != nil {
+		return res, nil
+	}
+	res.Body.Close()
+
+	retry := withBearer(req, tok.AccessToken)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(retry)
+}
+
+func withBearer(req *http.Request, accessToken string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	return r
+}
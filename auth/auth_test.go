@@ -0,0 +1,297 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/auth"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI is a stand-in for the RMX API auth endpoints.
+type fakeAPI struct {
+	mu sync.Mutex
+	// Lifetime of issued access tokens in seconds.
+	expiresIn int
+	issued    int
+	refreshes int
+	// Access tokens the server accepts.
+	valid map[string]bool
+	// Refresh tokens the server accepts.
+	refreshable map[string]bool
+}
+
+func newFakeAPI(expiresIn int) (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{
+		expiresIn:   expiresIn,
+		valid:       make(map[string]bool),
+		refreshable: make(map[string]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/login", api.login)
+	mux.HandleFunc("/auth/refresh", api.refresh)
+	mux.HandleFunc("/jam", api.jams)
+	return api, httptest.NewServer(mux)
+}
+
+func (a *fakeAPI) issue(w http.ResponseWriter) {
+	a.issued++
+	access := fmt.Sprintf("access-%d", a.issued)
+	refresh := fmt.Sprintf("refresh-%d", a.issued)
+	a.valid[access] = true
+	a.refreshable[refresh] = true
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"accessToken":  access,
+		"refreshToken": refresh,
+		"expiresIn":    a.expiresIn,
+	})
+}
+
+func (a *fakeAPI) login(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var c struct{ Username, Password string }
+	_ = json.NewDecoder(r.Body).Decode(&c)
+	if c.Username != "jeff" || c.Password != "hunter2" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	a.issue(w)
+}
+
+func (a *fakeAPI) refresh(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var body struct{ RefreshToken string }
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	if !a.refreshable[body.RefreshToken] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	delete(a.refreshable, body.RefreshToken)
+	a.refreshes++
+	a.issue(w)
+}
+
+func (a *fakeAPI) jams(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !a.valid[h[len(prefix):]] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	_, _ = w.Write([]byte(`{"rooms":[]}`))
+}
+
+// revokeRefresh invalidates every refresh token issued so far.
+func (a *fakeAPI) revokeRefresh() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refreshable = make(map[string]bool)
+}
+
+// revokeAll invalidates every access token issued so far.
+func (a *fakeAPI) revokeAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.valid = make(map[string]bool)
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rejects bad credentials", func(t *testing.T) {
+		_, srv := newFakeAPI(3600)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+		err = c.Login(ctx, "jeff", "wrong")
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		require.False(t, c.LoggedIn())
+	})
+
+	t.Run("attaches the bearer token to API requests and websocket headers", func(t *testing.T) {
+		_, srv := newFakeAPI(3600)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+
+		res, err := c.HTTPClient().Get(srv.URL + "/jam")
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode, "anonymous request should not be authorized")
+
+		h, err := c.Header(ctx)
+		require.NoError(t, err)
+		require.Empty(t, h.Get("Authorization"))
+
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+
+		res, err = c.HTTPClient().Get(srv.URL + "/jam")
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		h, err = c.Header(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "Bearer access-1", h.Get("Authorization"))
+	})
+
+	t.Run("refreshes tokens that are about to expire", func(t *testing.T) {
+		api, srv := newFakeAPI(1)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+
+		tok, err := c.Token(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "access-2", tok.AccessToken)
+		require.Equal(t, "refresh-2", tok.RefreshToken)
+		require.Equal(t, 1, api.refreshes)
+	})
+
+	t.Run("keeps tokens without an expiry", func(t *testing.T) {
+		api, srv := newFakeAPI(0)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+
+		tok, err := c.Token(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "access-1", tok.AccessToken)
+		require.Equal(t, 0, api.refreshes)
+	})
+
+	t.Run("refreshes once for concurrent requests", func(t *testing.T) {
+		api, srv := newFakeAPI(1)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+
+		// The server rotates refresh tokens, so a second refresh with the old one would log us out.
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = c.Token(ctx)
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		require.True(t, c.LoggedIn())
+		require.Equal(t, 1, api.refreshes)
+	})
+
+	t.Run("retries once with a fresh token when a request is rejected", func(t *testing.T) {
+		api, srv := newFakeAPI(3600)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+		api.revokeAll()
+
+		res, err := c.HTTPClient().Get(srv.URL + "/jam")
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 1, api.refreshes)
+	})
+
+	t.Run("logs out when the refresh token is rejected", func(t *testing.T) {
+		api, srv := newFakeAPI(3600)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+
+		_, err = c.Refresh(ctx)
+		require.NoError(t, err)
+		// Server forgot its sessions, ex: after a restart.
+		api.revokeRefresh()
+		_, err = c.Refresh(ctx)
+		require.ErrorIs(t, err, auth.ErrSessionExpired)
+		require.False(t, c.LoggedIn())
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), auth.TokenFileName)
+	store := auth.NewStore(path)
+
+	t.Run("reports a missing token as not existing", func(t *testing.T) {
+		_, err := store.Load()
+		require.True(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("persists the login across clients", func(t *testing.T) {
+		_, srv := newFakeAPI(3600)
+		defer srv.Close()
+
+		c, err := auth.NewClient(srv.URL, store)
+		require.NoError(t, err)
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		}
+
+		c2, err := auth.NewClient(srv.URL, store)
+		require.NoError(t, err)
+		require.True(t, c2.LoggedIn())
+
+		require.NoError(t, c2.Logout())
+		_, err = store.Load()
+		require.True(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("refuses token files readable by others", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no unix permissions on windows")
+		}
+		require.NoError(t, store.Save(auth.Token{AccessToken: "a", RefreshToken: "r"}))
+		require.NoError(t, os.Chmod(path, 0o644))
+
+		_, err := store.Load()
+		require.ErrorIs(t, err, auth.ErrInsecureFile)
+
+		// The client starts anonymous instead, and logging in replaces the file.
+		_, srv := newFakeAPI(3600)
+		defer srv.Close()
+		c, err := auth.NewClient(srv.URL, store)
+		require.ErrorIs(t, err, auth.ErrInsecureFile)
+		require.NotNil(t, c)
+		require.False(t, c.LoggedIn())
+
+		require.NoError(t, c.Login(ctx, "jeff", "hunter2"))
+		_, err = store.Load()
+		require.NoError(t, err)
+	})
+}
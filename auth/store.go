@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/rapidmidiex/rmxtui/config"
+)
+
+// TokenFileName is the name of the token file in the rmxtui config directory.
+const TokenFileName = "token.json"
+
+// ErrInsecureFile is returned when the token file can be read by other users.
+var ErrInsecureFile = errors.New("token file permissions are too open")
+
+// Store persists tokens to a file only readable by the current user.
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultStorePath returns the location of the token file in the rmxtui config directory.
+func DefaultStorePath() (string, error) {
+	return config.Path(TokenFileName)
+}
+
+// Load reads the stored token.
+// If no token has been saved yet the returned error wraps os.ErrNotExist.
+// Tokens in files readable or writable by group/others are refused with ErrInsecureFile.
+func (s *Store) Load() (Token, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return Token{}, err
+	}
+	if err := checkPerm(info); err != nil {
+		return Token{}, err
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return Token{}, err
+	}
+	var t Token
+	if err := json.Unmarshal(b, &t); err != nil {
+		return Token{}, fmt.Errorf("unmarshal token: %w", err)
+	}
+	return t, nil
+}
+
+// Save atomically replaces the stored token.
+func (s *Store) Save(t Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("marshal token: %w", err)
+	}
+
+	// Write to a private temp file first so a crash never leaves a half written token behind.
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".token-*")
+	if err != nil {
+		return fmt.Errorf("createTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0o600); err != nil && runtime.GOOS != "windows" {
+		f.Close()
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// Clear removes the stored token. Clearing an empty store is not an error.
+func (s *Store) Clear() error {
+	err := os.Remove(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func checkPerm(info os.FileInfo) error {
+	// Windows does not have unix permission bits.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("%w: %s is %#o, want 0600", ErrInsecureFile, info.Name(), perm)
+	}
+	return nil
+}
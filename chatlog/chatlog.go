@@ -0,0 +1,182 @@
+// Package chatlog stores Jam Session chat history on disk and exports transcripts.
+package chatlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/config"
+)
+
+// MaxEntries is the number of most recent entries kept when loading history.
+const MaxEntries = 500
+
+// Longest line read from a history file. Longer ones are skipped.
+const maxLine = 1 << 20
+
+// Export formats.
+const (
+	Markdown Format = "md"
+	JSON     Format = "json"
+)
+
+type (
+	Entry struct {
+		// Message identifier
+		ID uuid.UUID `json:"id"`
+		// Sender's user ID. Empty for system lines.
+		SenderID uuid.UUID `json:"senderId,omitempty"`
+		// Sender's display name at the time of sending.
+		Sender string `json:"sender,omitempty"`
+		// Sender's display color.
+		Color string    `json:"color,omitempty"`
+		Time  time.Time `json:"time"`
+		Body  string    `json:"body"`
+		// System lines, ex: joins and leaves, have no sender.
+		System bool `json:"system,omitempty"`
+		// Sent with /me, ex: "* Jeff dances".
+		Action bool `json:"action,omitempty"`
+		// Recipient of a direct message. Empty for messages to the whole room.
+		To     uuid.UUID `json:"to,omitempty"`
+		ToName string    `json:"toName,omitempty"`
+	}
+
+	Format string
+)
+
+// IsDirect reports whether the entry is a direct message.
+func (e Entry) IsDirect() bool {
+	return e.To != uuid.Nil
+}
+
+// Path returns the history file for the given Jam Session in the rmxtui config directory.
+func Path(roomID string) (string, error) {
+	return config.Path("history", config.FileName(roomID)+".jsonl")
+}
+
+// Append adds the entry to the end of the history file at path.
+func Append(path string, e Entry) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		f.Close()
+		return fmt.Errorf("encode entry: %w", err)
+	}
+	return f.Close()
+}
+
+// Load reads the most recent MaxEntries entries from the history file at path.
+// A missing file is an empty history.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]Entry, 0)
+	r := bufio.NewReader(f)
+	for {
+		line, err := readLine(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// Skip lines cut short by a crash, or too long to read, rather than losing the whole
+			// history.
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) > MaxEntries {
+		entries = entries[len(entries)-MaxEntries:]
+	}
+	return entries, nil
+}
+
+// ReadLine reads the next line, or as much of it as fits in maxLine. It returns io.EOF once
+// there are no more lines.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line) < maxLine {
+			line = append(line, chunk...)
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && len(line) > 0:
+			return line, nil
+		case err != nil:
+			return nil, err
+		}
+		return line, nil
+	}
+}
+
+// ParseFormat returns the export format for a name like "md", "markdown" or "json".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "", "md", "markdown":
+		return Markdown, nil
+	case "json":
+		return JSON, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, want md or json", name)
+	}
+}
+
+// Export writes the transcript to w in the given format.
+func Export(w io.Writer, format Format, title string, entries []Entry) error {
+	switch format {
+	case Markdown:
+		return writeMarkdown(w, title, entries)
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func writeMarkdown(w io.Writer, title string, entries []Entry) error {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	for _, e := range entries {
+		ts := e.Time.Format("2006-01-02 15:04:05")
+		if e.System {
+			fmt.Fprintf(&b, "- _%s · %s_\n", ts, e.Body)
+			continue
+		}
+		if e.Action {
+			fmt.Fprintf(&b, "- `%s` _* %s %s_\n", ts, e.Sender, e.Body)
+			continue
+		}
+		if e.IsDirect() {
+			fmt.Fprintf(&b, "- `%s` **%s** → **%s** (DM): %s\n", ts, e.Sender, e.ToName, e.Body)
+			continue
+		}
+		fmt.Fprintf(&b, "- `%s` **%s**: %s\n", ts, e.Sender, e.Body)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
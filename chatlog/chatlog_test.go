@@ -0,0 +1,132 @@
+package chatlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatlog"
+	"github.com/rapidmidiex/rmxtui/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "room.jsonl")
+	at := time.Date(2023, 2, 4, 18, 30, 5, 0, time.UTC)
+
+	t.Run("missing history is empty", func(t *testing.T) {
+		got, err := chatlog.Load(path)
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	t.Run("reloads appended entries in order", func(t *testing.T) {
+		want := []chatlog.Entry{
+			{ID: uuid.New(), SenderID: uuid.New(), Sender: "Jeff", Color: "#7D56F4", Time: at, Body: "hi"},
+			{ID: uuid.New(), Time: at.Add(time.Second), Body: "Jen joined the jam", System: true},
+		}
+		for _, e := range want {
+			require.NoError(t, chatlog.Append(path, e))
+		}
+
+		got, err := chatlog.Load(path)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("skips corrupt lines", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"id":"trunc`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		got, err := chatlog.Load(path)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+	})
+
+	t.Run("reads long messages and skips ones too long to read", func(t *testing.T) {
+		long := filepath.Join(t.TempDir(), "long.jsonl")
+		want := []chatlog.Entry{
+			{ID: uuid.New(), Time: at, Body: strings.Repeat("la", 100_000)},
+			{ID: uuid.New(), Time: at, Body: "after"},
+		}
+		require.NoError(t, chatlog.Append(long, want[0]))
+		require.NoError(t, chatlog.Append(long, chatlog.Entry{ID: uuid.New(), Time: at, Body: strings.Repeat("x", 2<<20)}))
+		require.NoError(t, chatlog.Append(long, want[1]))
+
+		got, err := chatlog.Load(long)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("keeps only the most recent entries", func(t *testing.T) {
+		long := filepath.Join(t.TempDir(), "long.jsonl")
+		for i := 0; i < chatlog.MaxEntries+10; i++ {
+			require.NoError(t, chatlog.Append(long, chatlog.Entry{ID: uuid.New(), Time: at, Body: "spam"}))
+		}
+		got, err := chatlog.Load(long)
+		require.NoError(t, err)
+		require.Len(t, got, chatlog.MaxEntries)
+	})
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir, err := config.Dir()
+	require.NoError(t, err)
+
+	path, err := chatlog.Path("3f2c8d4e-0d9a-4c57-9a8e-5b1f0c6d7e21")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "history", "3f2c8d4e-0d9a-4c57-9a8e-5b1f0c6d7e21.jsonl"), path)
+
+	// A room ID from the server can't reach outside the history directory.
+	path, err = chatlog.Path("../../escape")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "history", "escape.jsonl"), path)
+}
+
+func TestExport(t *testing.T) {
+	at := time.Date(2023, 2, 4, 18, 30, 5, 0, time.UTC)
+	entries := []chatlog.Entry{
+		{ID: uuid.New(), Sender: "Jeff", Time: at, Body: "ready?"},
+		{ID: uuid.New(), Time: at.Add(time.Minute), Body: "Jen left the jam", System: true},
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, chatlog.Export(&buf, chatlog.Markdown, "RMX chat", entries))
+		require.Equal(t, "# RMX chat\n\n"+
+			"- `2023-02-04 18:30:05` **Jeff**: ready?\n"+
+			"- _2023-02-04 18:31:05 · Jen left the jam_\n", buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, chatlog.Export(&buf, chatlog.JSON, "RMX chat", entries))
+		var got []chatlog.Entry
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+		require.Equal(t, entries, got)
+	})
+
+	t.Run("parses format names", func(t *testing.T) {
+		for name, want := range map[string]chatlog.Format{
+			"":         chatlog.Markdown,
+			"md":       chatlog.Markdown,
+			"Markdown": chatlog.Markdown,
+			".json":    chatlog.JSON,
+		} {
+			got, err := chatlog.ParseFormat(name)
+			require.NoError(t, err, name)
+			require.Equal(t, want, got, name)
+		}
+		_, err := chatlog.ParseFormat("pdf")
+		require.Error(t, err)
+	})
+}
@@ -2,40 +2,127 @@ package chatui
 
 import (
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatlog"
+	"github.com/rapidmidiex/rmxtui/config"
 	"github.com/rapidmidiex/rmxtui/rmxerr"
 )
 
 // Reference:
 // https://github.com/charmbracelet/bubbletea/blob/master/examples/chat/main.go
 
+const (
+	chatWidth  = 30
+	chatHeight = 5
+	// Rows taken up by the rest of the app and the chat title around the history view.
+	historyChrome = 9
+)
+
+var (
+	historyKey = key.NewBinding(
+		key.WithKeys("ctrl+l"),
+		key.WithHelp("ctrl+l", "toggle chat history"),
+	)
+	threadKey = key.NewBinding(
+		key.WithKeys("ctrl+t"),
+		key.WithHelp("ctrl+t", "cycle direct messages"),
+	)
+)
+
 type (
 	ToggleFocusMsg struct{}
 
+	// JoinedMsg loads the chat history of the Jam Session the user just joined.
+	JoinedMsg struct {
+		RoomID string
+		// Local user, used to tell our own messages apart in the history.
+		UserID uuid.UUID
+	}
+
 	SendMsg struct {
 		Msg string
 	}
 	RecvTextMsg struct {
 		ID          uuid.UUID
+		SenderID    uuid.UUID
 		DisplayName string
 		Msg         string
 		FromSelf    bool
+		// Sender's display color. Falls back to the default recipient style when empty.
+		Color string
+		// Time the message was sent. Defaults to the time it was received.
+		SentAt time.Time
+		// Sent with /me
+		Action bool
+	}
+	// InfoMsg is a system line shown in the chat, ex: "Jeff is now known as DJ Jeff".
+	InfoMsg struct {
+		Msg string
+	}
+	// NoticeMsg is feedback for the local user, ex: the result of a command. It is not saved to the history.
+	NoticeMsg struct {
+		Msg string
+	}
+
+	historyLoadedMsg struct {
+		roomID  string
+		entries []chatlog.Entry
 	}
 )
 
 type model struct {
 	viewport       viewport.Model
-	messages       []string
+	entries        []chatlog.Entry
 	textarea       textarea.Model
 	senderStyle    lipgloss.Style
 	recipientStyle lipgloss.Style
+	infoStyle      lipgloss.Style
+	timeStyle      lipgloss.Style
+	directStyle    lipgloss.Style
+	mentionStyle   lipgloss.Style
+	titleStyle     lipgloss.Style
+	flashStyle     lipgloss.Style
 	err            error
+
+	roomID   string
+	selfID   uuid.UUID
+	selfName string
+	// Matches @mentions of selfName.
+	mention *regexp.Regexp
+	// On-disk history of the current Jam Session. Empty until its history has loaded.
+	historyPath string
+	// Entries received before the history path was known, saved once it is.
+	pending []chatlog.Entry
+	// Entries shown but never saved, ex: command feedback, left out of exports.
+	unsaved map[uuid.UUID]bool
+	// Full height, scrollable history view.
+	showHistory bool
+	// Terminal height, used to size the history view.
+	height int
+
+	// Display names offered by tab-completion.
+	users []string
+	// Active tab-completion, if any.
+	completion *completion
+
+	// Direct message thread being shown instead of the room chat, if any.
+	dmThread *thread
+	// Messages and mentions received while the chat was out of focus.
+	unread   int
+	mentions int
+	// Title is highlighted after an alert, and rings the terminal bell while it's drawn.
+	flash bool
+	bell  bool
 }
 
 func New() model {
@@ -46,7 +133,7 @@ func New() model {
 	ta.Prompt = "┃ "
 	ta.CharLimit = 280
 
-	ta.SetWidth(30)
+	ta.SetWidth(chatWidth)
 	ta.SetHeight(3)
 
 	// Remove cursor line styling
@@ -54,7 +141,7 @@ func New() model {
 
 	ta.ShowLineNumbers = false
 
-	vp := viewport.New(30, 5)
+	vp := viewport.New(chatWidth, chatHeight)
 	vp.SetContent(`RMX Chat
 Type a message and press Enter to send.`)
 
@@ -62,10 +149,17 @@ Type a message and press Enter to send.`)
 
 	return model{
 		textarea:       ta,
-		messages:       []string{},
+		entries:        []chatlog.Entry{},
+		unsaved:        map[uuid.UUID]bool{},
 		viewport:       vp,
 		senderStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
 		recipientStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		infoStyle:      lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Italic(true),
+		timeStyle:      lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+		directStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
+		mentionStyle:   lipgloss.NewStyle().Bold(true).Reverse(true),
+		titleStyle:     lipgloss.NewStyle().Bold(true),
+		flashStyle:     lipgloss.NewStyle().Bold(true).Reverse(true),
 		err:            nil,
 	}
 }
@@ -82,8 +176,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vpCmd tea.Cmd
 	)
 
-	m.textarea, tiCmd = m.textarea.Update(msg)
-	m.viewport, vpCmd = m.viewport.Update(msg)
+	// Keys either type in the textarea, or scroll the history view.
+	keyMsg, isKey := msg.(tea.KeyMsg)
+	if !isKey || !m.showHistory {
+		m.textarea, tiCmd = m.textarea.Update(msg)
+	}
+	if !isKey || m.showHistory {
+		m.viewport, vpCmd = m.viewport.Update(msg)
+	}
 
 	cmds = append(cmds, tiCmd, vpCmd)
 
@@ -93,28 +193,159 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.textarea.Blur()
 		} else {
 			m.textarea.Focus()
+			m.unread, m.mentions = 0, 0
 		}
 
+	case UsersMsg:
+		m.users = msg.Names
+		if m.selfName != msg.Self {
+			m.selfName = msg.Self
+			m.mention = mentionPattern(msg.Self)
+			m.refresh()
+		}
+
+	case flashDoneMsg:
+		m.flash = false
+
+	case bellDoneMsg:
+		m.bell = false
+
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.resize()
+
+	case JoinedMsg:
+		m.roomID = msg.RoomID
+		m.selfID = msg.UserID
+		m.entries = []chatlog.Entry{}
+		m.historyPath = ""
+		m.pending = nil
+		m.unsaved = map[uuid.UUID]bool{}
+		m.dmThread = nil
+		m.unread, m.mentions = 0, 0
+		cmds = append(cmds, loadHistory(msg.RoomID))
+
+	case historyLoadedMsg:
+		if msg.roomID != m.roomID {
+			// Left the room before the history finished loading.
+			break
+		}
+		path, err := chatlog.Path(msg.roomID)
+		if err != nil {
+			cmds = append(cmds, func() tea.Msg { return rmxerr.ErrMsg{Err: err} })
+			break
+		}
+		m.historyPath = path
+		// Anything received while loading goes after the history, on screen and on disk.
+		m.entries = append(msg.entries, m.entries...)
+		cmds = append(cmds, saveEntries(path, m.pending))
+		m.pending = nil
+		m.refresh()
+
 	case RecvTextMsg:
-		if msg.FromSelf {
-			textMsg := fmt.Sprintf("You: %s", msg.Msg)
-			m.messages = append(m.messages, m.senderStyle.Render(textMsg))
-		} else {
-			// Message from others
-			textMsg := fmt.Sprintf("%s: %s", msg.DisplayName, msg.Msg)
-			m.messages = append(m.messages, m.recipientStyle.Render(textMsg))
+		e := chatlog.Entry{
+			ID:       msg.ID,
+			SenderID: msg.SenderID,
+			Sender:   msg.DisplayName,
+			Color:    msg.Color,
+			Time:     msg.SentAt,
+			Body:     msg.Msg,
+			Action:   msg.Action,
+		}
+		if msg.FromSelf && e.SenderID == uuid.Nil {
+			e.SenderID = m.selfID
+		}
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		cmds = append(cmds, m.addEntry(e))
+
+		if !msg.FromSelf {
+			_, _, mentioned := findMention(msg.Msg, m.mention)
+			if !m.textarea.Focused() {
+				m.unread++
+				if mentioned {
+					m.mentions++
+				}
+			}
+			if mentioned {
+				cmds = append(cmds, m.alert())
+			}
+		}
+
+	case RecvDirectMsg:
+		e := chatlog.Entry{
+			ID:       msg.ID,
+			SenderID: msg.SenderID,
+			Sender:   msg.DisplayName,
+			Color:    msg.Color,
+			Time:     msg.SentAt,
+			Body:     msg.Msg,
+			To:       msg.To,
+			ToName:   msg.ToName,
+		}
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		cmds = append(cmds, m.addEntry(e))
+
+		// Direct messages always deserve attention.
+		if !msg.FromSelf {
+			if !m.textarea.Focused() {
+				m.unread++
+				m.mentions++
+			}
+			cmds = append(cmds, m.alert())
 		}
-		m.viewport.SetContent(strings.Join(m.messages, "\n"))
-		m.viewport.GotoBottom()
+
+	case InfoMsg:
+		cmds = append(cmds, m.addEntry(chatlog.Entry{
+			ID:     uuid.New(),
+			Time:   time.Now(),
+			Body:   msg.Msg,
+			System: true,
+		}))
+
+	case NoticeMsg:
+		m.showEntry(chatlog.Entry{
+			ID:     uuid.New(),
+			Time:   time.Now(),
+			Body:   msg.Msg,
+			System: true,
+		})
 
 	case tea.KeyMsg:
+		if key.Matches(keyMsg, historyKey) {
+			m.showHistory = !m.showHistory
+			m.resize()
+			break
+		}
+		if key.Matches(keyMsg, threadKey) {
+			m.nextThread()
+			break
+		}
+		if m.showHistory {
+			break
+		}
+
+		if msg.Type == tea.KeyTab {
+			m.complete()
+			break
+		}
+		m.completion = nil
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			fmt.Println(m.textarea.Value())
 			return m, tea.Quit
 		case tea.KeyEnter:
-			m.viewport.SetContent(strings.Join(m.messages, "\n"))
-			cmds = append(cmds, m.send)
+			if command, ok := ParseCommand(m.textarea.Value()); ok {
+				cmds = append(cmds, m.runCommand(command))
+			} else if m.dmThread != nil {
+				cmds = append(cmds, m.sendDirect)
+			} else {
+				cmds = append(cmds, m.send)
+			}
 
 			m.textarea.Reset()
 			m.viewport.GotoBottom()
@@ -130,13 +361,250 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
+	if m.showHistory {
+		title := m.timeStyle.Render(fmt.Sprintf("Chat history (%d) · ↑/↓ pgup/pgdn scroll · %s close", len(m.entries), historyKey.Help().Key))
+		return fmt.Sprintf("%s\n%s", title, m.viewport.View()) + "\n\n"
+	}
+	hint := ""
+	if m.completion != nil && len(m.completion.candidates) > 1 {
+		hint = "\n" + m.timeStyle.Render(strings.Join(m.completion.candidates, " "))
+	}
 	return fmt.Sprintf(
-		"%s\n\n%s",
+		"%s\n%s\n\n%s%s",
+		m.title(),
 		m.viewport.View(),
 		m.textarea.View(),
+		hint,
 	) + "\n\n"
 }
 
+// Completing reports whether tab would complete the chat input, rather than move focus away from the chat.
+func (m model) Completing() bool {
+	if !m.textarea.Focused() || m.showHistory {
+		return false
+	}
+	if m.completion != nil {
+		return true
+	}
+	_, candidates := completionCandidates(m.textarea.Value(), m.users)
+	return len(candidates) > 0
+}
+
+// Complete replaces the word being typed with the next completion candidate.
+func (m *model) complete() {
+	if m.completion == nil {
+		head, candidates := completionCandidates(m.textarea.Value(), m.users)
+		if len(candidates) == 0 {
+			return
+		}
+		m.completion = &completion{head: head, candidates: candidates, idx: -1}
+	}
+	c := m.completion
+	c.idx = (c.idx + 1) % len(c.candidates)
+	m.textarea.SetValue(c.head + c.candidates[c.idx])
+	if len(c.candidates) == 1 {
+		// Nothing to cycle through, let the next tab complete the following word.
+		m.completion = nil
+	}
+}
+
+// RunCommand handles the chat's own commands and forwards the rest.
+func (m *model) runCommand(c CommandMsg) tea.Cmd {
+	switch c.Name {
+	case "help":
+		for _, line := range HelpLines() {
+			m.showEntry(chatlog.Entry{ID: uuid.New(), Time: time.Now(), Body: line, System: true})
+		}
+		return nil
+	case "export":
+		return m.export(c.Args)
+	}
+
+	if _, ok := LookupCommand(c.Name); !ok {
+		m.showEntry(chatlog.Entry{
+			ID:     uuid.New(),
+			Time:   time.Now(),
+			Body:   fmt.Sprintf("Unknown command /%s. Type /help for a list of commands.", c.Name),
+			System: true,
+		})
+		return nil
+	}
+	return func() tea.Msg { return c }
+}
+
 func (m model) send() tea.Msg {
 	return SendMsg{Msg: m.textarea.Value()}
 }
+
+func (m model) sendDirect() tea.Msg {
+	return SendDirectMsg{
+		To:     m.dmThread.peerID,
+		ToName: m.dmThread.peerName,
+		Msg:    m.textarea.Value(),
+	}
+}
+
+// AddEntry shows the entry in the chat and appends it to the on-disk history. Until the history
+// has loaded, the entry waits to be saved after it.
+func (m *model) addEntry(e chatlog.Entry) tea.Cmd {
+	m.appendEntry(e)
+	if m.historyPath == "" {
+		m.pending = append(m.pending, e)
+		return nil
+	}
+	return saveEntries(m.historyPath, []chatlog.Entry{e})
+}
+
+// ShowEntry shows the entry in the chat without saving it, ex: command feedback.
+func (m *model) showEntry(e chatlog.Entry) {
+	m.unsaved[e.ID] = true
+	m.appendEntry(e)
+}
+
+func (m *model) appendEntry(e chatlog.Entry) {
+	m.entries = append(m.entries, e)
+	if len(m.entries) > chatlog.MaxEntries {
+		for _, old := range m.entries[:len(m.entries)-chatlog.MaxEntries] {
+			delete(m.unsaved, old.ID)
+		}
+		m.entries = m.entries[len(m.entries)-chatlog.MaxEntries:]
+	}
+	m.refresh()
+}
+
+func saveEntries(path string, entries []chatlog.Entry) tea.Cmd {
+	if len(entries) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		for _, e := range entries {
+			if err := chatlog.Append(path, e); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("save chat history: %w", err)}
+			}
+		}
+		return nil
+	}
+}
+
+// Refresh re-renders the entries into the viewport and scrolls to the latest message.
+func (m *model) refresh() {
+	lines := make([]string, 0, len(m.entries))
+	for _, e := range m.entries {
+		if m.visible(e) {
+			lines = append(lines, m.renderEntry(e))
+		}
+	}
+	wrap := lipgloss.NewStyle().Width(m.viewport.Width)
+	m.viewport.SetContent(wrap.Render(strings.Join(lines, "\n")))
+	m.viewport.GotoBottom()
+}
+
+// Resize switches the viewport between the compact chat and full height history view.
+func (m *model) resize() {
+	m.viewport.Width = chatWidth
+	m.viewport.Height = chatHeight
+	if m.showHistory {
+		m.viewport.Width = chatWidth * 2
+		m.viewport.Height = chatHeight * 3
+		if m.height > historyChrome+chatHeight {
+			m.viewport.Height = m.height - historyChrome
+		}
+	}
+	m.refresh()
+}
+
+func (m model) renderEntry(e chatlog.Entry) string {
+	ts := m.timeStyle.Render(formatTime(e.Time))
+	if e.System {
+		return ts + " " + m.infoStyle.Render("* "+e.Body)
+	}
+	if e.Action {
+		style := m.recipientStyle
+		if e.Color != "" {
+			style = style.Copy().Foreground(lipgloss.Color(e.Color))
+		}
+		return ts + " " + style.Copy().Italic(true).Render(fmt.Sprintf("* %s %s", e.Sender, e.Body))
+	}
+	fromSelf := e.SenderID != uuid.Nil && e.SenderID == m.selfID
+	if e.IsDirect() {
+		if fromSelf {
+			return ts + " " + m.directStyle.Render(fmt.Sprintf("You → %s: %s", e.ToName, e.Body))
+		}
+		return ts + " " + m.directStyle.Render(fmt.Sprintf("%s → you: ", e.Sender)) + m.renderBody(e.Body, m.directStyle)
+	}
+	if fromSelf {
+		return ts + " " + m.senderStyle.Render(fmt.Sprintf("You: %s", e.Body))
+	}
+	// Message from others
+	style := m.recipientStyle
+	if e.Color != "" {
+		style = style.Copy().Foreground(lipgloss.Color(e.Color))
+	}
+	return ts + " " + style.Render(e.Sender+": ") + m.renderBody(e.Body, style)
+}
+
+// FormatTime shows the time of day for today's messages, and the date for older ones.
+func formatTime(t time.Time) string {
+	t = t.Local()
+	y, mo, d := t.Date()
+	ny, nmo, nd := time.Now().Date()
+	if y == ny && mo == nmo && d == nd {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
+func loadHistory(roomID string) tea.Cmd {
+	return func() tea.Msg {
+		path, err := chatlog.Path(roomID)
+		if err != nil {
+			return rmxerr.ErrMsg{Err: err}
+		}
+		entries, err := chatlog.Load(path)
+		if err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("load chat history: %w", err)}
+		}
+		return historyLoadedMsg{roomID: roomID, entries: entries}
+	}
+}
+
+// Export writes the transcript of the current Jam Session to a file.
+func (m model) export(args []string) tea.Cmd {
+	var formatName, path string
+	if len(args) > 0 {
+		formatName = args[0]
+	}
+	if len(args) > 1 {
+		path = args[1]
+	}
+	format, err := chatlog.ParseFormat(formatName)
+	if err != nil {
+		return func() tea.Msg { return rmxerr.ErrMsg{Err: fmt.Errorf("/export: %w", err)} }
+	}
+	if path == "" {
+		path = fmt.Sprintf("rmx-chat-%s-%s.%s", config.FileName(m.roomID), time.Now().Format("20060102-150405"), format)
+	}
+
+	// Only what's in the history, not command feedback.
+	entries := make([]chatlog.Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		if !m.unsaved[e.ID] {
+			entries = append(entries, e)
+		}
+	}
+	title := "RMX chat · " + m.roomID
+	return func() tea.Msg {
+		f, err := os.Create(path)
+		if err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("/export: %w", err)}
+		}
+		if err := chatlog.Export(f, format, title, entries); err != nil {
+			f.Close()
+			return rmxerr.ErrMsg{Err: fmt.Errorf("/export: %w", err)}
+		}
+		if err := f.Close(); err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("/export: %w", err)}
+		}
+		return NoticeMsg{Msg: fmt.Sprintf("Exported %d messages to %s", len(entries), path)}
+	}
+}
@@ -0,0 +1,157 @@
+package chatui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// CommandMsg is a slash command typed in the chat, ex: "/octave 5".
+	// Commands handled by the chat itself, like /help and /export, are not sent.
+	CommandMsg struct {
+		Name string
+		Args []string
+		// Everything after the command name, with the original spacing.
+		Raw string
+	}
+
+	// UsersMsg updates the display names offered by tab-completion.
+	UsersMsg struct {
+		// Local user's display name, highlighted when mentioned.
+		Self  string
+		Names []string
+	}
+
+	Command struct {
+		Name  string
+		Usage string
+		Help  string
+		// Complete usernames for the first argument.
+		TakesUser bool
+	}
+
+	// Completion cycles through the candidates for the word being completed.
+	completion struct {
+		// Input up to the start of the word being completed.
+		head       string
+		candidates []string
+		idx        int
+	}
+)
+
+// Commands available in the chat.
+var Commands = []Command{
+	{Name: "help", Usage: "/help", Help: "list chat commands"},
+	{Name: "nick", Usage: "/nick <name>", Help: "change your display name"},
+	{Name: "me", Usage: "/me <action>", Help: "describe what you're doing"},
+	{Name: "dm", Usage: "/dm <user> <message>", Help: "send a private message, if the server keeps them private", TakesUser: true},
+	{Name: "transpose", Usage: "/transpose <semitones>", Help: "shift the notes you play, ex: -2"},
+	{Name: "octave", Usage: "/octave <n|+|->", Help: "move the piano to another octave"},
+	{Name: "tempo", Usage: "/tempo [bpm] [beats/unit]", Help: "set the jam tempo and time signature, ex: 90 3/4"},
+	{Name: "play", Usage: "/play", Help: "start the jam's playback on the next downbeat"},
+	{Name: "stop", Usage: "/stop", Help: "stop the jam's playback"},
+	{Name: "locate", Usage: "/locate <bar>", Help: "move the jam's song position to a bar"},
+	{Name: "pattern", Usage: "/pattern <new|save|load|list> [name]", Help: "manage drum machine patterns"},
+	{Name: "key", Usage: "/key [<root> [scale]|off]", Help: "set the jam's key, ex: D dorian"},
+	{Name: "scale", Usage: "/scale [<root> [scale]|off|jam]", Help: "lock your piano to a scale"},
+	{Name: "quantize", Usage: "/quantize [1/16|1/8t|off] [swing %] [strength %]", Help: "hold the notes you play back to the room's grid"},
+	{Name: "roll", Usage: "/roll [pause]", Help: "show, hide or pause the piano roll"},
+	{Name: "perform", Usage: "/perform [single|chord|strum|arp] [maj|min|7|maj7|min7] [up|down|random] [1/16] [30ms]", Help: "play chords, strums or arpeggios from one key"},
+	{Name: "loop", Usage: "/loop <rec [bars]|dub|undo|clear>", Help: "record and layer a loop of what you play"},
+	{Name: "metronome", Usage: "/metronome", Help: "turn the metronome click on or off"},
+	{Name: "countin", Usage: "/countin [bars]", Help: "click a count-in from the next downbeat"},
+	{Name: "mute", Usage: "/mute <user>", Help: "mute or unmute a player's notes", TakesUser: true},
+	{Name: "instrument", Usage: "/instrument <number|name>", Help: "pick a General MIDI instrument"},
+	{Name: "synth", Usage: "/synth [preset|off]", Help: "play a built-in synth preset instead of your instrument"},
+	{Name: "record", Usage: "/record", Help: "start or stop recording the jam's notes"},
+	{Name: "capture", Usage: "/capture [wav|flac]", Help: "start or stop recording what you hear to a file"},
+	{Name: "scope", Usage: "/scope [wave|spectrum]", Help: "show or hide the output meter, or pick what its scope draws"},
+	{Name: "export", Usage: "/export [md|json] [path]", Help: "save the chat transcript"},
+}
+
+// ParseCommand splits a chat input like "/mute Jeff" into a command.
+// It reports false if the input is not a command.
+func ParseCommand(input string) (CommandMsg, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") || len(input) < 2 {
+		return CommandMsg{}, false
+	}
+	name, raw, _ := strings.Cut(input[1:], " ")
+	return CommandMsg{
+		Name: strings.ToLower(name),
+		Args: strings.Fields(raw),
+		Raw:  strings.TrimSpace(raw),
+	}, true
+}
+
+// LookupCommand finds a command by name.
+func LookupCommand(name string) (Command, bool) {
+	for _, c := range Commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Command{}, false
+}
+
+// HelpLines describes every command, one per line.
+func HelpLines() []string {
+	lines := make([]string, 0, len(Commands))
+	for _, c := range Commands {
+		lines = append(lines, fmt.Sprintf("%s · %s", c.Usage, c.Help))
+	}
+	return lines
+}
+
+// CompletionCandidates returns the completions for the last word of the input,
+// along with the input before that word.
+func completionCandidates(input string, users []string) (string, []string) {
+	wordStart := strings.LastIndex(input, " ") + 1
+	head, word := input[:wordStart], input[wordStart:]
+
+	var candidates []string
+	switch {
+	// Command name
+	case wordStart == 0 && strings.HasPrefix(word, "/"):
+		for _, c := range Commands {
+			if strings.HasPrefix("/"+c.Name, strings.ToLower(word)) {
+				candidates = append(candidates, "/"+c.Name+" ")
+			}
+		}
+
+	// @mention anywhere in the message
+	case strings.HasPrefix(word, "@"):
+		for _, u := range matchUsers(users, word[1:]) {
+			candidates = append(candidates, "@"+u+" ")
+		}
+
+	// Username argument
+	default:
+		cmd, ok := ParseCommand(head)
+		if !ok || len(cmd.Args) > 0 {
+			break
+		}
+		if c, ok := LookupCommand(cmd.Name); ok && c.TakesUser {
+			for _, u := range matchUsers(users, word) {
+				candidates = append(candidates, u+" ")
+			}
+		}
+	}
+	return head, candidates
+}
+
+func matchUsers(users []string, prefix string) []string {
+	matches := make([]string, 0)
+	for _, u := range users {
+		// Names with spaces can't be completed as a single word.
+		if strings.Contains(u, " ") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(u), strings.ToLower(prefix)) {
+			matches = append(matches, u)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,54 @@
+package chatui_test
+
+import (
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		want  chatui.CommandMsg
+		isCmd bool
+	}{
+		{input: "hello", isCmd: false},
+		{input: "/", isCmd: false},
+		{input: "  /help ", want: chatui.CommandMsg{Name: "help", Args: []string{}}, isCmd: true},
+		{input: "/OCTAVE +", want: chatui.CommandMsg{Name: "octave", Args: []string{"+"}, Raw: "+"}, isCmd: true},
+		{
+			input: "/me plays  a sick solo",
+			want:  chatui.CommandMsg{Name: "me", Args: []string{"plays", "a", "sick", "solo"}, Raw: "plays  a sick solo"},
+			isCmd: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := chatui.ParseCommand(tt.input)
+		require.Equal(t, tt.isCmd, ok, tt.input)
+		if ok {
+			require.Equal(t, tt.want, got, tt.input)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		name string
+		want bool
+	}{
+		{body: "hey @jeff", name: "Jeff", want: true},
+		{body: "@Jeff, take a solo", name: "Jeff", want: true},
+		{body: "nice one @DJ Jeff!", name: "DJ Jeff", want: true},
+		{body: "hey jeff", name: "Jeff", want: false},
+		{body: "hey @jeffrey", name: "Jeff", want: false},
+		{body: "mail jeff@example.com", name: "example", want: false},
+		{body: "hey @", name: "", want: false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, chatui.Mentions(tt.body, tt.name), tt.body)
+	}
+}
@@ -0,0 +1,181 @@
+package chatui
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatlog"
+)
+
+const (
+	// How long the chat title flashes when the user is mentioned.
+	flashDuration = 400 * time.Millisecond
+	// How long the bell stays in the view, long enough to be drawn once.
+	bellDuration = 50 * time.Millisecond
+)
+
+type (
+	// RecvDirectMsg is a direct message from, or to, the local user.
+	RecvDirectMsg struct {
+		ID          uuid.UUID
+		SenderID    uuid.UUID
+		DisplayName string
+		Color       string
+		To          uuid.UUID
+		ToName      string
+		Msg         string
+		FromSelf    bool
+		SentAt      time.Time
+	}
+
+	// SendDirectMsg is sent when the user types a message in a direct message thread.
+	SendDirectMsg struct {
+		To     uuid.UUID
+		ToName string
+		Msg    string
+	}
+
+	flashDoneMsg struct{}
+	bellDoneMsg  struct{}
+
+	// Thread is a direct message conversation with another user.
+	thread struct {
+		peerID   uuid.UUID
+		peerName string
+	}
+)
+
+// Mentions reports whether the body @mentions name, ignoring case.
+func Mentions(body, name string) bool {
+	_, _, ok := findMention(body, mentionPattern(name))
+	return ok
+}
+
+// MentionPattern matches "@name" as a whole word, so neither "@jeffrey" nor "me@jeff" mention
+// "jeff". It's nil for no name.
+func mentionPattern(name string) *regexp.Regexp {
+	if name == "" {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])(@` + regexp.QuoteMeta(name) + `)(?:[^\p{L}\p{N}_-]|$)`)
+}
+
+// FindMention returns the byte range of the first mention in body.
+func findMention(body string, re *regexp.Regexp) (int, int, bool) {
+	if re == nil {
+		return 0, 0, false
+	}
+	loc := re.FindStringSubmatchIndex(body)
+	if loc == nil {
+		return 0, 0, false
+	}
+	return loc[2], loc[3], true
+}
+
+// RenderBody styles a message body, highlighting mentions of the local user.
+func (m model) renderBody(body string, style lipgloss.Style) string {
+	start, end, ok := findMention(body, m.mention)
+	if !ok {
+		return style.Render(body)
+	}
+	return style.Render(body[:start]) +
+		m.mentionStyle.Render(body[start:end]) +
+		m.renderBody(body[end:], style)
+}
+
+// Threads lists the direct message conversations, oldest first.
+func (m model) threads() []thread {
+	threads := make([]thread, 0)
+	seen := make(map[uuid.UUID]bool)
+	for _, e := range m.entries {
+		if !e.IsDirect() {
+			continue
+		}
+		t := thread{peerID: e.SenderID, peerName: e.Sender}
+		if e.SenderID == m.selfID {
+			t = thread{peerID: e.To, peerName: e.ToName}
+		}
+		if seen[t.peerID] {
+			continue
+		}
+		seen[t.peerID] = true
+		threads = append(threads, t)
+	}
+	return threads
+}
+
+// NextThread cycles from the room chat through each direct message thread and back.
+func (m *model) nextThread() {
+	threads := m.threads()
+	if m.dmThread == nil {
+		if len(threads) > 0 {
+			m.dmThread = &threads[0]
+		}
+		m.refresh()
+		return
+	}
+	for i, t := range threads {
+		if t.peerID == m.dmThread.peerID && i+1 < len(threads) {
+			m.dmThread = &threads[i+1]
+			m.refresh()
+			return
+		}
+	}
+	m.dmThread = nil
+	m.refresh()
+}
+
+// Visible reports whether the entry belongs in the current view.
+// The room view shows everything, a thread only shows its direct messages.
+func (m model) visible(e chatlog.Entry) bool {
+	if m.dmThread == nil {
+		return true
+	}
+	peer := m.dmThread.peerID
+	return e.IsDirect() && (e.To == peer || e.SenderID == peer)
+}
+
+// Alert flashes the chat title and rings the terminal bell once.
+func (m *model) alert() tea.Cmd {
+	m.flash, m.bell = true, true
+	return tea.Batch(
+		tea.Tick(flashDuration, func(time.Time) tea.Msg { return flashDoneMsg{} }),
+		tea.Tick(bellDuration, func(time.Time) tea.Msg { return bellDoneMsg{} }),
+	)
+}
+
+// Title names the current view and counts what the user missed while the chat was out of focus.
+func (m model) title() string {
+	title := "Chat"
+	if m.dmThread != nil {
+		title = "DM with " + m.dmThread.peerName
+	}
+	if m.unread > 0 {
+		title += fmt.Sprintf(" · %d unread", m.unread)
+	}
+	if m.mentions > 0 {
+		title += " · " + pluralize(m.mentions, "mention", "mentions")
+	}
+	bell := ""
+	if m.bell {
+		// The bell is part of the view, so it's written with the rest of the title rather than
+		// over the top of Bubble Tea's rendering. It's only there for about a frame, so it rings
+		// once however often the title changes while it flashes.
+		bell = "\a"
+	}
+	if m.flash {
+		return m.flashStyle.Render(title) + bell
+	}
+	return m.titleStyle.Render(title) + bell
+}
+
+func pluralize(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
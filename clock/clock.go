@@ -0,0 +1,53 @@
+// Package clock tells the time. Code that schedules music takes a Clock so tests can control time.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	Clock interface {
+		Now() time.Time
+	}
+
+	wallClock struct{}
+
+	// Fake is a Clock that only moves when told to.
+	Fake struct {
+		mu  sync.Mutex
+		now time.Time
+	}
+)
+
+// Wall is the system clock.
+var Wall Clock = wallClock{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewFake returns a Fake clock stopped at t.
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the clock to t.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
@@ -6,19 +6,44 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/rapidmidiex/rmxtui"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/midi"
 )
 
 var serverVar string
 var debugVar bool
+var loginVar bool
+var audioVar string
+var sampleRateVar string
+var bufferVar string
+var voicesVar int
+var stealVar string
+var synthVar string
+var privateDMVar bool
 
 func init() {
 	flag.StringVar(&serverVar, "server", "https://rmx.fly.dev", "API Server Host")
 	flag.BoolVar(&debugVar, "debug", false, "Debug mode. Write logs to `debug.log` file")
+	flag.BoolVar(&loginVar, "login", false, "Log in to the RMX server before joining a jam")
+	flag.StringVar(&audioVar, "audio", "speaker", "Where the sound goes: speaker, null, or wav:<path> to write a WAV file")
+	flag.StringVar(&sampleRateVar, "sample-rate", "44100", "Sample rate to play at: 44100, 48000 or 96000")
+	flag.StringVar(&bufferVar, "buffer", "auto", "How much sound is handed to the output at a time, ex: 20ms, or auto to tune it as the jam plays")
+
+	flag.IntVar(&voicesVar, "voices", midi.DefaultMaxVoices, "Most notes playing at once")
+	flag.StringVar(&stealVar, "steal", "oldest", "Which note ends to make room for another when all voices are playing: oldest or quietest")
+	flag.StringVar(&synthVar, "synth", "soundfont", "Engine to play notes with: soundfont, or osc for the built-in synth without loading the SoundFont")
+
+	flag.BoolVar(&privateDMVar, "private-dm", false, "The server delivers direct messages only to their recipient. /dm is off without it")
 
 	flag.Parse()
 }
 
 func main() {
+	if flag.Arg(0) == "stems" {
+		runStems(flag.Args()[1:])
+		return
+	}
+
 	if debugVar {
 		f, err := tea.LogToFile("debug.log", "debug")
 		if err != nil {
@@ -27,5 +52,36 @@ func main() {
 		defer f.Close()
 	}
 
-	rmxtui.Run(serverVar, debugVar)
+	sampleRate, err := audio.ParseSampleRate(sampleRateVar)
+	if err != nil {
+		log.Fatal(err)
+	}
+	buffer, autoTune, err := audio.ParseBuffer(bufferVar)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	steal, err := midi.ParseStealPolicy(stealVar)
+	if err != nil {
+		log.Fatal(err)
+	}
+	synth, err := midi.ParseEngine(synthVar)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rmxtui.Run(rmxtui.Config{
+		ServerURL: serverVar,
+		Debug:     debugVar,
+		Login:     loginVar,
+		Audio: audio.Config{
+			Output:     audioVar,
+			SampleRate: sampleRate,
+			Buffer:     buffer,
+			AutoTune:   autoTune,
+		},
+		Voices:    midi.VoicesOpts{Max: voicesVar, Steal: steal},
+		Synth:     synth,
+		PrivateDM: privateDMVar,
+	})
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/session"
+	"github.com/rapidmidiex/rmxtui/stems"
+)
+
+// RunStems renders a /record recording to a WAV per player and a mixdown.
+func runStems(args []string) {
+	fs := flag.NewFlagSet("stems", flag.ExitOnError)
+	out := fs.String("out", "", "Directory to write the WAV files to (default: next to the recording, named after it)")
+	sampleRate := fs.String("sample-rate", "44100", "Sample rate to render at: 44100, 48000 or 96000")
+	engine := fs.String("synth", "soundfont", "Engine to render notes with: soundfont, or osc for the built-in synth")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rmxtui stems [flags] <recording.jsonl>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	sr, err := audio.ParseSampleRate(*sampleRate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	synthEngine, err := midi.ParseEngine(*engine)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dir := *out
+	if dir == "" {
+		dir = strings.TrimSuffix(path, ".jsonl") + "-stems"
+	}
+
+	events, err := session.Load(path)
+	if err != nil {
+		log.Fatalf("load recording: %v", err)
+	}
+	synth, err := midi.NewEngines(midi.NewEnginesOpts{Engine: synthEngine, SampleRate: int(sr)})
+	if err != nil {
+		log.Fatalf("midi.NewEngines: %v", err)
+	}
+	res, err := stems.Render(events, stems.Opts{Synth: synth, Dir: dir})
+	if err != nil {
+		log.Fatalf("render stems: %v", err)
+	}
+
+	for _, s := range res.Stems {
+		fmt.Printf("%s: %d notes -> %s\n", s.Name, s.Notes, s.Path)
+	}
+	fmt.Printf("Mixdown -> %s\n", res.Mixdown)
+	fmt.Printf("%d stems, %s long\n", len(res.Stems), res.Length.Round(time.Millisecond))
+}
@@ -0,0 +1,104 @@
+// Package config locates the files rmxtui keeps on the local machine.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// AppName is the name of the directory rmxtui stores its files in, under the user's config directory.
+const AppName = "rmxtui"
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Dir returns the rmxtui config directory, creating it if it does not exist yet.
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("userConfigDir: %w", err)
+	}
+	dir := filepath.Join(base, AppName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("mkdirAll: %w", err)
+	}
+	return dir, nil
+}
+
+// Path returns the path of a file inside the rmxtui config directory.
+// Any intermediate directories are created.
+func Path(elem ...string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(append([]string{dir}, elem...)...)
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return "", fmt.Errorf("mkdirAll: %w", err)
+	}
+	return p, nil
+}
+
+// FileName makes name safe to use in a file name, for names that come from the server like room
+// IDs. Anything but letters, digits, '-' and '_' is replaced, so the file can't end up outside
+// the config directory.
+func FileName(name string) string {
+	name = strings.Trim(unsafeChars.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		return "unnamed"
+	}
+	return name
+}
+
+// LoadJSON reads the JSON file at path into v. A missing file leaves v as it is.
+func LoadJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unmarshal %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// SaveJSON writes v to path as indented JSON, with WriteFile.
+func SaveJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", filepath.Base(path), err)
+	}
+	return WriteFile(path, b)
+}
+
+// WriteFile atomically replaces the file at path with b, only readable by the current user.
+// It's written to a temp file first, so a crash or another write never leaves a half written
+// file behind.
+func WriteFile(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("createTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0o600); err != nil && runtime.GOOS != "windows" {
+		f.Close()
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	return os.Rename(f.Name(), path)
+}
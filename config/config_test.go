@@ -0,0 +1,53 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileName(t *testing.T) {
+	for name, want := range map[string]string{
+		"3f2c8d4e-0d9a-4c57-9a8e-5b1f0c6d7e21": "3f2c8d4e-0d9a-4c57-9a8e-5b1f0c6d7e21",
+		"../../.ssh/authorized_keys":           "ssh-authorized_keys",
+		`..\..\evil`:                           "evil",
+		"jam room":                             "jam-room",
+		"..":                                   "unnamed",
+		"":                                     "unnamed",
+	} {
+		require.Equal(t, want, config.FileName(name), name)
+	}
+}
+
+func TestSaveJSON(t *testing.T) {
+	type settings struct {
+		Volume float64
+		Mute   bool
+	}
+	path := filepath.Join(t.TempDir(), "settings.json")
+
+	// Nothing saved yet leaves the defaults.
+	got := settings{Volume: -6}
+	require.NoError(t, config.LoadJSON(path, &got))
+	require.Equal(t, settings{Volume: -6}, got)
+
+	// A longer file replaced by a shorter one leaves nothing of it behind.
+	require.NoError(t, config.SaveJSON(path, settings{Volume: -12.5, Mute: true}))
+	require.NoError(t, config.SaveJSON(path, settings{}))
+	got = settings{Volume: -6}
+	require.NoError(t, config.LoadJSON(path, &got))
+	require.Equal(t, settings{}, got)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temp files left over")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"Volume": -3}}`), 0o600))
+	require.Error(t, config.LoadJSON(path, &got))
+}
@@ -0,0 +1,132 @@
+package jamui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/chatui"
+)
+
+// How often the status bar's capture time and size are redrawn.
+const captureRefresh = time.Second
+
+type (
+	// CaptureTickMsg redraws the status bar while the capture is running.
+	captureTickMsg struct {
+		capture *audio.Capture
+	}
+
+	// CaptureStoppedMsg is sent once a capture's file is finished.
+	captureStoppedMsg struct {
+		path    string
+		length  time.Duration
+		size    int64
+		dropped time.Duration
+		err     error
+	}
+)
+
+// CaptureCommand starts or stops recording what you hear to a WAV or FLAC file.
+func (m *model) captureCommand(c chatui.CommandMsg) tea.Cmd {
+	if len(c.Args) > 1 {
+		return m.usage(c)
+	}
+	if m.capture != nil {
+		return m.stopCapture()
+	}
+
+	format := "wav"
+	if len(c.Args) == 1 {
+		format = c.Args[0]
+	}
+	if format != "wav" && format != "flac" {
+		return m.notice("/capture: format should be wav or flac")
+	}
+	path, err := audio.CapturePath(m.ID, m.clock.Now(), format)
+	if err != nil {
+		return m.notice("/capture: %v", err)
+	}
+	w, err := audio.CreateRecording(path, m.sampleRate)
+	if err != nil {
+		return m.notice("/capture: %v", err)
+	}
+	m.capture, m.capturePath = m.tap.Start(w), path
+	return tea.Batch(m.notice("Recording what you hear to %s", path), m.watchCapture())
+}
+
+// StopCapture finishes the capture's file in the background, as the last of it is written.
+func (m *model) stopCapture() tea.Cmd {
+	if m.capture == nil {
+		return nil
+	}
+	c, path := m.capture, m.capturePath
+	m.capture, m.capturePath = nil, ""
+	return func() tea.Msg {
+		err := c.Stop()
+		return captureStoppedMsg{
+			path:    path,
+			length:  m.sampleRate.D(c.Frames()),
+			size:    c.Size(),
+			dropped: m.sampleRate.D(c.Dropped()),
+			err:     err,
+		}
+	}
+}
+
+func (m *model) onCaptureStopped(msg captureStoppedMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.notice("/capture: %v", msg.err)
+	}
+	text := fmt.Sprintf("Saved %s (%s) to %s", formatElapsed(msg.length), formatSize(msg.size), msg.path)
+	if msg.dropped > 0 {
+		text += fmt.Sprintf(", %s was dropped as the disk couldn't keep up", msg.dropped.Round(time.Millisecond))
+	}
+	return m.notice("%s", text)
+}
+
+func (m *model) watchCapture() tea.Cmd {
+	c := m.capture
+	return tea.Tick(captureRefresh, func(time.Time) tea.Msg { return captureTickMsg{capture: c} })
+}
+
+func (m *model) onCaptureTick(msg captureTickMsg) tea.Cmd {
+	if msg.capture != m.capture {
+		return nil
+	}
+	return m.watchCapture()
+}
+
+// Status is what the jam has to say in the status bar: how long the capture has run and how
+// big its file is.
+func (m model) Status() string {
+	if m.capture == nil {
+		return ""
+	}
+	return recStyle.Render(fmt.Sprintf("● CAPTURE %s %s",
+		formatElapsed(m.sampleRate.D(m.capture.Frames())), formatSize(m.capture.Size())))
+}
+
+// Close finishes anything the jam is writing, for when rmxtui quits without leaving the room.
+func (m model) Close() error {
+	if m.capture == nil {
+		return nil
+	}
+	return m.capture.Stop()
+}
+
+// FormatElapsed shows a duration as minutes and seconds, ex: "3:07".
+func formatElapsed(d time.Duration) string {
+	s := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// FormatSize shows a file size in KB or MB, ex: "4.2 MB".
+func formatSize(n int64) string {
+	const kb, mb = 1000, 1000 * 1000
+	if n < mb {
+		return fmt.Sprintf("%d KB", (n+kb/2)/kb)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/mb)
+}
@@ -0,0 +1,429 @@
+package jamui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/looper"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/perform"
+	"github.com/rapidmidiex/rmxtui/profile"
+	"github.com/rapidmidiex/rmxtui/quantize"
+	"github.com/rapidmidiex/rmxtui/sequencer"
+	"github.com/rapidmidiex/rmxtui/session"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/vpiano"
+)
+
+const (
+	maxTranspose = 24
+	minOctave    = vpiano.C1
+	maxOctave    = vpiano.C7
+	maxCountIn   = 4
+)
+
+// RunCommand performs a slash command typed in the chat.
+func (m *model) runCommand(c chatui.CommandMsg) tea.Cmd {
+	switch c.Name {
+	case "nick":
+		name, err := profile.ValidateName(c.Raw)
+		if err != nil {
+			return m.notice("/nick: %v", err)
+		}
+		oldName := m.userName
+		m.userName = name
+		return tea.Batch(
+			m.sendNickMessage(oldName, name),
+			func() tea.Msg { return NickChangedMsg{DisplayName: name} },
+		)
+
+	case "me":
+		if c.Raw == "" {
+			return m.usage(c)
+		}
+		return m.sendTextMessage(c.Raw, true)
+
+	case "dm":
+		if !m.privateDM {
+			return m.notice(dmOff)
+		}
+		user, body, ok := m.roster.findPrefix(strings.TrimPrefix(c.Raw, "@"))
+		if !ok || body == "" {
+			return m.usage(c)
+		}
+		if user.UserID == m.userID {
+			return m.notice("/dm: you can't message yourself")
+		}
+		return m.sendDirectMessage(user.UserID, user.DisplayName, body)
+
+	case "transpose":
+		n, err := strconv.Atoi(strings.TrimPrefix(c.Raw, "+"))
+		if err != nil || n < -maxTranspose || n > maxTranspose {
+			return m.notice("/transpose: semitones must be between -%d and %d", maxTranspose, maxTranspose)
+		}
+		m.transpose = n
+		return m.notice("Transposing by %+d semitones", n)
+
+	case "octave":
+		octave := m.octave
+		switch c.Raw {
+		case "+":
+			octave++
+		case "-":
+			octave--
+		default:
+			n, err := strconv.Atoi(c.Raw)
+			if err != nil {
+				return m.usage(c)
+			}
+			octave = vpiano.Octave(n)
+		}
+		if octave < minOctave || octave > maxOctave {
+			return m.notice("/octave: octave must be between %d and %d", minOctave, maxOctave)
+		}
+		m.setOctave(octave)
+		return m.notice("Piano starts at C%d", octave)
+
+	case "tempo":
+		if len(c.Args) == 0 {
+			return m.notice("Tempo is %d BPM in %s", m.tempo.BPM, m.tempo.Signature)
+		}
+		bpm, sig := m.tempo.BPM, m.tempo.Signature
+		for _, arg := range c.Args {
+			if strings.Contains(arg, "/") {
+				s, err := tempo.ParseSignature(arg)
+				if err != nil {
+					return m.notice("/tempo: %v", err)
+				}
+				sig = s
+				continue
+			}
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return m.usage(c)
+			}
+			if err := tempo.ValidateBPM(n); err != nil {
+				return m.notice("/tempo: %v", err)
+			}
+			bpm = n
+		}
+		m.tempoFrom = m.userID
+		return tea.Batch(
+			m.setClock(tempo.NewClock(bpm, sig, m.clock.Now())),
+			m.sendTempoMessage(),
+			m.notice("Tempo set to %d BPM in %s", bpm, sig),
+		)
+
+	case "play":
+		return m.play()
+
+	case "stop":
+		return m.stop()
+
+	case "locate":
+		bar, err := strconv.Atoi(c.Raw)
+		if err != nil {
+			return m.usage(c)
+		}
+		if bar < 1 {
+			return m.notice("/locate: bars start at 1")
+		}
+		return m.locate(bar)
+
+	case "pattern":
+		return m.patternCommand(c)
+
+	case "loop":
+		return m.loopCommand(c)
+
+	case "key":
+		switch c.Raw {
+		case "":
+			return m.notice("The jam's key is %s", m.roomKey)
+		case "off":
+			return m.setRoomKey(vpiano.Key{})
+		}
+		key, err := vpiano.ParseKey(c.Raw)
+		if err != nil {
+			return m.notice("/key: %v", err)
+		}
+		return m.setRoomKey(key)
+
+	case "scale":
+		switch c.Raw {
+		case "":
+			return m.notice("Piano is locked to %s", m.scale)
+		case "off":
+			m.followKey = false
+			m.setScale(vpiano.Key{})
+			return m.notice("Scale lock off")
+		case "jam":
+			m.followKey = true
+			m.setScale(m.roomKey)
+			return m.notice("Following the jam's key, %s", m.roomKey)
+		}
+		key, err := vpiano.ParseKey(c.Raw)
+		if err != nil {
+			return m.notice("/scale: %v", err)
+		}
+		m.followKey = false
+		m.setScale(key)
+		return m.notice("Piano locked to %s", key)
+
+	case "quantize":
+		if len(c.Args) == 0 {
+			return m.notice("Quantize is %s", m.quantize)
+		}
+		s, err := quantize.Parse(m.quantize, c.Args)
+		if err != nil {
+			return m.notice("/quantize: %v", err)
+		}
+		m.quantize = s
+		m.quantizeOff = 0
+		return m.notice("Quantize %s", s)
+
+	case "roll":
+		switch c.Raw {
+		case "":
+			m.rollHidden = !m.rollHidden
+			if m.rollHidden {
+				return m.notice("Piano roll hidden")
+			}
+			return tea.Batch(m.scrollRoll(), m.notice("Piano roll shown"))
+		case "pause":
+			return m.pauseRoll()
+		}
+		return m.usage(c)
+
+	case "perform":
+		if len(c.Args) == 0 {
+			return m.notice("Playing %s", m.performer.Settings)
+		}
+		s, err := perform.Parse(m.performer.Settings, c.Args)
+		if err != nil {
+			return m.notice("/perform: %v", err)
+		}
+		m.performer.Settings = s
+		return m.notice("Playing %s", s)
+
+	case "metronome":
+		m.metronome = !m.metronome
+		if m.metronome {
+			return m.notice("Metronome on")
+		}
+		return m.notice("Metronome off")
+
+	case "countin":
+		bars := 1
+		if c.Raw != "" {
+			n, err := strconv.Atoi(c.Raw)
+			if err != nil || n < 1 || n > maxCountIn {
+				return m.notice("/countin: bars must be between 1 and %d", maxCountIn)
+			}
+			bars = n
+		}
+		m.countIn = bars * m.tempo.Signature.Beats
+		m.counting = false
+		return m.notice("Counting in %d beats from the next downbeat", m.countIn)
+
+	case "mute":
+		if c.Raw == "" {
+			return m.usage(c)
+		}
+		user, ok := m.roster.find(strings.TrimPrefix(c.Raw, "@"))
+		if !ok {
+			return m.notice("/mute: no player named %q", c.Raw)
+		}
+		if user.UserID == m.userID {
+			return m.notice("/mute: you can't mute yourself")
+		}
+		muted, save := m.toggleMute(user.UserID)
+		if !muted {
+			return tea.Batch(save, m.notice("Unmuted %s", user.DisplayName))
+		}
+		return tea.Batch(save, m.notice("Muted %s", user.DisplayName))
+
+	case "instrument":
+		if c.Raw == "" {
+			return m.notice("Playing %s (%d)", midi.ProgramName(m.instrument), m.instrument)
+		}
+		program, err := midi.FindProgram(c.Raw)
+		if err != nil {
+			return m.notice("/instrument: %v", err)
+		}
+		m.instrument, m.preset = program, ""
+		m.roster.upsert(m.selfInfo())
+		return tea.Batch(
+			m.notice("Playing %s (%d)", midi.ProgramName(program), program),
+			// Let the others update their rosters.
+			m.sendUserUpdate(),
+		)
+
+	case "synth":
+		return m.synthCommand(c)
+
+	case "capture":
+		return m.captureCommand(c)
+
+	case "scope":
+		return m.scopeCommand(c)
+
+	case "record":
+		if m.recorder != nil {
+			rec := m.recorder
+			m.recorder = nil
+			if err := rec.Close(); err != nil {
+				return m.notice("/record: %v", err)
+			}
+			return m.notice("Saved %d notes to %s", rec.Count(), rec.Path())
+		}
+		path, err := session.NewPath(m.ID, m.clock.Now())
+		if err != nil {
+			return m.notice("/record: %v", err)
+		}
+		rec, err := session.NewRecorder(path)
+		if err != nil {
+			return m.notice("/record: %v", err)
+		}
+		m.recorder = rec
+		return tea.Batch(m.recordMixer(), m.notice("Recording notes to %s", path))
+	}
+
+	return m.notice("/%s is not supported in a jam", c.Name)
+}
+
+// PatternCommand saves, loads and lists drum machine patterns.
+func (m *model) patternCommand(c chatui.CommandMsg) tea.Cmd {
+	if len(c.Args) == 0 || len(c.Args) > 2 {
+		return m.usage(c)
+	}
+	name := m.pattern.Name
+	if len(c.Args) == 2 {
+		name = c.Args[1]
+	}
+
+	switch c.Args[0] {
+	case "new":
+		if err := sequencer.ValidateName(name); err != nil {
+			return m.notice("/pattern: %v", err)
+		}
+		m.loadPattern(sequencer.NewPattern(name))
+		return m.notice("New pattern %s", name)
+
+	case "save":
+		path, err := sequencer.Path(name)
+		if err != nil {
+			return m.notice("/pattern: %v", err)
+		}
+		m.pattern.Name = name
+		if err := m.pattern.Save(path); err != nil {
+			return m.notice("/pattern: %v", err)
+		}
+		return m.notice("Saved pattern %s to %s", name, path)
+
+	case "load":
+		if len(c.Args) < 2 {
+			return m.usage(c)
+		}
+		path, err := sequencer.Path(name)
+		if err != nil {
+			return m.notice("/pattern: %v", err)
+		}
+		p, err := sequencer.Load(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return m.notice("/pattern: no pattern named %q", name)
+		}
+		if err != nil {
+			return m.notice("/pattern: %v", err)
+		}
+		m.loadPattern(p)
+		return m.notice("Loaded pattern %s", name)
+
+	case "list":
+		names, err := sequencer.List()
+		if err != nil {
+			return m.notice("/pattern: %v", err)
+		}
+		if len(names) == 0 {
+			return m.notice("No saved patterns")
+		}
+		return m.notice("Patterns: %s", strings.Join(names, ", "))
+	}
+	return m.usage(c)
+}
+
+// LoopCommand records, overdubs, undoes and clears the loop.
+func (m *model) loopCommand(c chatui.CommandMsg) tea.Cmd {
+	if len(c.Args) == 0 || len(c.Args) > 2 {
+		return m.usage(c)
+	}
+	switch c.Args[0] {
+	case "rec":
+		bars := defaultLoopBars
+		if len(c.Args) == 2 {
+			n, err := strconv.Atoi(c.Args[1])
+			if err != nil {
+				return m.usage(c)
+			}
+			bars = n
+		}
+		return m.recordLoop(bars)
+
+	case "dub":
+		return m.overdubLoop()
+
+	case "undo":
+		if !m.looper.Undo() {
+			return m.notice("/loop: nothing to undo")
+		}
+		if m.looper.State() == looper.Idle {
+			return m.notice("Loop cleared")
+		}
+		return m.notice("Removed the last layer, %d left", m.looper.Layers())
+
+	case "clear":
+		m.looper.Clear()
+		return m.notice("Loop cleared")
+	}
+	return m.usage(c)
+}
+
+// Notice shows feedback to the local user in the chat.
+func (m *model) notice(format string, a ...any) tea.Cmd {
+	var cmd tea.Cmd
+	m.chatBox, cmd = m.chatBox.Update(chatui.NoticeMsg{Msg: fmt.Sprintf(format, a...)})
+	return cmd
+}
+
+func (m *model) usage(c chatui.CommandMsg) tea.Cmd {
+	if cmd, ok := chatui.LookupCommand(c.Name); ok {
+		return m.notice("Usage: %s", cmd.Usage)
+	}
+	return nil
+}
+
+// SetOctave moves the virtual piano to start at the given octave, keeping it in the locked key.
+func (m *model) setOctave(octave vpiano.Octave) {
+	m.octave = octave
+	m.pianoNotes = vpiano.MakeScaleNotes(octave, m.scale)
+	m.noteKeyMap = m.pianoNotes.ToBindingMap()
+}
+
+// UpdateChatUsers refreshes the names the chat can tab-complete.
+func (m *model) updateChatUsers() tea.Cmd {
+	entries := m.roster.sorted()
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.info.UserID != m.userID {
+			names = append(names, e.info.DisplayName)
+		}
+	}
+	var cmd tea.Cmd
+	m.chatBox, cmd = m.chatBox.Update(chatui.UsersMsg{Self: m.userName, Names: names})
+	return cmd
+}
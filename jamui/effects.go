@@ -0,0 +1,210 @@
+package jamui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/keymap"
+	"github.com/rapidmidiex/rmxtui/midi"
+)
+
+// Reverb or chorus send a note gets when the effect is switched on.
+const defaultSend = 64
+
+var (
+	fxKeys = struct {
+		Up, Down, Left, Right key.Binding
+		Toggle, Reset         key.Binding
+	}{
+		Up:     key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "previous setting")),
+		Down:   key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "next setting")),
+		Left:   key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←/h", "less")),
+		Right:  key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→/l", "more")),
+		Toggle: key.NewBinding(key.WithKeys(" ", "x"), key.WithHelp("space/x", "on/off")),
+		Reset:  key.NewBinding(key.WithKeys("0"), key.WithHelp("0", "reset")),
+	}
+
+	fxNameStyle = mixerNameStyle.Copy().Width(11)
+)
+
+// FxSetting is a row of the effects pane.
+type fxSetting struct {
+	name string
+	// Nudge moves the setting a step up or down.
+	nudge func(s *audio.EffectsSettings, dir int)
+	// Toggle switches the effect on or off.
+	toggle func(s *audio.EffectsSettings)
+	// Reset puts the setting back to its default d.
+	reset func(s *audio.EffectsSettings, d audio.EffectsSettings)
+	show  func(s audio.EffectsSettings) string
+	on    func(s audio.EffectsSettings) bool
+}
+
+var fxSettings = []fxSetting{
+	{
+		name:   "Reverb",
+		nudge:  func(s *audio.EffectsSettings, dir int) { s.Reverb += 8 * dir },
+		toggle: func(s *audio.EffectsSettings) { s.Reverb = toggleSend(s.Reverb) },
+		reset:  func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.Reverb = d.Reverb },
+		show:   func(s audio.EffectsSettings) string { return showSend(s.Reverb) },
+		on:     func(s audio.EffectsSettings) bool { return s.Reverb > 0 },
+	},
+	{
+		name:   "Chorus",
+		nudge:  func(s *audio.EffectsSettings, dir int) { s.Chorus += 8 * dir },
+		toggle: func(s *audio.EffectsSettings) { s.Chorus = toggleSend(s.Chorus) },
+		reset:  func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.Chorus = d.Chorus },
+		show:   func(s audio.EffectsSettings) string { return showSend(s.Chorus) },
+		on:     func(s audio.EffectsSettings) bool { return s.Chorus > 0 },
+	},
+	{
+		name:  "Low",
+		nudge: func(s *audio.EffectsSettings, dir int) { s.EQ.Low += float64(dir) },
+		reset: func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.EQ.Low = d.EQ.Low },
+		show:  func(s audio.EffectsSettings) string { return formatVolume(s.EQ.Low) },
+		on:    func(s audio.EffectsSettings) bool { return s.EQ.Low != 0 },
+	},
+	{
+		name:  "Mid",
+		nudge: func(s *audio.EffectsSettings, dir int) { s.EQ.Mid += float64(dir) },
+		reset: func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.EQ.Mid = d.EQ.Mid },
+		show:  func(s audio.EffectsSettings) string { return formatVolume(s.EQ.Mid) },
+		on:    func(s audio.EffectsSettings) bool { return s.EQ.Mid != 0 },
+	},
+	{
+		name:  "High",
+		nudge: func(s *audio.EffectsSettings, dir int) { s.EQ.High += float64(dir) },
+		reset: func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.EQ.High = d.EQ.High },
+		show:  func(s audio.EffectsSettings) string { return formatVolume(s.EQ.High) },
+		on:    func(s audio.EffectsSettings) bool { return s.EQ.High != 0 },
+	},
+	{
+		name:   "Delay",
+		nudge:  func(s *audio.EffectsSettings, dir int) { s.Delay.Time += time.Duration(dir) * 25 * time.Millisecond },
+		toggle: func(s *audio.EffectsSettings) { s.Delay.On = !s.Delay.On },
+		reset:  func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.Delay = d.Delay },
+		show: func(s audio.EffectsSettings) string {
+			return fmt.Sprintf("%dms", s.Delay.Time.Milliseconds())
+		},
+		on: func(s audio.EffectsSettings) bool { return s.Delay.On },
+	},
+	{
+		name:  "Feedback",
+		nudge: func(s *audio.EffectsSettings, dir int) { s.Delay.Feedback = nudgePercent(s.Delay.Feedback, dir) },
+		reset: func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.Delay.Feedback = d.Delay.Feedback },
+		show:  func(s audio.EffectsSettings) string { return showPercent(s.Delay.Feedback) },
+		on:    func(s audio.EffectsSettings) bool { return s.Delay.On },
+	},
+	{
+		name:  "Echo mix",
+		nudge: func(s *audio.EffectsSettings, dir int) { s.Delay.Mix = nudgePercent(s.Delay.Mix, dir) },
+		reset: func(s *audio.EffectsSettings, d audio.EffectsSettings) { s.Delay.Mix = d.Delay.Mix },
+		show:  func(s audio.EffectsSettings) string { return showPercent(s.Delay.Mix) },
+		on:    func(s audio.EffectsSettings) bool { return s.Delay.On },
+	},
+}
+
+// UpdateEffects changes the effects while their pane has focus.
+func (m *model) updateEffects(msg tea.KeyMsg) tea.Cmd {
+	c := &m.fxCursor
+	s := m.effects.Settings()
+	setting := fxSettings[*c]
+	switch {
+	case key.Matches(msg, fxKeys.Up):
+		*c = (*c - 1 + len(fxSettings)) % len(fxSettings)
+		return nil
+	case key.Matches(msg, fxKeys.Down):
+		*c = (*c + 1) % len(fxSettings)
+		return nil
+	case key.Matches(msg, fxKeys.Left):
+		setting.nudge(&s, -1)
+	case key.Matches(msg, fxKeys.Right):
+		setting.nudge(&s, 1)
+	case key.Matches(msg, fxKeys.Toggle):
+		if setting.toggle == nil {
+			return nil
+		}
+		setting.toggle(&s)
+	case key.Matches(msg, fxKeys.Reset):
+		setting.reset(&s, audio.DefaultEffects())
+	default:
+		return nil
+	}
+	return m.setEffects(s)
+}
+
+// SetEffects applies effects settings to the synth and the master bus, and saves them for the next session.
+func (m *model) setEffects(s audio.EffectsSettings) tea.Cmd {
+	m.effects.Set(s)
+	s = m.effects.Settings()
+	m.midiPlayer.SetEffects(midi.Effects{Reverb: s.Reverb, Chorus: s.Chorus})
+	return m.fxSaver.schedule(func() error {
+		path, err := audio.EffectsPath()
+		if err != nil {
+			return fmt.Errorf("audio.EffectsPath: %w", err)
+		}
+		return s.Save(path)
+	})
+}
+
+// ToggleSend switches a reverb or chorus send off, or on at the default level.
+func toggleSend(send int) int {
+	if send > 0 {
+		return 0
+	}
+	return defaultSend
+}
+
+func showSend(send int) string {
+	if send == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d%%", int(math.Round(float64(send)*100/audio.MaxSend)))
+}
+
+// NudgePercent moves a level from 0 to 1 by 5%.
+func nudgePercent(v float64, dir int) float64 {
+	return math.Round(v*20+float64(dir)) / 20
+}
+
+func showPercent(v float64) string {
+	return fmt.Sprintf("%d%%", int(math.Round(v*100)))
+}
+
+// FxHelp lists the effects pane's keys.
+func fxHelp() string {
+	bindings := []key.Binding{fxKeys.Up, fxKeys.Left, fxKeys.Right, fxKeys.Toggle, fxKeys.Reset, keymap.DefaultMapping.PlayStop}
+	help := make([]string, len(bindings))
+	for i, b := range bindings {
+		help[i] = fmt.Sprintf("%s %s", b.Help().Key, b.Help().Desc)
+	}
+	return strings.Join(help, " · ")
+}
+
+func (m model) renderEffects() string {
+	focused := m.focused == fxFocus
+	s := m.effects.Settings()
+	lines := []string{seqTitleStyle.Render("Effects")}
+	for i, setting := range fxSettings {
+		name := fxNameStyle.Render(setting.name)
+		if focused && i == m.fxCursor {
+			name = seqCursorStyle.Render(name)
+		}
+		value := seqOffStyle.Render(setting.show(s))
+		if setting.on(s) {
+			value = mixerFaderStyle.Render(setting.show(s))
+		}
+		lines = append(lines, name+value)
+	}
+
+	if focused {
+		lines = append(lines, seqOffStyle.Render(fxHelp()))
+		return seqFocusedStyle.Render(strings.Join(lines, "\n"))
+	}
+	return seqStyle.Render(strings.Join(lines, "\n"))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -12,19 +13,35 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/faiface/beep"
-	"github.com/faiface/beep/speaker"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/rapidmidiex/rmxtui/audio"
 	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/clock"
 	"github.com/rapidmidiex/rmxtui/keymap"
+	"github.com/rapidmidiex/rmxtui/looper"
 	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/perform"
+	"github.com/rapidmidiex/rmxtui/pianoroll"
+	"github.com/rapidmidiex/rmxtui/profile"
+	"github.com/rapidmidiex/rmxtui/quantize"
 	"github.com/rapidmidiex/rmxtui/rmxerr"
 	"github.com/rapidmidiex/rmxtui/rtt"
+	"github.com/rapidmidiex/rmxtui/sequencer"
+	"github.com/rapidmidiex/rmxtui/session"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/theory"
+	"github.com/rapidmidiex/rmxtui/transport"
 	"github.com/rapidmidiex/rmxtui/vpiano"
 	"github.com/rapidmidiex/rmxtui/wsmsg"
 	"golang.org/x/term"
 )
 
+const (
+	dmOff    = "/dm is off: this server relays direct messages to the whole room. Start rmxtui with --private-dm if yours keeps them private."
+	dmLeaked = "The server relayed someone else's direct message to us, so direct messages aren't private here. /dm is off until you restart rmxtui."
+)
+
 // DocStyle styling for viewports
 var (
 	subtle    = lipgloss.AdaptiveColor{Light: "#D9DCCF", Dark: "#383838"}
@@ -44,16 +61,24 @@ var (
 		BottomRight: "╯",
 	}
 
+	headerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	recStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F87")).Bold(true)
+
 	pianoKeyStyle = lipgloss.NewStyle().
 			Align(lipgloss.Center).
 			Border(keyBorder, true).
 			BorderForeground(highlight).
 			Padding(0, 1)
+	rootKeyStyle = pianoKeyStyle.Copy().BorderForeground(special)
+	scaleStyle   = lipgloss.NewStyle().Foreground(special)
 )
 
 const (
 	chatFocus focused = iota
 	pianoFocus
+	seqFocus
+	mixerFocus
+	fxFocus
 	// Don't forget to update model.availableFocusStates if more states are added here.
 )
 
@@ -62,10 +87,17 @@ type (
 	ConnectedMsg struct {
 		WS    *websocket.Conn
 		JamID string
+		// Local identity presented to the other players.
+		Profile profile.Profile
 	}
 
 	LeaveRoomMsg struct{}
 
+	// NickChangedMsg is sent when the user changes their display name with /nick.
+	NickChangedMsg struct {
+		DisplayName string
+	}
+
 	StatsMsg rtt.Stats
 
 	sentMsg struct {
@@ -78,11 +110,38 @@ type (
 		userName string
 	}
 
+	recvNickMsg struct {
+		userID  uuid.UUID
+		oldName string
+		newName string
+	}
+
 	recvMIDIMsg struct {
-		id  uuid.UUID
-		msg wsmsg.MIDIMsg
+		id     uuid.UUID
+		userID uuid.UUID
+		msg    wsmsg.MIDIMsg
+	}
+
+	recvJoinMsg struct {
+		user   wsmsg.UserInfo
+		update bool
 	}
 
+	recvLeaveMsg struct {
+		userID uuid.UUID
+	}
+
+	recvRosterMsg struct {
+		users []wsmsg.UserInfo
+	}
+
+	// RecvOtherMsg is a message meant for someone else, like another user's direct message.
+	recvOtherMsg struct{}
+
+	// LeakedDirectMsg is another user's direct message, relayed to us by a server that was meant
+	// to keep it private.
+	leakedDirectMsg struct{}
+
 	focused int
 
 	model struct {
@@ -98,6 +157,8 @@ type (
 		ID string
 		// Chat container
 		chatBox tea.Model
+		// Players in the Jam Session
+		roster *roster
 
 		// Element currently with focus
 		focused focused
@@ -109,13 +170,119 @@ type (
 		pingStats rtt.Stats
 		userName  string
 		userID    uuid.UUID
+		userColor string
+		// Average latency we last announced to the room, when, and whether an announcement is
+		// waiting for the interval to pass.
+		latencySent    time.Duration
+		latencySentAt  time.Time
+		latencyPending bool
+		// General MIDI program of the local user's instrument, and the built-in synth preset
+		// played instead, if one is picked.
+		instrument int
+		preset     string
+		// Octave the virtual piano starts at.
+		octave vpiano.Octave
+		// Semitones added to every note we play.
+		transpose int
+		// Key the piano is locked to, zero when every key plays its own note.
+		scale vpiano.Key
+		// Jam's key, and whether our piano follows it.
+		roomKey   vpiano.Key
+		followKey bool
+		// Jam tempo, time signature and beat clock.
+		tempo tempo.Clock
+		// Player whose tempo we follow.
+		tempoFrom uuid.UUID
+		tapper    *tempo.Tapper
+		// Current beat, for the beat indicator.
+		beat tempo.Position
+		// Bumped whenever the clock is replaced.
+		beatGen int
+		// Room-wide play/stop and song position.
+		transport transport.Transport
+		// Bumped whenever a new song position refresh loop starts.
+		positionGen int
+		// Tells the time. Swapped for a fake clock in tests.
+		clock clock.Clock
+
+		// Drum machine pattern.
+		pattern   sequencer.Pattern
+		seqCursor seqCursor
+		// Step being played, -1 when stopped.
+		seqStep int
+		// Keep the pattern to ourselves.
+		seqMuted bool
+		// Bumped whenever the sequencer starts following the transport again.
+		seqGen int
+		// Loops what we play on the piano back into the room.
+		looper *looper.Looper
+		// Turns piano keys into chords, strums and arpeggios.
+		performer *perform.Performer
+		// Names the chord and key everyone is playing in.
+		theory *theory.Tracker
+		// Scrolling view of everyone's recent notes.
+		roll          *pianoroll.Roll
+		rollHidden    bool
+		rollPaused    bool
+		rollPausedAt  time.Time
+		rollScrolling bool
+		// Terminal size.
+		width  int
+		height int
+		// Pulls the notes we play onto the grid, and how far off the last one was.
+		quantize    quantize.Settings
+		quantizeOff time.Duration
+		// Click on every beat.
+		metronome bool
+		// Count-in beats left to click, and whether the count-in has started.
+		countIn  int
+		counting bool
+		// Records the jam's notes while /record is on.
+		recorder *session.Recorder
+
+		curMidiMsg wsmsg.MIDIMsg
+		midiPlayer *midi.Engines
+		// Every player's volume, pan, mute and solo, and the strip selected in the mixer pane.
+		mixer       *audio.Mixer
+		mixerCursor int
+		mixerSaver  *saver
+		// EQ and delay on the master bus, and the setting selected in the effects pane.
+		effects  *audio.Effects
+		fxCursor int
+		fxSaver  *saver
+		// Keeps the mix from clipping on its way to the speaker, and measures how loud it is.
+		limiter    *audio.Limiter
+		meter      *audio.Meter
+		sampleRate beep.SampleRate
+		// Where the sound is played.
+		out audio.Backend
+		// Keeps the latest of the mix for the output pane's scope, and what the pane shows.
+		scope  *audio.Scope
+		output *output
+		// Copies the mix on its way out to the capture, while /capture is on.
+		tap         *audio.Tap
+		capture     *audio.Capture
+		capturePath string
+		// Notes playing, limited so mashing keys doesn't pile them up, and a slot for each note
+		// being rendered at once.
+		voices     *midi.Voices
+		renders    chan struct{}
+		noteKeyMap vpiano.NoteKeyMap
+		log        *log.Logger
+		// Problems loading the settings, told once the chat is up.
+		startNotices []string
+		// The server keeps direct messages between sender and recipient. /dm is off without it.
+		privateDM bool
+	}
 
-		curMidiMsg  wsmsg.MIDIMsg
-		midiPlayer  midi.Synth
-		audioPlayer *audioPlayer
-		sampleRate  beep.SampleRate
-		noteKeyMap  vpiano.NoteKeyMap
-		log         *log.Logger
+	// Opts configures the jam view.
+	Opts struct {
+		// How many notes play at once, and which ends when there are too many.
+		Voices midi.VoicesOpts
+		// Engine notes are rendered by.
+		Engine midi.EngineName
+		// The server delivers direct messages only to their recipient, so /dm is private.
+		PrivateDM bool
 	}
 
 	wsClient struct {
@@ -123,54 +290,97 @@ type (
 		// Websocket connection for current Jam Session
 		conn *websocket.Conn
 	}
+)
 
-	audioPlayer struct {
-		mu    sync.Mutex
-		mixer *beep.Mixer
+// New returns the jam view, playing its sound on out.
+func New(out audio.Backend, o Opts) (model, error) {
+	fxPath, err := audio.EffectsPath()
+	if err != nil {
+		return model{}, fmt.Errorf("audio.EffectsPath: %w", err)
+	}
+	// A broken settings file shouldn't keep anyone from jamming. It's replaced on the next save.
+	var startNotices []string
+	effectSettings, err := audio.LoadEffects(fxPath)
+	if err != nil {
+		startNotices = append(startNotices, fmt.Sprintf("Effects reset to defaults: %v", err))
 	}
-)
 
-func New() (model, error) {
-	midiPlayer, err := midi.NewSynth(midi.NewSynthOpts{
-		// TODO: Take soundFont as arg, or select in TUI
-		SoundFontName: midi.GeneralUser,
+	midiPlayer, err := midi.NewEngines(midi.NewEnginesOpts{
+		Engine:     o.Engine,
+		Effects:    midi.Effects{Reverb: effectSettings.Reverb, Chorus: effectSettings.Chorus},
+		SampleRate: int(out.SampleRate()),
 	})
 	if err != nil {
-		return model{}, fmt.Errorf("midi.NewPlayer: %w", err)
+		return model{}, fmt.Errorf("midi.NewEngines: %w", err)
 	}
 
-	sr := beep.SampleRate(44100)
-	// TODO: Determine buffer length sweet spot.
-	// Bigger -> less CPU, slower response
-	// Lower -> more CPU, faster response
-	bufLen := sr.N(time.Millisecond * 20)
-	err = speaker.Init(sr, bufLen)
+	sr := out.SampleRate()
+	voices := o.Voices
+	voices.SampleRate = int(sr)
+
+	mixerPath, err := audio.SettingsPath()
 	if err != nil {
-		return model{}, fmt.Errorf("speaker.Init: %w", err)
+		return model{}, fmt.Errorf("audio.SettingsPath: %w", err)
+	}
+	mixerSettings, err := audio.LoadSettings(mixerPath)
+	if err != nil {
+		startNotices = append(startNotices, fmt.Sprintf("Mixer reset to defaults: %v", err))
 	}
 
-	pianoNotes := vpiano.MakeOctaveNotes(vpiano.C4)
+	octave := vpiano.C4
+	pianoNotes := vpiano.MakeOctaveNotes(octave)
 
 	m := model{
 		pianoNotes: pianoNotes,
 		activeKeys: make(map[string]struct{}),
 
 		chatBox: chatui.New(),
+		roster:  newRoster(clock.Wall),
+		octave:  octave,
+
+		followKey: true,
+		tempo:     tempo.NewClock(tempo.DefaultBPM, tempo.CommonTime, clock.Wall.Now()),
+		tapper:    &tempo.Tapper{},
+
+		transport: transport.New(),
+		clock:     clock.Wall,
+
+		pattern: sequencer.NewPattern("untitled"),
+		seqStep: -1,
+		looper:  looper.New(),
+
+		performer: perform.New(perform.DefaultSettings(), nil),
+		quantize:  quantize.Off(),
+		theory:    theory.NewTracker(),
+		roll:      pianoroll.New(),
 
 		focused: chatFocus,
 		// If more focus states are added, update number of available states
-		availableFocusStates: 2,
-
-		rtTimer:     rtt.NewTimer(),
-		pingStats:   rtt.NewStats(),
-		noteKeyMap:  pianoNotes.ToBindingMap(),
-		midiPlayer:  midiPlayer,
-		audioPlayer: &audioPlayer{mixer: &beep.Mixer{}},
-		sampleRate:  sr,
-		log:         log.Default(),
+		availableFocusStates: 5,
+
+		rtTimer:    rtt.NewTimer(),
+		pingStats:  rtt.NewStats(),
+		noteKeyMap: pianoNotes.ToBindingMap(),
+		midiPlayer: midiPlayer,
+		mixer:      audio.NewMixer(mixerSettings),
+		mixerSaver: newSaver("mixer"),
+		fxSaver:    newSaver("effects"),
+		sampleRate: sr,
+		out:        out,
+		voices:     midi.NewVoices(voices),
+		renders:    make(chan struct{}, runtime.NumCPU()),
+		output:     newOutput(),
+		log:        log.Default(),
 	}
-
-	speaker.Play(m.audioPlayer.mixer)
+	m.startNotices = startNotices
+	m.privateDM = o.PrivateDM
+	m.effects = audio.NewEffects(m.mixer, sr, effectSettings)
+	m.limiter = audio.NewLimiter(audio.NewLimiterOpts{Streamer: m.effects, SampleRate: sr})
+	m.meter = audio.NewMeter(m.limiter)
+	m.scope = audio.NewScope(m.meter)
+	m.tap = audio.NewTap(m.scope)
+
+	out.Play(m.tap)
 	return m, nil
 }
 
@@ -191,20 +401,58 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keymap.DefaultMapping.Quit):
 			cmds = append(cmds, m.leaveRoom())
 		case key.Matches(msg, keymap.DefaultMapping.GoBack):
-			cmds = append(cmds, m.leaveRoom())
+			m.stopOutput()
+			cmds = append(cmds, m.stopCapture(), m.leaveRoom())
 
-		case key.Matches(msg, keymap.DefaultMapping.CycleFocus):
+		case key.Matches(msg, keymap.DefaultMapping.CycleFocus) && !(m.focused == chatFocus && chatCompleting(m.chatBox)):
 			// Keep the state in bounds of the number of available states
+			prev := m.focused
 			m.focused = (m.focused + 1) % focused(m.availableFocusStates)
-			m.chatBox, cmd = m.chatBox.Update(chatui.ToggleFocusMsg{})
-			cmds = append(cmds, cmd)
+			if prev == chatFocus || m.focused == chatFocus {
+				m.chatBox, cmd = m.chatBox.Update(chatui.ToggleFocusMsg{})
+				cmds = append(cmds, cmd)
+			}
+			// The key only moves focus.
+			return m, tea.Batch(cmds...)
 		}
 
 		switch m.focused {
 		case chatFocus:
 			m.chatBox, cmd = m.chatBox.Update(msg)
 			cmds = append(cmds, cmd)
-		case pianoFocus:
+		case pianoFocus, seqFocus, mixerFocus, fxFocus:
+			if key.Matches(msg, keymap.DefaultMapping.PlayStop) {
+				if m.transport.Playing() {
+					cmds = append(cmds, m.stop())
+				} else {
+					cmds = append(cmds, m.play())
+				}
+				break
+			}
+			if m.focused == seqFocus {
+				cmds = append(cmds, m.updateSequencer(msg))
+				break
+			}
+			if m.focused == mixerFocus {
+				cmds = append(cmds, m.updateMixer(msg))
+				break
+			}
+			if m.focused == fxFocus {
+				cmds = append(cmds, m.updateEffects(msg))
+				break
+			}
+			if key.Matches(msg, keymap.DefaultMapping.TapTempo) {
+				cmds = append(cmds, m.tapTempo())
+				break
+			}
+			if key.Matches(msg, keymap.DefaultMapping.PauseRoll) {
+				cmds = append(cmds, m.pauseRoll())
+				break
+			}
+			if key.Matches(msg, keymap.DefaultMapping.Loop) {
+				cmds = append(cmds, m.toggleLoop())
+				break
+			}
 			// TODO: highlight the key play
 			cmds = append(cmds, m.sendMIDIMessage(msg.String()))
 		}
@@ -215,15 +463,57 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case ConnectedMsg:
 		m.wsClient = &wsClient{conn: msg.WS}
 		m.ID = msg.JamID
-		cmds = append(cmds, m.listenSocket())
+		m.userID = msg.Profile.ID
+		m.userName = msg.Profile.DisplayName
+		m.userColor = msg.Profile.Color
+		m.roster = newRoster(m.clock)
+		m.roster.upsert(m.selfInfo())
+		// A latency announcement still waiting when we left went to the lobby.
+		m.latencySent, m.latencySentAt, m.latencyPending = 0, time.Time{}, false
+		m.recorder = nil
+		cmds = append(cmds, m.stopCapture(), m.watchOutput())
+		m.tempoFrom = uuid.Nil
+		m.countIn, m.counting = 0, false
+		m.transport = transport.New()
+		m.looper.Clear()
+		m.theory.Reset()
+		m.roll.Clear()
+		// A roll tick still waiting when we left went to the lobby.
+		m.rollScrolling = false
+		m.roomKey = vpiano.Key{}
+		if m.followKey {
+			m.setScale(vpiano.Key{})
+		}
+		m.chatBox, cmd = m.chatBox.Update(chatui.JoinedMsg{RoomID: m.ID, UserID: m.userID})
+		for _, notice := range m.startNotices {
+			cmds = append(cmds, m.notice("%s", notice))
+		}
+		m.startNotices = nil
+		cmds = append(cmds, cmd, m.listenSocket(), m.sendJoinMessage(),
+			// Keep our own time until someone shares theirs.
+			m.setClock(tempo.NewClock(tempo.DefaultBPM, tempo.CommonTime, m.clock.Now())),
+		)
 
 	case chatui.SendMsg:
-		cmds = append(cmds, m.sendTextMessage(msg.Msg))
+		cmds = append(cmds, m.sendTextMessage(msg.Msg, false))
+	case chatui.SendDirectMsg:
+		if !m.privateDM {
+			cmds = append(cmds, m.notice(dmOff))
+			break
+		}
+		cmds = append(cmds, m.sendDirectMessage(msg.To, msg.ToName, msg.Msg))
+	case leakedDirectMsg:
+		// Anything sent since was read by the whole room, so stop before anything else is.
+		m.privateDM = false
+		m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: dmLeaked})
+		cmds = append(cmds, cmd, m.listenSocket())
+	case chatui.CommandMsg:
+		cmds = append(cmds, m.runCommand(msg))
 	case sentMsg:
 		// TODO Delete me after testing vv
 		// Curious if this time includes latency.
 		// If this number is 0, delete this log
-		timeToSend := time.Since(msg.sentAt).Milliseconds()
+		timeToSend := m.clock.Now().Sub(msg.sentAt).Milliseconds()
 		if timeToSend > 0 {
 			m.log.Printf("Send time: %d (ms)", timeToSend)
 		}
@@ -240,34 +530,158 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// TODO: Move to envelope msg handler (not just text)
 		latest := m.rtTimer.Stop(msg.ID.String())
-		var pingCmd tea.Cmd
-		if latest > -1 {
-			m.pingStats = m.pingStats.Calc(latest)
-			pingCmd = func() tea.Msg { return StatsMsg(m.pingStats) }
-		}
+		pingCmd := m.recordPing(latest)
+		// Start listening again
+		cmds = append(cmds, cmd, m.listenSocket(), pingCmd)
+
+	case chatui.RecvDirectMsg:
+		m.chatBox, cmd = m.chatBox.Update(msg)
+		latest := m.rtTimer.Stop(msg.ID.String())
+		pingCmd := m.recordPing(latest)
 		// Start listening again
 		cmds = append(cmds, cmd, m.listenSocket(), pingCmd)
 
+	case recvOtherMsg:
+		// Start listening again
+		cmds = append(cmds, m.listenSocket())
+
 	case recvConnectMsg:
-		m.userName = msg.userName
-		m.userID = msg.userID
+		// Our profile identity takes precedence over the one assigned by the server.
+		if m.userID == uuid.Nil {
+			m.userName = msg.userName
+			m.userID = msg.userID
+		}
+		// Start listening again
+		cmds = append(cmds, cmd, m.listenSocket())
+
+	case recvNickMsg:
+		m.roster.rename(msg.userID, msg.newName)
+		cmds = append(cmds, m.updateChatUsers())
+		info := fmt.Sprintf("%s is now known as %s", msg.oldName, msg.newName)
+		if msg.userID == m.userID {
+			info = fmt.Sprintf("You are now known as %s", msg.newName)
+		}
+		m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: info})
 		// Start listening again
 		cmds = append(cmds, cmd, m.listenSocket())
+	case recvJoinMsg:
+		isNew := m.roster.upsert(msg.user)
+		cmds = append(cmds, m.updateChatUsers())
+		if isNew && !msg.update && msg.user.UserID != m.userID {
+			m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: msg.user.DisplayName + " joined the jam"})
+			// Let the newcomer know we're here too.
+			cmds = append(cmds, cmd, m.sendUserUpdate())
+			if m.keepsTime() {
+				cmds = append(cmds, m.syncNewcomer())
+			}
+		}
+		// Start listening again
+		cmds = append(cmds, m.listenSocket())
+
+	case recvLeaveMsg:
+		user, ok := m.roster.remove(msg.userID)
+		cmds = append(cmds, m.updateChatUsers())
+		if ok && msg.userID != m.userID {
+			m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: user.DisplayName + " left the jam"})
+			cmds = append(cmds, cmd)
+		}
+		// Start listening again
+		cmds = append(cmds, m.listenSocket())
+
+	case recvRosterMsg:
+		m.roster.replace(msg.users)
+		// Make sure we're listed even if the server snapshot predates our JoinMsg.
+		m.roster.upsert(m.selfInfo())
+		// Start listening again
+		cmds = append(cmds, m.updateChatUsers(), m.listenSocket())
+
+	case beatMsg:
+		cmds = append(cmds, m.onBeat(msg))
+
+	case stepMsg:
+		cmds = append(cmds, m.onStep(msg))
+
+	case rollTickMsg:
+		cmds = append(cmds, m.onRollTick())
+
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.chatBox, cmd = m.chatBox.Update(msg)
+		cmds = append(cmds, cmd)
+
+	case performNoteMsg:
+		cmds = append(cmds, m.sendNote(msg.msg))
+
+	case positionTickMsg:
+		cmds = append(cmds, m.onPositionTick(msg))
+
+	case captureTickMsg:
+		cmds = append(cmds, m.onCaptureTick(msg))
+
+	case captureStoppedMsg:
+		cmds = append(cmds, m.onCaptureStopped(msg))
+
+	case outputTickMsg:
+		cmds = append(cmds, m.onOutputTick(msg))
+
+	case recvTransportMsg:
+		// Start listening again
+		cmds = append(cmds, m.onTransport(msg), m.listenSocket())
+
+	case recvKeyMsg:
+		// Start listening again
+		cmds = append(cmds, m.onKey(msg), m.listenSocket())
+
+	case tapDoneMsg:
+		cmds = append(cmds, m.onTapDone(msg))
+
+	case recvTempoMsg:
+		// Start listening again
+		cmds = append(cmds, m.onTempo(msg), m.listenSocket())
+
+	case saveDueMsg:
+		cmds = append(cmds, onSaveDue(msg))
+
+	case latencyMsg:
+		m.latencyPending = false
+		cmds = append(cmds, m.announceLatency())
+
+	case rosterRefreshMsg:
+		// Nothing to update, the roster is redrawn on the next View.
+
 	case recvMIDIMsg:
 		m.curMidiMsg = msg.msg
+		m.roster.played(msg.userID)
+		// Our own notes were tracked when we sent them.
+		if msg.userID != m.userID {
+			m.theory.Add(msg.userID, msg.msg, m.clock.Now())
+			cmds = append(cmds, m.rollNote(msg.userID, msg.msg))
+		}
+		cmds = append(cmds, tea.Tick(playingWindow, func(time.Time) tea.Msg { return rosterRefreshMsg{} }))
+		if m.recorder != nil {
+			// Players who joined since the recording started have their strip recorded with their first note.
+			cmds = append(cmds, m.recordStrip(msg.userID))
+			if err := m.recorder.Record(msg.userID, m.roster.name(msg.userID), msg.msg); err != nil {
+				cmds = append(cmds, m.notice("/record: %v", err))
+			}
+		}
 
 		// TODO: Move to envelope msg handler (not just text)
 		latest := m.rtTimer.Stop(msg.id.String())
-		var pingCmd tea.Cmd
-		if latest > -1 {
-			m.pingStats = m.pingStats.Calc(latest)
-			pingCmd = func() tea.Msg { return StatsMsg(m.pingStats) }
-		}
+		pingCmd := m.recordPing(latest)
 
 		// Play MIDI on speakers
-		cmd = m.playMIDI(msg.msg)
+		cmd = nil
+		if m.mixer.Audible(msg.userID) {
+			cmd = m.playMIDI(msg.userID, msg.msg)
+		}
 		// Start listening again
 		cmds = append(cmds, cmd, m.listenSocket(), pingCmd)
+
+	default:
+		// Chat internals, ex: history loading, cursor blink, window resizes.
+		m.chatBox, cmd = m.chatBox.Update(msg)
+		cmds = append(cmds, cmd)
 	}
 
 	return m, tea.Batch(cmds...)
@@ -281,17 +695,48 @@ func (m model) View() string {
 		docStyle = docStyle.MaxWidth(physicalWidth)
 	}
 
-	doc.WriteString(m.chatBox.View())
-	doc.WriteString(m.renderPiano() + "\n\n")
+	doc.WriteString(m.renderHeader() + "\n\n")
+	doc.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, m.chatBox.View(), m.renderRoster()))
+	if !m.rollHidden {
+		doc.WriteString(m.renderRoll() + "\n")
+	}
+	doc.WriteString(m.renderTheory() + "\n")
+	doc.WriteString(m.renderPiano() + "\n")
+	doc.WriteString(m.renderSequencer() + "\n")
+	doc.WriteString(m.renderPanes() + "\n\n")
 	return docStyle.Render(doc.String())
 }
 
+// RenderPanes lays out the mixer, effects and output panes side by side, moving the output pane
+// below the others when the terminal is too narrow for it.
+func (m model) renderPanes() string {
+	panes := lipgloss.JoinHorizontal(lipgloss.Top, m.renderMixer(), m.renderEffects())
+	if m.output.hidden {
+		return panes
+	}
+	out := m.renderOutput()
+	if m.width > 0 && lipgloss.Width(panes)+lipgloss.Width(out) > m.width-docStyle.GetHorizontalFrameSize() {
+		return panes + "\n" + out
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, panes, out)
+}
+
 // LeaveRoom disconnects from the room and sends a LeaveRoom message.
 func (m model) leaveRoom() tea.Cmd {
 	return func() tea.Msg {
+		if m.recorder != nil {
+			if err := m.recorder.Close(); err != nil {
+				m.log.Printf("close recording: %v", err)
+			}
+		}
 		if m.wsClient.conn == nil {
 			return LeaveRoomMsg{}
 		}
+		// Say goodbye so the other players can update their rosters.
+		// Not fatal if this fails, the server will notice the closed connection.
+		if err := m.sendEnvelope(wsmsg.LEAVE, wsmsg.LeaveMsg{UserID: m.userID}); err != nil {
+			m.log.Printf("send LeaveMsg: %v", err)
+		}
 		// Send websocket close message
 		err := m.wsClient.conn.WriteControl(
 			websocket.CloseMessage,
@@ -330,9 +775,24 @@ func (m model) listenSocket() tea.Cmd {
 			}
 			return chatui.RecvTextMsg{
 				ID:          message.ID,
+				SenderID:    message.UserID,
 				DisplayName: textMsg.DisplayName,
 				Msg:         string(textMsg.Body),
 				FromSelf:    fromSelf,
+				Color:       textMsg.Color,
+				SentAt:      textMsg.SentAt,
+				Action:      textMsg.Action,
+			}
+
+		case wsmsg.NICK:
+			var nickMsg wsmsg.NickMsg
+			if err := message.Unwrap(&nickMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal NickMsg: %+v\n%w", message, err)}
+			}
+			return recvNickMsg{
+				userID:  message.UserID,
+				oldName: nickMsg.OldName,
+				newName: nickMsg.DisplayName,
 			}
 
 		case wsmsg.CONNECT:
@@ -352,8 +812,79 @@ func (m model) listenSocket() tea.Cmd {
 			}
 			m.log.Printf("MIDI received: %+v\n", midiMsg)
 			return recvMIDIMsg{
-				id:  message.ID,
-				msg: midiMsg,
+				id:     message.ID,
+				userID: message.UserID,
+				msg:    midiMsg,
+			}
+
+		case wsmsg.JOIN:
+			var joinMsg wsmsg.JoinMsg
+			if err := message.Unwrap(&joinMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal JoinMsg: %+v\n%w", message, err)}
+			}
+			return recvJoinMsg{user: joinMsg.User, update: joinMsg.Update}
+
+		case wsmsg.LEAVE:
+			var leaveMsg wsmsg.LeaveMsg
+			if err := message.Unwrap(&leaveMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal LeaveMsg: %+v\n%w", message, err)}
+			}
+			if leaveMsg.UserID == uuid.Nil {
+				leaveMsg.UserID = message.UserID
+			}
+			return recvLeaveMsg{userID: leaveMsg.UserID}
+
+		case wsmsg.ROSTER:
+			var rosterMsg wsmsg.RosterMsg
+			if err := message.Unwrap(&rosterMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal RosterMsg: %+v\n%w", message, err)}
+			}
+			return recvRosterMsg{users: rosterMsg.Users}
+
+		case wsmsg.TEMPO:
+			var tempoMsg wsmsg.TempoMsg
+			if err := message.Unwrap(&tempoMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal TempoMsg: %+v\n%w", message, err)}
+			}
+			return recvTempoMsg{userID: message.UserID, msg: tempoMsg}
+
+		case wsmsg.TRANSPORT:
+			var transportMsg wsmsg.TransportMsg
+			if err := message.Unwrap(&transportMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal TransportMsg: %+v\n%w", message, err)}
+			}
+			return recvTransportMsg{userID: message.UserID, msg: transportMsg}
+
+		case wsmsg.KEY:
+			var keyMsg wsmsg.KeyMsg
+			if err := message.Unwrap(&keyMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal KeyMsg: %+v\n%w", message, err)}
+			}
+			return recvKeyMsg{userID: message.UserID, msg: keyMsg}
+
+		case wsmsg.DIRECT:
+			var directMsg wsmsg.DirectMsg
+			if err := message.Unwrap(&directMsg); err != nil {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("unmarshal DirectMsg: %+v\n%w", message, err)}
+			}
+			fromSelf := message.UserID == m.userID
+			if !fromSelf && directMsg.To != m.userID {
+				// A server that isn't private relays direct messages to everyone in the room.
+				if m.privateDM {
+					return leakedDirectMsg{}
+				}
+				return recvOtherMsg{}
+			}
+			return chatui.RecvDirectMsg{
+				ID:          message.ID,
+				SenderID:    message.UserID,
+				DisplayName: directMsg.DisplayName,
+				Color:       directMsg.Color,
+				To:          directMsg.To,
+				ToName:      directMsg.ToName,
+				Msg:         directMsg.Body,
+				FromSelf:    fromSelf,
+				SentAt:      directMsg.SentAt,
 			}
 		default:
 			return rmxerr.ErrMsg{Err: fmt.Errorf("unknown message type: %+v", message)}
@@ -362,22 +893,29 @@ func (m model) listenSocket() tea.Cmd {
 
 }
 
-func (m model) sendTextMessage(body string) tea.Cmd {
+// SendTextMessage sends a chat message. Actions are messages sent with /me.
+func (m model) sendTextMessage(body string, action bool) tea.Cmd {
 	return func() tea.Msg {
 		envelope := wsmsg.Envelope{
 			ID:     uuid.New(),
 			Typ:    wsmsg.TEXT,
 			UserID: m.userID,
 		}
-		textMsg := wsmsg.TextMsg{Body: body, DisplayName: m.userName}
+		textMsg := wsmsg.TextMsg{
+			Body:        body,
+			DisplayName: m.userName,
+			Color:       m.userColor,
+			SentAt:      m.clock.Now(),
+			Action:      action,
+		}
 		err := envelope.SetPayload(textMsg)
 		if err != nil {
 			return rmxerr.ErrMsg{Err: fmt.Errorf("marshal: %w", err)}
 		}
 
 		// Curious to see how long WriteJSON takes
-		preSendTime := time.Now()
-		err = m.wsClient.conn.WriteJSON(envelope)
+		preSendTime := m.clock.Now()
+		err = m.wsClient.writeMsg(envelope)
 		if err != nil {
 			return rmxerr.ErrMsg{Err: fmt.Errorf("writeJSON: %w", err)}
 		}
@@ -388,19 +926,146 @@ func (m model) sendTextMessage(body string) tea.Cmd {
 	}
 }
 
-func (m model) sendMIDIMessage(keyPressed string) tea.Cmd {
+// SendDirectMessage sends a chat message to a single user. The server has to keep it private,
+// see Opts.PrivateDM.
+func (m model) sendDirectMessage(to uuid.UUID, toName, body string) tea.Cmd {
 	return func() tea.Msg {
-		midiNum := m.noteKeyMap[keyPressed].MIDI
-		if !vpiano.InRange(midiNum) {
-			return nil
+		envelope := wsmsg.Envelope{
+			ID:     uuid.New(),
+			Typ:    wsmsg.DIRECT,
+			UserID: m.userID,
+		}
+		directMsg := wsmsg.DirectMsg{
+			To:          to,
+			ToName:      toName,
+			DisplayName: m.userName,
+			Body:        body,
+			Color:       m.userColor,
+			SentAt:      m.clock.Now(),
+		}
+		if err := envelope.SetPayload(directMsg); err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("marshal: %w", err)}
+		}
+		preSendTime := m.clock.Now()
+		if err := m.wsClient.writeMsg(envelope); err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("writeJSON: %w", err)}
+		}
+		return sentMsg{
+			id:     envelope.ID,
+			sentAt: preSendTime,
+		}
+	}
+}
+
+// SendNickMessage tells the other players that we changed our display name.
+func (m model) sendNickMessage(oldName, newName string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.sendEnvelope(wsmsg.NICK, wsmsg.NickMsg{OldName: oldName, DisplayName: newName}); err != nil {
+			return rmxerr.ErrMsg{Err: err}
 		}
+		return nil
+	}
+}
+
+// SendJoinMessage announces the local user's arrival to the other players.
+func (m model) sendJoinMessage() tea.Cmd {
+	return m.sendJoin(wsmsg.JoinMsg{User: m.selfInfo()})
+}
+
+// SendUserUpdate tells the other players the local user's details, once they've arrived.
+func (m model) sendUserUpdate() tea.Cmd {
+	return m.sendJoin(wsmsg.JoinMsg{User: m.selfInfo(), Update: true})
+}
 
-		msg := wsmsg.MIDIMsg{
-			State:    wsmsg.NOTE_ON,
-			Velocity: 127,
-			Number:   midiNum,
+func (m model) sendJoin(join wsmsg.JoinMsg) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.sendEnvelope(wsmsg.JOIN, join); err != nil {
+			return rmxerr.ErrMsg{Err: err}
 		}
+		return nil
+	}
+}
+
+// RecordPing adds a round trip to the ping stats, and lets the room know if our latency has
+// changed since we last said.
+func (m *model) recordPing(latest time.Duration) tea.Cmd {
+	if latest < 0 {
+		return nil
+	}
+	m.pingStats = m.pingStats.Calc(latest)
+	stats := m.pingStats
+	return tea.Batch(func() tea.Msg { return StatsMsg(stats) }, m.announceLatency())
+}
 
+// AnnounceLatency sends our average latency in a JOIN once it has moved far enough from what we
+// last announced, so other players can work out the delay on our notes and tempo. Announcements
+// are throttled, the last change in an interval is sent when it's up.
+func (m *model) announceLatency() tea.Cmd {
+	change := m.pingStats.Avg - m.latencySent
+	if m.latencyPending || (change < latencyChange && -change < latencyChange) {
+		return nil
+	}
+	now := m.clock.Now()
+	if wait := latencyInterval - now.Sub(m.latencySentAt); wait > 0 {
+		m.latencyPending = true
+		return tea.Tick(wait, func(time.Time) tea.Msg { return latencyMsg{} })
+	}
+	m.latencySent, m.latencySentAt = m.pingStats.Avg, now
+	m.roster.upsert(m.selfInfo())
+	return m.sendUserUpdate()
+}
+
+// SendEnvelope wraps the payload in an envelope from the local user and writes it to the websocket.
+func (m model) sendEnvelope(typ wsmsg.MsgType, payload any) error {
+	envelope := wsmsg.Envelope{
+		ID:     uuid.New(),
+		Typ:    typ,
+		UserID: m.userID,
+	}
+	if err := envelope.SetPayload(payload); err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if err := m.wsClient.writeMsg(envelope); err != nil {
+		return fmt.Errorf("writeJSON: %w", err)
+	}
+	return nil
+}
+
+// SelfInfo describes the local user for the roster.
+func (m model) selfInfo() wsmsg.UserInfo {
+	return wsmsg.UserInfo{
+		UserID:      m.userID,
+		DisplayName: m.userName,
+		Color:       m.userColor,
+		Instrument:  m.instrument,
+		Preset:      m.preset,
+		LatencyMS:   m.pingStats.Avg.Milliseconds(),
+	}
+}
+
+// SendMIDIMessage sends the note for a piano key to the room.
+func (m *model) sendMIDIMessage(keyPressed string) tea.Cmd {
+	note, ok := m.noteKeyMap[keyPressed]
+	if !ok {
+		return nil
+	}
+	midiNum := note.MIDI + m.transpose
+	if !vpiano.InRange(midiNum) {
+		return nil
+	}
+	msg := wsmsg.MIDIMsg{
+		State:    wsmsg.NOTE_ON,
+		Velocity: 127,
+		Number:   midiNum,
+		Program:  m.instrument,
+		Preset:   m.preset,
+	}
+	return m.perform(msg)
+}
+
+// SendMIDI sends a MIDI message to the room. We play it when the server echoes it back.
+func (m model) sendMIDI(msg wsmsg.MIDIMsg) tea.Cmd {
+	return func() tea.Msg {
 		envelope := wsmsg.Envelope{
 			ID:     uuid.New(),
 			Typ:    wsmsg.MIDI,
@@ -415,45 +1080,112 @@ func (m model) sendMIDIMessage(keyPressed string) tea.Cmd {
 		}
 		return sentMsg{
 			id:     envelope.ID,
-			sentAt: time.Now(),
+			sentAt: m.clock.Now(),
 		}
 	}
 }
 
-// PlayMIDI plays the given MIDI note through system audio.
-func (m *model) playMIDI(note wsmsg.MIDIMsg) tea.Cmd {
+// PlayMIDI plays the given MIDI note through system audio, on the player's mixer strip.
+func (m *model) playMIDI(player uuid.UUID, note wsmsg.MIDIMsg) tea.Cmd {
+	// NOTE_OFF messages are not really going to work with a virtual keyboard
+	// or with sending realtime messages, so we have to use some arbitrary duration to play the note.
+	// TODO: Maybe control duration with some other key
+	return m.playNote(player, note, time.Second*2)
+}
+
+// PlayNote renders duration worth of the note and adds it to the player's mixer bus.
+// Local sounds like the metronome use uuid.Nil.
+func (m *model) playNote(player uuid.UUID, note wsmsg.MIDIMsg, duration time.Duration) tea.Cmd {
+	// Take a voice first, so a note that would only be stolen again isn't rendered.
+	key := midi.VoiceKey{Player: player, Channel: note.Channel, Note: note.Number}
+	v := m.voices.Reserve(key)
 	return func() tea.Msg {
-		// NOTE_OFF messages are not really going to work with a virtual keyboard
-		// or with sending realtime messages, so we have to use some arbitrary duration to play the note.
-		// TODO: Maybe control duration with some other key
-		duration := time.Second * 2
-		s := midi.NewMIDIStreamer(duration)
-
-		// Render MIDI note to audio streamer buffer
-		if err := m.midiPlayer.Render(note, s); err != nil {
+		m.renders <- struct{}{}
+		defer func() { <-m.renders }()
+		// Stolen by a newer note while waiting for a turn to render.
+		if v.Released() {
+			v.Cancel()
+			return nil
+		}
+
+		// Render MIDI note to audio
+		s, err := m.midiPlayer.Note(note, duration)
+		if err != nil {
+			v.Cancel()
 			return rmxerr.ErrMsg{Err: err}
 		}
 
-		// Take n seconds worth of samples @ 44.1khz from the audio streamer and
-		// add it to the main speaker mix.
-		m.audioPlayer.addToMix(beep.Take(m.sampleRate.N(duration), s))
+		// Add it to the player's bus, as one of the voices.
+		v.Play(s)
+		m.mixer.Add(player, v)
 
 		return nil
 	}
 }
 
+// RenderHeader shows the jam's settings above the chat.
+func (m model) renderHeader() string {
+	parts := []string{
+		fmt.Sprintf("Octave C%d", m.octave),
+		m.renderTransport(),
+		fmt.Sprintf("%d BPM %s", m.tempo.BPM, m.tempo.Signature),
+		m.renderBeat(),
+		instrumentName(m.instrument, m.preset),
+	}
+	if m.metronome {
+		parts = append(parts, "Click")
+	}
+	if loop := m.renderLoop(); loop != "" {
+		parts = append(parts, loop)
+	}
+	if m.quantize.Enabled() {
+		parts = append(parts, m.renderQuantize())
+	}
+	if s := m.performer.Settings; s.Mode != perform.Single {
+		parts = append(parts, s.String())
+	}
+	if m.transpose != 0 {
+		parts = append(parts, fmt.Sprintf("Transpose %+d", m.transpose))
+	}
+	if m.recorder != nil {
+		elapsed := m.recorder.Elapsed().Round(time.Second)
+		parts = append(parts, recStyle.Render(fmt.Sprintf("● REC %s", elapsed)))
+	}
+	return headerStyle.Render(strings.Join(parts, " · "))
+}
+
+// ChatCompleting reports whether the chat would use tab for completion.
+func chatCompleting(chat tea.Model) bool {
+	c, ok := chat.(interface{ Completing() bool })
+	return ok && c.Completing()
+}
+
 func (m model) renderPiano() string {
+	locked := !m.scale.IsZero()
 	pianoKeys := make([]string, 0)
 	for _, v := range m.pianoNotes {
-		if v.IsAccidental {
+		// Locked to a key, every key is in the scale and shown.
+		if v.IsAccidental && !locked {
 			// TODO: Figure out black keys
 			continue
 		}
+		style := pianoKeyStyle
+		if locked && (v.MIDI-m.scale.Root)%12 == 0 {
+			style = rootKeyStyle
+		}
 		pianoKeys = append(pianoKeys,
-			pianoKeyStyle.Render(lipgloss.JoinVertical(lipgloss.Top, v.Name, "\n", fmt.Sprintf("(%s)", v.KeyBinding))),
+			style.Render(lipgloss.JoinVertical(lipgloss.Top, v.Name, "\n", fmt.Sprintf("(%s)", v.KeyBinding))),
 		)
 	}
-	return lipgloss.JoinHorizontal(lipgloss.Top, pianoKeys...)
+	piano := lipgloss.JoinHorizontal(lipgloss.Top, pianoKeys...)
+	if !locked {
+		return piano
+	}
+	label := "Scale lock: " + m.scale.String()
+	if m.followKey {
+		label += " (jam key)"
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, scaleStyle.Render(label), piano)
 }
 
 func (c *wsClient) readMsg(out *wsmsg.Envelope) error {
@@ -465,9 +1197,3 @@ func (c *wsClient) writeMsg(envelope wsmsg.Envelope) error {
 	defer c.mu.Unlock()
 	return c.conn.WriteJSON(envelope)
 }
-
-func (a *audioPlayer) addToMix(s beep.Streamer) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.mixer.Add(s)
-}
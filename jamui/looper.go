@@ -0,0 +1,84 @@
+package jamui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/looper"
+	"github.com/rapidmidiex/rmxtui/sequencer"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+// Bars recorded when no length is given.
+const defaultLoopBars = 2
+
+// RecordLoop arms the looper to record the piano from the next downbeat.
+func (m *model) recordLoop(bars int) tea.Cmd {
+	if !m.transport.Playing() {
+		return m.notice("/loop: start playback first, loops follow the song")
+	}
+	sig := m.tempo.Signature
+	barLength := sig.Beats * sequencer.StepValue / sig.Unit
+	if barLength < 1 {
+		return m.notice("/loop: bars of %s are shorter than a sixteenth", sig)
+	}
+	if err := m.looper.Record(m.sixteenths(m.clock.Now()), bars, barLength); err != nil {
+		return m.notice("/loop: %v", err)
+	}
+	return m.notice("Recording a %d bar loop from the next downbeat", bars)
+}
+
+// ToggleLoop records a loop if there isn't one, or starts and stops overdubbing it.
+func (m *model) toggleLoop() tea.Cmd {
+	switch m.looper.State() {
+	case looper.Idle:
+		return m.recordLoop(defaultLoopBars)
+	case looper.Armed, looper.Recording:
+		return m.notice("Still recording the loop")
+	}
+	return m.overdubLoop()
+}
+
+func (m *model) overdubLoop() tea.Cmd {
+	if err := m.looper.Overdub(); err != nil {
+		return m.notice("/loop: %v", err)
+	}
+	if m.looper.State() == looper.Overdubbing {
+		return m.notice("Overdubbing the loop")
+	}
+	return m.notice("Stopped overdubbing")
+}
+
+// LoopNote records a note we played into the loop.
+func (m model) loopNote(msg wsmsg.MIDIMsg) {
+	now := m.clock.Now()
+	if !m.transport.Started(now) {
+		return
+	}
+	m.looper.Note(m.sixteenths(now), msg)
+}
+
+// Sixteenths counts the sixteenth notes from the top of the song to time now.
+func (m model) sixteenths(now time.Time) float64 {
+	return m.tempo.Signature.Notes(m.transport.Position(m.tempo, now), sequencer.StepValue)
+}
+
+func (m model) renderLoop() string {
+	l := m.looper
+	pass := func() string {
+		step := sequencer.Sixteenth(m.tempo.Signature, m.transport.Position(m.tempo, m.clock.Now()))
+		return fmt.Sprintf("%d/%d", l.Pass(step), l.Bars())
+	}
+	switch l.State() {
+	case looper.Armed:
+		return recStyle.Render(fmt.Sprintf("○ Loop %d bars", l.Bars()))
+	case looper.Recording:
+		return recStyle.Render("● Loop " + pass())
+	case looper.Playing:
+		return fmt.Sprintf("⟳ Loop %s · %d layers", pass(), l.Layers())
+	case looper.Overdubbing:
+		return recStyle.Render(fmt.Sprintf("● Dub %s · %d layers", pass(), l.Layers()))
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+package jamui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/rmxerr"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+// How long a metronome click is rendered for.
+const clickDuration = 300 * time.Millisecond
+
+var (
+	beatStyle     = lipgloss.NewStyle().Foreground(special)
+	downbeatStyle = lipgloss.NewStyle().Foreground(highlight).Bold(true)
+	restStyle     = lipgloss.NewStyle().Foreground(subtle)
+	countInStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#F25D94")).Bold(true)
+)
+
+type (
+	// BeatMsg fires on every beat of the jam's clock.
+	beatMsg struct {
+		// Clock generation the beat was scheduled for. Beats from a replaced clock are dropped.
+		gen int
+		at  time.Time
+		pos tempo.Position
+	}
+
+	// TapDoneMsg fires once the user may have stopped tapping the tempo.
+	tapDoneMsg struct {
+		last time.Time
+	}
+
+	recvTempoMsg struct {
+		userID uuid.UUID
+		msg    wsmsg.TempoMsg
+	}
+)
+
+// SetClock replaces the jam's clock and schedules its next beat.
+func (m *model) setClock(c tempo.Clock) tea.Cmd {
+	now := m.clock.Now()
+	m.transport.Retime(m.tempo, c, now)
+	m.tempo = c
+	m.beatGen++
+	m.beat = c.At(now)
+	return m.nextBeat(now)
+}
+
+// NextBeat waits for the first beat after t.
+func (m model) nextBeat(t time.Time) tea.Cmd {
+	at, pos := m.tempo.NextBeat(t)
+	gen := m.beatGen
+	return tea.Tick(at.Sub(m.clock.Now()), func(time.Time) tea.Msg {
+		return beatMsg{gen: gen, at: at, pos: pos}
+	})
+}
+
+// OnBeat updates the beat indicator and plays the metronome click.
+func (m *model) onBeat(msg beatMsg) tea.Cmd {
+	if msg.gen != m.beatGen {
+		return nil
+	}
+	m.beat = msg.pos
+
+	click := m.metronome
+	// The count-in starts on a downbeat.
+	if m.countIn > 0 && (m.counting || msg.pos.Beat == 1) {
+		m.counting = true
+		m.countIn--
+		click = true
+	} else {
+		m.counting = false
+	}
+
+	cmds := []tea.Cmd{m.nextBeat(msg.at)}
+	if click {
+		cmds = append(cmds, m.playClick(msg.pos.Beat == 1))
+	}
+	return tea.Batch(cmds...)
+}
+
+// PlayClick plays a wood block on the percussion channel, accented on the downbeat.
+func (m *model) playClick(downbeat bool) tea.Cmd {
+	note := wsmsg.MIDIMsg{
+		State:    wsmsg.NOTE_ON,
+		Number:   midi.LowWoodBlock,
+		Velocity: 90,
+		Channel:  midi.PercussionChannel,
+	}
+	if downbeat {
+		note.Number = midi.HiWoodBlock
+		note.Velocity = 127
+	}
+	return m.playNote(uuid.Nil, note, clickDuration)
+}
+
+// TapTempo sets the tempo from taps on the tap key, with the latest tap as the downbeat.
+// The room hears about it once the user stops tapping.
+func (m *model) tapTempo() tea.Cmd {
+	now := m.clock.Now()
+	bpm, ok := m.tapper.Tap(now)
+	if !ok {
+		return nil
+	}
+	m.tempoFrom = m.userID
+	return tea.Batch(
+		m.setClock(tempo.NewClock(bpm, m.tempo.Signature, now)),
+		tea.Tick(tempo.TapTimeout, func(time.Time) tea.Msg { return tapDoneMsg{last: now} }),
+	)
+}
+
+// OnTapDone shares the tapped tempo if there were no taps since.
+func (m *model) onTapDone(msg tapDoneMsg) tea.Cmd {
+	if !m.tapper.Last().Equal(msg.last) {
+		return nil
+	}
+	return tea.Batch(
+		m.sendTempoMessage(),
+		m.notice("Tempo set to %d BPM", m.tempo.BPM),
+	)
+}
+
+// OnTempo lines our clock up with the one another player sent.
+func (m *model) onTempo(msg recvTempoMsg) tea.Cmd {
+	if msg.userID == m.userID {
+		return nil
+	}
+	sig := tempo.Signature{Beats: msg.msg.BeatsPerBar, Unit: msg.msg.BeatUnit}
+	if err := tempo.ValidateBPM(msg.msg.BPM); err != nil {
+		return func() tea.Msg { return rmxerr.ErrMsg{Err: fmt.Errorf("TempoMsg: %w", err)} }
+	}
+	if err := sig.Validate(); err != nil {
+		return func() tea.Msg { return rmxerr.ErrMsg{Err: fmt.Errorf("TempoMsg: %w", err)} }
+	}
+
+	delay := m.delayFrom(msg.userID)
+	elapsed := time.Duration(msg.msg.BarElapsedMS * float64(time.Millisecond))
+
+	changed := msg.msg.BPM != m.tempo.BPM || sig != m.tempo.Signature
+	m.tempoFrom = msg.userID
+	cmds := []tea.Cmd{m.setClock(tempo.Synced(msg.msg.BPM, sig, elapsed, delay, m.clock.Now()))}
+	// Resyncs for newcomers don't need announcing.
+	if changed {
+		var cmd tea.Cmd
+		info := fmt.Sprintf("%s set the tempo to %d BPM in %s", m.roster.name(msg.userID), msg.msg.BPM, sig)
+		m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: info})
+		cmds = append(cmds, cmd)
+	}
+	return tea.Batch(cmds...)
+}
+
+// KeepsTime reports whether we should bring newcomers in time: either we set the tempo,
+// or whoever did has left and we've been in the jam the longest.
+func (m model) keepsTime() bool {
+	if m.tempoFrom == m.userID {
+		return true
+	}
+	if _, ok := m.roster.users[m.tempoFrom]; ok {
+		return false
+	}
+	entries := m.roster.sorted()
+	return len(entries) > 0 && entries[0].info.UserID == m.userID
+}
+
+// SendTempoMessage shares our tempo and where we are in the bar.
+func (m model) sendTempoMessage() tea.Cmd {
+	c := m.tempo
+	return func() tea.Msg {
+		msg := wsmsg.TempoMsg{
+			BPM:          c.BPM,
+			BeatsPerBar:  c.Signature.Beats,
+			BeatUnit:     c.Signature.Unit,
+			BarElapsedMS: float64(c.BarElapsed(m.clock.Now())) / float64(time.Millisecond),
+		}
+		if err := m.sendEnvelope(wsmsg.TEMPO, msg); err != nil {
+			return rmxerr.ErrMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// RenderBeat shows a light per beat of the bar, with the current beat lit.
+func (m model) renderBeat() string {
+	lights := make([]string, m.tempo.Signature.Beats)
+	for i := range lights {
+		beat := i + 1
+		switch {
+		case beat != m.beat.Beat:
+			lights[i] = restStyle.Render("○")
+		case beat == 1:
+			lights[i] = downbeatStyle.Render("●")
+		default:
+			lights[i] = beatStyle.Render("●")
+		}
+	}
+	beat := strings.Join(lights, " ")
+	if m.counting {
+		beat += " " + countInStyle.Render(fmt.Sprintf("Count-in %d", m.beat.Beat))
+	}
+	return beat
+}
@@ -0,0 +1,290 @@
+package jamui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/keymap"
+)
+
+const (
+	// How far one key press moves a fader, in decibels, and a pan knob.
+	volumeStep = 1.0
+	panStep    = 0.1
+	// Width of a fader, in characters.
+	faderWidth = 18
+)
+
+var (
+	mixerKeys = struct {
+		Left, Right, Up, Down key.Binding
+		PanLeft, PanRight     key.Binding
+		Mute, Solo, Reset     key.Binding
+	}{
+		Left:     key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←/h", "previous strip")),
+		Right:    key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→/l", "next strip")),
+		Up:       key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "louder")),
+		Down:     key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "quieter")),
+		PanLeft:  key.NewBinding(key.WithKeys(","), key.WithHelp(",", "pan left")),
+		PanRight: key.NewBinding(key.WithKeys("."), key.WithHelp(".", "pan right")),
+		Mute:     key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "mute")),
+		Solo:     key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "solo")),
+		Reset:    key.NewBinding(key.WithKeys("0"), key.WithHelp("0", "reset")),
+	}
+
+	mixerNameStyle  = lipgloss.NewStyle().Width(14)
+	mixerFlagStyle  = lipgloss.NewStyle().Foreground(special).Bold(true)
+	mixerFaderStyle = lipgloss.NewStyle().Foreground(special)
+)
+
+// MixerStrips returns the players in the mixer, in roster order. The master fader comes after them.
+func (m model) mixerStrips() []uuid.UUID {
+	entries := m.roster.sorted()
+	ids := make([]uuid.UUID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.info.UserID
+	}
+	return ids
+}
+
+// UpdateMixer moves the mixer's controls while it has focus.
+func (m *model) updateMixer(msg tea.KeyMsg) tea.Cmd {
+	players := m.mixerStrips()
+	// The master fader is the last strip.
+	count := len(players) + 1
+	m.mixerCursor = (m.mixerCursor%count + count) % count
+	switch {
+	case key.Matches(msg, mixerKeys.Left):
+		m.mixerCursor = (m.mixerCursor - 1 + count) % count
+		return nil
+	case key.Matches(msg, mixerKeys.Right):
+		m.mixerCursor = (m.mixerCursor + 1) % count
+		return nil
+	}
+
+	master := m.mixerCursor == len(players)
+	var strip audio.Strip
+	if master {
+		strip = m.mixer.Master()
+	} else {
+		strip = m.mixer.Strip(players[m.mixerCursor])
+	}
+	switch {
+	case key.Matches(msg, mixerKeys.Up):
+		strip.Volume += volumeStep
+	case key.Matches(msg, mixerKeys.Down):
+		strip.Volume -= volumeStep
+	case key.Matches(msg, mixerKeys.PanLeft):
+		strip.Pan = math.Round((strip.Pan-panStep)*10) / 10
+	case key.Matches(msg, mixerKeys.PanRight):
+		strip.Pan = math.Round((strip.Pan+panStep)*10) / 10
+	case key.Matches(msg, mixerKeys.Mute):
+		strip.Mute = !strip.Mute
+	case key.Matches(msg, mixerKeys.Solo):
+		if master {
+			return nil
+		}
+		strip.Solo = !strip.Solo
+	case key.Matches(msg, mixerKeys.Reset):
+		strip = audio.Strip{Solo: strip.Solo}
+	default:
+		return nil
+	}
+	if master {
+		m.mixer.SetMaster(strip)
+	} else {
+		m.mixer.SetStrip(players[m.mixerCursor], strip)
+	}
+	return tea.Batch(m.saveMixer(), m.recordMixer())
+}
+
+// SaveMixer keeps the mixer settings for the next session.
+func (m model) saveMixer() tea.Cmd {
+	settings := m.mixer.Settings()
+	return m.mixerSaver.schedule(func() error {
+		path, err := audio.SettingsPath()
+		if err != nil {
+			return fmt.Errorf("audio.SettingsPath: %w", err)
+		}
+		return settings.Save(path)
+	})
+}
+
+// ToggleMute mutes or unmutes a player, returning whether they're now muted.
+func (m *model) toggleMute(player uuid.UUID) (bool, tea.Cmd) {
+	strip := m.mixer.Strip(player)
+	strip.Mute = !strip.Mute
+	m.mixer.SetStrip(player, strip)
+	return strip.Mute, tea.Batch(m.saveMixer(), m.recordMixer())
+}
+
+// RecordMixer adds the strips that changed to the recording, if there is one, so its stems
+// can be mixed the way the jam sounded.
+func (m *model) recordMixer() tea.Cmd {
+	if m.recorder == nil {
+		return nil
+	}
+	cmds := []tea.Cmd{m.recordStrip(uuid.Nil)}
+	for _, id := range m.mixerStrips() {
+		cmds = append(cmds, m.recordStrip(id))
+	}
+	return tea.Batch(cmds...)
+}
+
+// RecordStrip adds a player's strip to the recording if it's changed, uuid.Nil for the master.
+func (m *model) recordStrip(player uuid.UUID) tea.Cmd {
+	if m.recorder == nil {
+		return nil
+	}
+	name, strip := "", m.mixer.Master()
+	if player != uuid.Nil {
+		name, strip = m.roster.name(player), m.mixer.Strip(player)
+	}
+	if err := m.recorder.RecordStrip(player, name, strip); err != nil {
+		return m.notice("/record: %v", err)
+	}
+	return nil
+}
+
+// MixerHelp lists the mixer's keys.
+func mixerHelp() string {
+	bindings := []key.Binding{
+		mixerKeys.Left, mixerKeys.Up, mixerKeys.Down, mixerKeys.PanLeft, mixerKeys.PanRight,
+		mixerKeys.Mute, mixerKeys.Solo, mixerKeys.Reset, keymap.DefaultMapping.PlayStop,
+	}
+	help := make([]string, len(bindings))
+	for i, b := range bindings {
+		help[i] = fmt.Sprintf("%s %s", b.Help().Key, b.Help().Desc)
+	}
+	return strings.Join(help, " · ")
+}
+
+func (m model) renderMixer() string {
+	focused := m.focused == mixerFocus
+	lines := []string{
+		seqTitleStyle.Render("Mixer") + seqOffStyle.Render(" · "+m.renderLatency()),
+		seqOffStyle.Render(m.renderVoices()),
+	}
+
+	players := m.mixerStrips()
+	for i := 0; i <= len(players); i++ {
+		var (
+			name  string
+			strip audio.Strip
+			style = lipgloss.NewStyle().Bold(true)
+		)
+		if i == len(players) {
+			name, strip = "Master", m.mixer.Master()
+		} else {
+			id := players[i]
+			strip = m.mixer.Strip(id)
+			if e, ok := m.roster.users[id]; ok {
+				name = e.info.DisplayName
+				if e.info.Color != "" {
+					style = style.Foreground(lipgloss.Color(e.info.Color))
+				}
+			}
+			if id == m.userID {
+				name += " (you)"
+			}
+		}
+		name = mixerNameStyle.Render(style.Render(truncate(name, mixerNameStyle.GetWidth()-1)))
+		if focused && i == m.mixerCursor {
+			name = seqCursorStyle.Render(name)
+		}
+
+		flags := seqOffStyle.Render("M S")
+		switch {
+		case strip.Mute && strip.Solo:
+			flags = mixerFlagStyle.Render("M S")
+		case strip.Mute:
+			flags = mixerFlagStyle.Render("M") + seqOffStyle.Render(" S")
+		case strip.Solo:
+			flags = seqOffStyle.Render("M ") + mixerFlagStyle.Render("S")
+		}
+		lines = append(lines, fmt.Sprintf("%s%s %7s  %-4s %s",
+			name, renderFader(strip.Volume), formatVolume(strip.Volume), formatPan(strip.Pan), flags))
+	}
+
+	if focused {
+		lines = append(lines, seqOffStyle.Render(mixerHelp()))
+		return seqFocusedStyle.Render(strings.Join(lines, "\n"))
+	}
+	return seqStyle.Render(strings.Join(lines, "\n"))
+}
+
+// RenderLatency describes the output, ex: "48 kHz · 25ms latency (auto)". The latency is how long
+// a note takes from being rendered to reaching the output.
+func (m model) renderLatency() string {
+	latency := m.out.Buffer() + m.limiter.Latency()
+	s := fmt.Sprintf("%g kHz · %dms latency", float64(m.sampleRate)/1000, latency.Milliseconds())
+	if a, ok := m.out.(*audio.AutoTuned); ok {
+		s += " (auto"
+		if n := a.Underruns(); n > 0 {
+			s += fmt.Sprintf(", %d underruns", n)
+		}
+		s += ")"
+	}
+	return s
+}
+
+// RenderVoices shows how many notes are playing out of how many can, ex: "12/32 voices, 3 stolen".
+func (m model) renderVoices() string {
+	s := m.voices.Stats()
+	text := fmt.Sprintf("%d/%d voices (peak %d)", s.Active, s.Max, s.Peak)
+	if s.Stolen > 0 {
+		text += fmt.Sprintf(", %d stolen", s.Stolen)
+	}
+	return text
+}
+
+// RenderFader draws a volume as a bar, with a tick at 0 dB.
+func renderFader(volume float64) string {
+	span := audio.MaxVolume - audio.MinVolume
+	filled := int(math.Round((volume - audio.MinVolume) / span * faderWidth))
+	unity := int(math.Round(-audio.MinVolume / span * faderWidth))
+	var b strings.Builder
+	for i := 0; i < faderWidth; i++ {
+		switch {
+		case i < filled:
+			b.WriteString(mixerFaderStyle.Render("█"))
+		case i == unity:
+			b.WriteString(seqOffStyle.Render("|"))
+		default:
+			b.WriteString(seqOffStyle.Render("·"))
+		}
+	}
+	return b.String()
+}
+
+// FormatVolume writes a fader's level, ex: "-6 dB".
+func formatVolume(volume float64) string {
+	return fmt.Sprintf("%+.0f dB", volume)
+}
+
+// FormatPan writes a pan position, ex: "L20", "C" or "R100".
+func formatPan(pan float64) string {
+	n := int(math.Round(math.Abs(pan) * 100))
+	switch {
+	case n == 0:
+		return "C"
+	case pan < 0:
+		return fmt.Sprintf("L%d", n)
+	}
+	return fmt.Sprintf("R%d", n)
+}
+
+// Truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,41 @@
+package jamui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+// PerformNoteMsg fires when a strummed or arpeggiated note is due.
+type performNoteMsg struct {
+	msg wsmsg.MIDIMsg
+}
+
+// Perform plays a piano note through the performance mode, as a chord, strum or arpeggio.
+// Quantizing holds the whole performance back until the grid.
+func (m *model) perform(msg wsmsg.MIDIMsg) tea.Cmd {
+	now := m.clock.Now()
+	delay, off := m.quantize.Quantize(m.tempo, now)
+	m.quantizeOff = off
+
+	events := m.performer.Play(msg, m.tempo, now.Add(delay))
+	cmds := make([]tea.Cmd, 0, len(events))
+	for _, e := range events {
+		e.Offset += delay
+		if e.Offset <= 0 {
+			cmds = append(cmds, m.sendNote(e.MIDI))
+			continue
+		}
+		note := e.MIDI
+		cmds = append(cmds, tea.Tick(e.Offset, func(time.Time) tea.Msg { return performNoteMsg{msg: note} }))
+	}
+	return tea.Batch(cmds...)
+}
+
+// SendNote sends a note we played to the room, recording it into the loop.
+func (m *model) sendNote(msg wsmsg.MIDIMsg) tea.Cmd {
+	m.theory.Add(m.userID, msg, m.clock.Now())
+	m.loopNote(msg)
+	return tea.Batch(m.rollNote(m.userID, msg), m.sendMIDI(msg))
+}
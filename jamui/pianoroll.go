@@ -0,0 +1,103 @@
+package jamui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+// How often the piano roll scrolls.
+const rollRefresh = 100 * time.Millisecond
+
+// Piano roll rows, as a share of the terminal's height.
+const (
+	minRollRows = 3
+	maxRollRows = 10
+)
+
+var (
+	rollStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(subtle).
+			Padding(0, 1)
+	rollTitleStyle = lipgloss.NewStyle().Bold(true).Foreground(highlight)
+)
+
+// RollTickMsg scrolls the piano roll.
+type rollTickMsg struct{}
+
+// RollNote draws a note a player sent on the piano roll. Drums have no pitch to draw.
+func (m *model) rollNote(userID uuid.UUID, msg wsmsg.MIDIMsg) tea.Cmd {
+	if msg.Channel == midi.PercussionChannel {
+		return nil
+	}
+	now := m.clock.Now()
+	if msg.State == wsmsg.NOTE_OFF || msg.Velocity == 0 {
+		m.roll.NoteOff(userID.String(), msg.Number, now)
+		return nil
+	}
+	var color string
+	if e, ok := m.roster.users[userID]; ok {
+		color = e.info.Color
+	}
+	m.roll.NoteOn(userID.String(), msg.Number, color, now)
+	return m.scrollRoll()
+}
+
+// ScrollRoll keeps the piano roll moving while it has notes on it.
+func (m *model) scrollRoll() tea.Cmd {
+	if m.rollScrolling || m.rollHidden || m.rollPaused {
+		return nil
+	}
+	m.rollScrolling = true
+	return tea.Tick(rollRefresh, func(time.Time) tea.Msg { return rollTickMsg{} })
+}
+
+func (m *model) onRollTick() tea.Cmd {
+	m.rollScrolling = false
+	if !m.roll.Active(m.clock.Now()) {
+		return nil
+	}
+	return m.scrollRoll()
+}
+
+// PauseRoll freezes the piano roll, or starts it scrolling again. Notes keep being added while paused.
+func (m *model) pauseRoll() tea.Cmd {
+	m.rollPaused = !m.rollPaused
+	if m.rollPaused {
+		m.rollPausedAt = m.clock.Now()
+		return nil
+	}
+	return m.scrollRoll()
+}
+
+func (m model) renderRoll() string {
+	width := m.width
+	if width == 0 {
+		width = 80
+	}
+	// Leave room for the page's padding and the pane's border and padding.
+	width -= docStyle.GetHorizontalPadding() + rollStyle.GetHorizontalFrameSize()
+	rows := m.height / 5
+	if rows < minRollRows {
+		rows = minRollRows
+	}
+	if rows > maxRollRows {
+		rows = maxRollRows
+	}
+
+	now := m.clock.Now()
+	title := "Piano roll"
+	if m.rollPaused {
+		now = m.rollPausedAt
+		title += " (paused)"
+	}
+	return rollStyle.Render(lipgloss.JoinVertical(lipgloss.Left,
+		rollTitleStyle.Render(title),
+		m.roll.Render(width, rows, now),
+	))
+}
@@ -0,0 +1,51 @@
+package jamui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Cells either side of the grid line in the quantize meter.
+const quantizeCells = 3
+
+// Notes closer than this to the grid count as on it.
+const onGrid = 10 * time.Millisecond
+
+var offGridStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#F25D94"))
+
+// RenderQuantize shows the grid and how far the last note we played was from it, ex: "Q 1/16 ·●·|··· -42ms".
+func (m model) renderQuantize() string {
+	half := m.tempo.NoteLength(m.quantize.Value) / 2
+	pos := int(math.Round(float64(m.quantizeOff) / float64(half) * quantizeCells))
+	if pos < -quantizeCells {
+		pos = -quantizeCells
+	}
+	if pos > quantizeCells {
+		pos = quantizeCells
+	}
+
+	cells := make([]string, 0, 2*quantizeCells+1)
+	for i := -quantizeCells; i <= quantizeCells; i++ {
+		switch {
+		case i == pos && pos != 0:
+			cells = append(cells, offGridStyle.Render("●"))
+		case i == 0 && pos == 0:
+			cells = append(cells, beatStyle.Render("●"))
+		case i == 0:
+			cells = append(cells, "|")
+		default:
+			cells = append(cells, "·")
+		}
+	}
+
+	meter := strings.Join(cells, "")
+	offset := fmt.Sprintf("%+dms", m.quantizeOff.Milliseconds())
+	if m.quantizeOff > -onGrid && m.quantizeOff < onGrid {
+		offset = beatStyle.Render(offset)
+	}
+	return fmt.Sprintf("Q %s %s %s", m.quantize, meter, offset)
+}
@@ -0,0 +1,196 @@
+package jamui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/clock"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// A user is shown as playing if we heard a note from them within this window.
+	playingWindow = time.Second
+	// Our latency is announced again once it has moved this much since we last told the room,
+	// at most once per interval.
+	latencyChange   = 5 * time.Millisecond
+	latencyInterval = 5 * time.Second
+)
+
+var (
+	rosterStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(subtle).
+			Padding(0, 1).
+			MarginLeft(2)
+	rosterTitleStyle = lipgloss.NewStyle().Bold(true).Foreground(highlight)
+	rosterDimStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	playingStyle     = lipgloss.NewStyle().Foreground(special)
+)
+
+type (
+	rosterEntry struct {
+		info       wsmsg.UserInfo
+		joinedAt   time.Time
+		lastPlayed time.Time
+	}
+
+	// Roster tracks the players in the Jam Session.
+	roster struct {
+		users map[uuid.UUID]*rosterEntry
+		clock clock.Clock
+	}
+
+	// RosterRefreshMsg redraws the roster once a "playing" indicator may have expired.
+	rosterRefreshMsg struct{}
+
+	// LatencyMsg announces our latency once the interval since the last announcement is up.
+	latencyMsg struct{}
+)
+
+func newRoster(c clock.Clock) *roster {
+	return &roster{users: make(map[uuid.UUID]*rosterEntry), clock: c}
+}
+
+// Upsert adds the user or updates their details. It reports whether the user is new.
+func (r *roster) upsert(info wsmsg.UserInfo) bool {
+	if e, ok := r.users[info.UserID]; ok {
+		e.info = info
+		return false
+	}
+	r.users[info.UserID] = &rosterEntry{info: info, joinedAt: r.clock.Now()}
+	return true
+}
+
+// Remove drops the user from the roster, returning their last known details.
+func (r *roster) remove(userID uuid.UUID) (wsmsg.UserInfo, bool) {
+	e, ok := r.users[userID]
+	if !ok {
+		return wsmsg.UserInfo{}, false
+	}
+	delete(r.users, userID)
+	return e.info, true
+}
+
+// Replace swaps the whole roster for a server snapshot.
+func (r *roster) replace(users []wsmsg.UserInfo) {
+	prev := r.users
+	r.users = make(map[uuid.UUID]*rosterEntry, len(users))
+	for _, u := range users {
+		e := &rosterEntry{info: u, joinedAt: r.clock.Now()}
+		if old, ok := prev[u.UserID]; ok {
+			e.joinedAt = old.joinedAt
+			e.lastPlayed = old.lastPlayed
+		}
+		r.users[u.UserID] = e
+	}
+}
+
+func (r *roster) rename(userID uuid.UUID, name string) {
+	if e, ok := r.users[userID]; ok {
+		e.info.DisplayName = name
+	}
+}
+
+// Find looks up a user by display name, ignoring case.
+func (r *roster) find(name string) (wsmsg.UserInfo, bool) {
+	for _, e := range r.users {
+		if strings.EqualFold(e.info.DisplayName, name) {
+			return e.info, true
+		}
+	}
+	return wsmsg.UserInfo{}, false
+}
+
+// FindPrefix looks up the user whose display name starts the input, ignoring case,
+// and returns the rest of the input. The longest matching name wins so names may contain spaces.
+func (r *roster) findPrefix(input string) (wsmsg.UserInfo, string, bool) {
+	var (
+		found wsmsg.UserInfo
+		rest  string
+		ok    bool
+	)
+	for _, e := range r.users {
+		name := e.info.DisplayName
+		if len(input) < len(name) || !strings.EqualFold(input[:len(name)], name) {
+			continue
+		}
+		// The name must be a whole word.
+		if len(input) > len(name) && input[len(name)] != ' ' {
+			continue
+		}
+		if !ok || len(name) > len(found.DisplayName) {
+			found, rest, ok = e.info, strings.TrimSpace(input[len(name):]), true
+		}
+	}
+	return found, rest, ok
+}
+
+func (r *roster) played(userID uuid.UUID) {
+	if e, ok := r.users[userID]; ok {
+		e.lastPlayed = r.clock.Now()
+	}
+}
+
+func (r *roster) name(userID uuid.UUID) string {
+	if e, ok := r.users[userID]; ok {
+		return e.info.DisplayName
+	}
+	return "Someone"
+}
+
+// Sorted returns the roster entries in the order users joined.
+func (r *roster) sorted() []*rosterEntry {
+	entries := make([]*rosterEntry, 0, len(r.users))
+	for _, e := range r.users {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].joinedAt.Equal(entries[j].joinedAt) {
+			return entries[i].info.DisplayName < entries[j].info.DisplayName
+		}
+		return entries[i].joinedAt.Before(entries[j].joinedAt)
+	})
+	return entries
+}
+
+func (m model) renderRoster() string {
+	entries := m.roster.sorted()
+	lines := []string{rosterTitleStyle.Render(fmt.Sprintf("Players (%d)", len(entries)))}
+
+	for _, e := range entries {
+		info := e.info
+		latency := time.Duration(info.LatencyMS) * time.Millisecond
+		name := info.DisplayName
+		if info.UserID == m.userID {
+			name += " (you)"
+			latency = m.pingStats.Avg
+		}
+		if m.mixer.Strip(info.UserID).Mute {
+			name += " (muted)"
+		}
+
+		nameStyle := lipgloss.NewStyle().Bold(true)
+		if info.Color != "" {
+			nameStyle = nameStyle.Foreground(lipgloss.Color(info.Color))
+		}
+		playing := " "
+		if m.clock.Now().Sub(e.lastPlayed) < playingWindow {
+			playing = playingStyle.Render("♪")
+		}
+		ping := "--"
+		if latency > 0 {
+			ping = fmt.Sprintf("%dms", latency.Milliseconds())
+		}
+
+		lines = append(lines,
+			fmt.Sprintf("%s %s", playing, nameStyle.Render(name)),
+			rosterDimStyle.Render(fmt.Sprintf("  %s · %s", instrumentName(info.Instrument, info.Preset), ping)),
+		)
+	}
+	return rosterStyle.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,79 @@
+package jamui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/rmxerr"
+	"github.com/rapidmidiex/rmxtui/vpiano"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+type recvKeyMsg struct {
+	userID uuid.UUID
+	msg    wsmsg.KeyMsg
+}
+
+// SetScale locks the piano to a key, or unlocks it for the zero key.
+func (m *model) setScale(key vpiano.Key) {
+	m.scale = key
+	m.setOctave(m.octave)
+}
+
+// SetRoomKey sets the jam's key for everyone.
+func (m *model) setRoomKey(key vpiano.Key) tea.Cmd {
+	m.roomKey = key
+	if m.followKey {
+		m.setScale(key)
+	}
+	if key.IsZero() {
+		return tea.Batch(m.sendKeyMessage(), m.notice("The jam no longer has a key"))
+	}
+	return tea.Batch(m.sendKeyMessage(), m.notice("Set the jam's key to %s", key))
+}
+
+// OnKey follows the key another player set for the jam.
+func (m *model) onKey(msg recvKeyMsg) tea.Cmd {
+	if msg.userID == m.userID {
+		return nil
+	}
+	var key vpiano.Key
+	if msg.msg.Scale != "" {
+		scale, ok := vpiano.LookupScale(msg.msg.Scale)
+		if !ok || msg.msg.Root < 0 || msg.msg.Root > 11 {
+			return func() tea.Msg {
+				return rmxerr.ErrMsg{Err: fmt.Errorf("KeyMsg: bad key %d %q", msg.msg.Root, msg.msg.Scale)}
+			}
+		}
+		key = vpiano.Key{Root: msg.msg.Root, Scale: scale}
+	}
+	if key.Root == m.roomKey.Root && key.Scale.Name == m.roomKey.Scale.Name {
+		return nil
+	}
+
+	m.roomKey = key
+	if m.followKey {
+		m.setScale(key)
+	}
+	info := fmt.Sprintf("%s set the jam's key to %s", m.roster.name(msg.userID), key)
+	if key.IsZero() {
+		info = m.roster.name(msg.userID) + " cleared the jam's key"
+	}
+	var cmd tea.Cmd
+	m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: info})
+	return cmd
+}
+
+// SendKeyMessage shares the jam's key.
+func (m model) sendKeyMessage() tea.Cmd {
+	key := m.roomKey
+	return func() tea.Msg {
+		msg := wsmsg.KeyMsg{Root: key.Root, Scale: key.Scale.Name}
+		if err := m.sendEnvelope(wsmsg.KEY, msg); err != nil {
+			return rmxerr.ErrMsg{Err: err}
+		}
+		return nil
+	}
+}
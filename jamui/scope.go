@@ -0,0 +1,321 @@
+package jamui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/chatui"
+)
+
+const (
+	// How often the output pane is redrawn while there's sound, and while there isn't.
+	outputRefresh     = time.Second / 20
+	outputIdleRefresh = time.Second / 4
+	// Width of a level meter, and of the scope, in characters. The scope is as wide as a meter
+	// with its label and reading.
+	meterWidth = 24
+	scopeWidth = meterWidth + 8
+	scopeRows  = 4
+	// Lowest level the meters and the spectrum show, in dBFS.
+	meterFloor    = -48.0
+	spectrumFloor = -72.0
+	// How fast the meters fall back once the sound gets quieter, in dB a second, and how long
+	// the peak marker holds before it falls.
+	meterFall = 24.0
+	peakHold  = time.Second
+	// Frames drawn across the waveform, about 20ms, and analyzed for the spectrum.
+	waveFrames     = 1024
+	spectrumFrames = 2048
+	spectrumMinHz  = 40
+	spectrumMaxHz  = 16000
+)
+
+var (
+	// Eighths of a cell, filled from the left and from the bottom.
+	hBlocks = []rune(" ▏▎▍▌▋▊▉█")
+	vBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+	meterStyle     = lipgloss.NewStyle().Foreground(special)
+	meterLoudStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F87"))
+	meterOffStyle  = lipgloss.NewStyle().Foreground(subtle)
+)
+
+const (
+	waveScope scopeMode = iota
+	spectrumScope
+)
+
+type (
+	// ScopeMode is what the output pane draws under the meters.
+	scopeMode int
+
+	// OutputTickMsg takes a fresh look at the output. Ticks from before the pane was last
+	// started or stopped are ignored.
+	outputTickMsg struct {
+		gen int
+		at  time.Time
+	}
+
+	// Output is what the output pane shows, updated on each tick so View only draws it.
+	output struct {
+		hidden bool
+		mode   scopeMode
+		gen    int
+		last   time.Time
+		// Shown levels in dBFS, falling back slowly, and the held peaks.
+		rms, peak [2]float64
+		held      [2]float64
+		heldAt    [2]time.Time
+		reduction float64
+		// Lowest and highest sample in each column of the waveform.
+		wave [scopeWidth][2]float64
+		// Level of each band of the spectrum, in dBFS.
+		bands []float64
+		// Buffers taken from the scope.
+		frames [][2]float64
+		mono   []float64
+	}
+)
+
+func newOutput() *output {
+	o := &output{
+		frames: make([][2]float64, spectrumFrames),
+		mono:   make([]float64, spectrumFrames),
+		bands:  make([]float64, scopeWidth),
+	}
+	o.reset()
+	return o
+}
+
+func (o *output) reset() {
+	for c := range o.rms {
+		o.rms[c], o.peak[c], o.held[c] = math.Inf(-1), math.Inf(-1), math.Inf(-1)
+	}
+	for i := range o.bands {
+		o.bands[i] = math.Inf(-1)
+	}
+	o.wave = [scopeWidth][2]float64{}
+	o.reduction = 0
+}
+
+func (m scopeMode) String() string {
+	if m == spectrumScope {
+		return "spectrum"
+	}
+	return "wave"
+}
+
+// ScopeCommand shows or hides the output pane, or picks what its scope draws.
+func (m *model) scopeCommand(c chatui.CommandMsg) tea.Cmd {
+	switch c.Raw {
+	case "":
+		m.output.hidden = !m.output.hidden
+		if m.output.hidden {
+			m.output.gen++
+			return m.notice("Output meter hidden")
+		}
+		return tea.Batch(m.watchOutput(), m.notice("Output meter shown"))
+	case "wave":
+		m.output.mode = waveScope
+	case "spectrum":
+		m.output.mode = spectrumScope
+	default:
+		return m.usage(c)
+	}
+	var cmd tea.Cmd
+	if m.output.hidden {
+		m.output.hidden = false
+		cmd = m.watchOutput()
+	}
+	return tea.Batch(cmd, m.notice("Output scope shows the %s", m.output.mode))
+}
+
+// WatchOutput starts the output pane's ticks, replacing any already running.
+func (m *model) watchOutput() tea.Cmd {
+	if m.output.hidden {
+		return nil
+	}
+	m.output.gen++
+	m.output.last = time.Time{}
+	return m.outputTick(outputRefresh)
+}
+
+// StopOutput stops the output pane's ticks, until it's watched again.
+func (m *model) stopOutput() {
+	m.output.gen++
+	m.output.reset()
+}
+
+func (m *model) outputTick(d time.Duration) tea.Cmd {
+	gen := m.output.gen
+	return tea.Tick(d, func(t time.Time) tea.Msg { return outputTickMsg{gen: gen, at: t} })
+}
+
+// OnOutputTick reads the meter and scope on the output, at most a few dozen times a second
+// however much sound is played, and slows down while it's quiet.
+func (m *model) onOutputTick(msg outputTickMsg) tea.Cmd {
+	o := m.output
+	if msg.gen != o.gen {
+		return nil
+	}
+	fall := 0.0
+	if !o.last.IsZero() {
+		fall = meterFall * msg.at.Sub(o.last).Seconds()
+	}
+	o.last = msg.at
+
+	levels := m.meter.Levels()
+	for c := range levels.Peak {
+		o.rms[c] = math.Max(audio.Decibels(levels.RMS[c]), o.rms[c]-fall)
+		o.peak[c] = math.Max(audio.Decibels(levels.Peak[c]), o.peak[c]-fall)
+		if o.peak[c] >= o.held[c] || msg.at.Sub(o.heldAt[c]) > peakHold {
+			o.held[c], o.heldAt[c] = o.peak[c], msg.at
+		}
+	}
+	o.reduction = math.Max(m.limiter.Reduction(), o.reduction-fall)
+
+	frames := m.scope.Latest(o.frames)
+	for i, f := range frames {
+		o.mono[i] = (f[0] + f[1]) / 2
+	}
+	switch o.mode {
+	case waveScope:
+		wave := o.mono[len(o.mono)-waveFrames:]
+		per := waveFrames / scopeWidth
+		for col := range o.wave {
+			lo, hi := 0.0, 0.0
+			for _, v := range wave[col*per : (col+1)*per] {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+			o.wave[col] = [2]float64{lo, hi}
+		}
+	case spectrumScope:
+		for i, db := range audio.Spectrum(o.mono, m.sampleRate, scopeWidth, spectrumMinHz, spectrumMaxHz) {
+			o.bands[i] = math.Max(db, o.bands[i]-2*fall)
+		}
+	}
+
+	if o.quiet() {
+		return m.outputTick(outputIdleRefresh)
+	}
+	return m.outputTick(outputRefresh)
+}
+
+// Quiet reports whether the meters have fallen back to the floor.
+func (o *output) quiet() bool {
+	for c := range o.held {
+		if o.held[c] > meterFloor || o.peak[c] > meterFloor {
+			return false
+		}
+	}
+	return o.reduction <= 0
+}
+
+// RenderOutput shows the level of each channel, a scope, and how hard the limiter is working.
+func (m model) renderOutput() string {
+	o := m.output
+	title := seqTitleStyle.Render("Output") + seqOffStyle.Render(" · "+o.mode.String())
+	if o.reduction >= 0.1 {
+		title += seqOffStyle.Render(" · ") + meterLoudStyle.Render(fmt.Sprintf("-%.1f dB", o.reduction))
+	}
+	lines := []string{title}
+	for c, name := range []string{"L", "R"} {
+		lines = append(lines, name+" "+o.renderMeter(c))
+	}
+	if o.mode == spectrumScope {
+		lines = append(lines, o.renderSpectrum()...)
+	} else {
+		lines = append(lines, o.renderWave()...)
+	}
+	return seqStyle.Render(strings.Join(lines, "\n"))
+}
+
+// RenderMeter draws a channel's RMS level as a bar, its held peak as a mark past it, and the
+// peak's reading in dB.
+func (o *output) renderMeter(c int) string {
+	cells := func(db float64) float64 {
+		return math.Max(0, math.Min(1, (db-meterFloor)/-meterFloor)) * meterWidth
+	}
+	rms, held := cells(o.rms[c]), cells(o.held[c])
+	bar := make([]rune, meterWidth)
+	for i := range bar {
+		bar[i] = hBlocks[int(math.Max(0, math.Min(8, (rms-float64(i))*8)))]
+	}
+	mark := -1
+	if held > 0 {
+		mark = int(math.Min(meterWidth-1, held))
+	}
+
+	style := meterStyle
+	reading := seqOffStyle.Render("   -∞")
+	if o.held[c] > meterFloor {
+		if o.held[c] > -1 {
+			style = meterLoudStyle
+		}
+		reading = style.Render(fmt.Sprintf("%5.1f", o.held[c]))
+	}
+	var b strings.Builder
+	for i, r := range bar {
+		switch {
+		case i == mark && r == ' ':
+			b.WriteString(style.Render("│"))
+		case r == ' ':
+			b.WriteString(meterOffStyle.Render("·"))
+		default:
+			b.WriteString(style.Render(string(r)))
+		}
+	}
+	return b.String() + " " + reading
+}
+
+// RenderWave draws the waveform in half cells, each column spanning the lowest to the highest
+// sample under it.
+func (o *output) renderWave() []string {
+	const halves = scopeRows * 2
+	half := func(v float64) int {
+		return int(math.Max(0, math.Min(halves-1, (1-v)/2*halves)))
+	}
+	grid := make([][]rune, scopeRows)
+	for row := range grid {
+		grid[row] = []rune(strings.Repeat(" ", scopeWidth))
+	}
+	for col, span := range o.wave {
+		for h := half(span[1]); h <= half(span[0]); h++ {
+			row := grid[h/2]
+			switch {
+			case row[col] != ' ':
+				row[col] = '█'
+			case h%2 == 0:
+				row[col] = '▀'
+			default:
+				row[col] = '▄'
+			}
+		}
+	}
+	lines := make([]string, scopeRows)
+	for row := range grid {
+		lines[row] = meterStyle.Render(string(grid[row]))
+	}
+	return lines
+}
+
+// RenderSpectrum draws each band as a column of eighth blocks, low notes on the left.
+func (o *output) renderSpectrum() []string {
+	lines := make([]string, scopeRows)
+	for row := range lines {
+		// Eighths of the column below this row.
+		below := float64((scopeRows - 1 - row) * 8)
+		var b strings.Builder
+		for _, db := range o.bands {
+			eighths := math.Max(0, math.Min(1, (db-spectrumFloor)/-spectrumFloor)) * scopeRows * 8
+			b.WriteRune(vBlocks[int(math.Max(0, math.Min(8, eighths-below)))])
+		}
+		lines[row] = meterStyle.Render(b.String())
+	}
+	return lines
+}
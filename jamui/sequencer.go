@@ -0,0 +1,207 @@
+package jamui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/keymap"
+	"github.com/rapidmidiex/rmxtui/looper"
+	"github.com/rapidmidiex/rmxtui/sequencer"
+)
+
+var (
+	seqKeys = struct {
+		Up, Down, Left, Right key.Binding
+		Toggle, Accent, Clear key.Binding
+		Mute                  key.Binding
+	}{
+		Up:     key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "previous drum")),
+		Down:   key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "next drum")),
+		Left:   key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←/h", "previous step")),
+		Right:  key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→/l", "next step")),
+		Toggle: key.NewBinding(key.WithKeys(" ", "x"), key.WithHelp("space/x", "toggle step")),
+		Accent: key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "cycle accent")),
+		Clear:  key.NewBinding(key.WithKeys("C"), key.WithHelp("C", "clear pattern")),
+		Mute:   key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "mute drums")),
+	}
+
+	seqStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(subtle).
+			Padding(0, 1)
+	seqFocusedStyle = seqStyle.Copy().BorderForeground(highlight)
+	seqTitleStyle   = lipgloss.NewStyle().Bold(true).Foreground(highlight)
+	seqNameStyle    = lipgloss.NewStyle().Width(11)
+	seqOnStyle      = lipgloss.NewStyle().Foreground(special)
+	seqAccentStyle  = lipgloss.NewStyle().Foreground(special).Bold(true)
+	seqOffStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	seqPlayStyle    = lipgloss.NewStyle().Background(subtle)
+	seqCursorStyle  = lipgloss.NewStyle().Reverse(true)
+)
+
+type (
+	// StepMsg fires on every sixteenth note while the transport is playing.
+	stepMsg struct {
+		// Sequencer run the step was scheduled for. Steps from an earlier run are dropped.
+		gen int
+		at  time.Time
+	}
+
+	seqCursor struct {
+		track int
+		step  int
+	}
+)
+
+// RunSequencer starts playing the pattern in time with the transport.
+func (m *model) runSequencer() tea.Cmd {
+	m.seqGen++
+	return m.nextStep(m.clock.Now())
+}
+
+// NextStep waits for the first sixteenth after t.
+func (m model) nextStep(t time.Time) tea.Cmd {
+	at := m.tempo.NextNote(t, sequencer.StepValue)
+	gen := m.seqGen
+	return tea.Tick(at.Sub(m.clock.Now()), func(time.Time) tea.Msg {
+		return stepMsg{gen: gen, at: at}
+	})
+}
+
+// OnStep sends the pattern's hits and the loop's notes for the step to the room.
+func (m *model) onStep(msg stepMsg) tea.Cmd {
+	if msg.gen != m.seqGen {
+		return nil
+	}
+	if !m.transport.Playing() {
+		m.seqStep = -1
+		return nil
+	}
+	next := m.nextStep(msg.at)
+	if !m.transport.Started(msg.at) {
+		return next
+	}
+
+	// Steps drive the looper too, so loops stay on the same grid as the drums.
+	n := sequencer.Sixteenth(m.tempo.Signature, m.transport.Position(m.tempo, msg.at))
+	cmds := []tea.Cmd{next}
+	recording := m.looper.State() == looper.Recording
+	for _, note := range m.looper.Tick(n) {
+		cmds = append(cmds, m.sendMIDI(note))
+	}
+	if recording && m.looper.State() == looper.Idle {
+		cmds = append(cmds, m.notice("Nothing was played, the loop wasn't kept"))
+	}
+
+	step := sequencer.StepAt(m.tempo.Signature, m.transport.Position(m.tempo, msg.at))
+	m.seqStep = step
+	if m.seqMuted {
+		return tea.Batch(cmds...)
+	}
+	for _, hit := range m.pattern.Hits(step) {
+		cmds = append(cmds, m.sendMIDI(hit))
+	}
+	return tea.Batch(cmds...)
+}
+
+// UpdateSequencer edits the pattern while the sequencer has focus.
+func (m *model) updateSequencer(msg tea.KeyMsg) tea.Cmd {
+	c := &m.seqCursor
+	switch {
+	case key.Matches(msg, seqKeys.Up):
+		c.track = (c.track - 1 + len(m.pattern.Tracks)) % len(m.pattern.Tracks)
+	case key.Matches(msg, seqKeys.Down):
+		c.track = (c.track + 1) % len(m.pattern.Tracks)
+	case key.Matches(msg, seqKeys.Left):
+		c.step = (c.step - 1 + sequencer.Steps) % sequencer.Steps
+	case key.Matches(msg, seqKeys.Right):
+		c.step = (c.step + 1) % sequencer.Steps
+	case key.Matches(msg, seqKeys.Toggle):
+		m.pattern.Toggle(c.track, c.step)
+		return m.auditionStep()
+	case key.Matches(msg, seqKeys.Accent):
+		m.pattern.Cycle(c.track, c.step)
+		return m.auditionStep()
+	case key.Matches(msg, seqKeys.Clear):
+		m.pattern.Clear()
+	case key.Matches(msg, seqKeys.Mute):
+		m.seqMuted = !m.seqMuted
+	}
+	return nil
+}
+
+// AuditionStep plays the drum under the cursor locally, so you can hear what you're adding.
+func (m *model) auditionStep() tea.Cmd {
+	if m.transport.Playing() {
+		return nil
+	}
+	hit, ok := m.pattern.Tracks[m.seqCursor.track].Hit(m.seqCursor.step)
+	if !ok {
+		return nil
+	}
+	return m.playMIDI(uuid.Nil, hit)
+}
+
+// LoadPattern replaces the pattern, keeping the cursor on the grid.
+func (m *model) loadPattern(p sequencer.Pattern) {
+	m.pattern = p
+	if m.seqCursor.track >= len(p.Tracks) {
+		m.seqCursor.track = 0
+	}
+}
+
+// SeqHelp lists the sequencer's editing keys.
+func seqHelp() string {
+	bindings := []key.Binding{seqKeys.Toggle, seqKeys.Accent, seqKeys.Clear, seqKeys.Mute, keymap.DefaultMapping.PlayStop}
+	help := make([]string, len(bindings))
+	for i, b := range bindings {
+		help[i] = fmt.Sprintf("%s %s", b.Help().Key, b.Help().Desc)
+	}
+	return strings.Join(help, " · ")
+}
+
+func (m model) renderSequencer() string {
+	focused := m.focused == seqFocus
+	title := "Drums · " + m.pattern.Name
+	if m.seqMuted {
+		title += " (muted)"
+	}
+	lines := []string{seqTitleStyle.Render(title)}
+
+	for i, t := range m.pattern.Tracks {
+		cells := make([]string, sequencer.Steps)
+		for j, s := range t.Steps {
+			var cell string
+			switch s {
+			case sequencer.On:
+				cell = seqOnStyle.Render("■")
+			case sequencer.Accent:
+				cell = seqAccentStyle.Render("█")
+			default:
+				cell = seqOffStyle.Render("·")
+				if j%4 == 0 {
+					cell = seqOffStyle.Render("○")
+				}
+			}
+			switch {
+			case focused && i == m.seqCursor.track && j == m.seqCursor.step:
+				cell = seqCursorStyle.Render(cell)
+			case j == m.seqStep:
+				cell = seqPlayStyle.Render(cell)
+			}
+			cells[j] = cell
+		}
+		lines = append(lines, seqNameStyle.Render(t.Name)+strings.Join(cells, " "))
+	}
+
+	if focused {
+		lines = append(lines, seqOffStyle.Render(seqHelp()))
+		return seqFocusedStyle.Render(strings.Join(lines, "\n"))
+	}
+	return seqStyle.Render(strings.Join(lines, "\n"))
+}
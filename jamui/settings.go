@@ -0,0 +1,58 @@
+package jamui
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/rmxerr"
+)
+
+// How long settings wait after the last change before they're saved, so holding a key down
+// saves once.
+const saveDelay = 500 * time.Millisecond
+
+type (
+	// Saver saves a settings file a moment after the last change to it, one save at a time.
+	saver struct {
+		name string
+		// Bumped on every change, so only the last one's save runs.
+		gen int
+		mu  sync.Mutex
+	}
+
+	// SaveDueMsg fires once a change to the settings has had time to settle.
+	saveDueMsg struct {
+		saver *saver
+		gen   int
+		save  func() error
+	}
+)
+
+func newSaver(name string) *saver {
+	return &saver{name: name}
+}
+
+// Schedule saves the settings with save, unless they change again within saveDelay.
+func (s *saver) schedule(save func() error) tea.Cmd {
+	s.gen++
+	gen := s.gen
+	return tea.Tick(saveDelay, func(time.Time) tea.Msg { return saveDueMsg{saver: s, gen: gen, save: save} })
+}
+
+// OnSaveDue saves the settings if they haven't changed since.
+func onSaveDue(msg saveDueMsg) tea.Cmd {
+	s := msg.saver
+	if msg.gen != s.gen {
+		return nil
+	}
+	return func() tea.Msg {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := msg.save(); err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("save %s settings: %w", s.name, err)}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,31 @@
+package jamui
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/rmxerr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaver(t *testing.T) {
+	s := newSaver("mixer")
+	var saved []int
+	save := func(v int) func() error {
+		return func() error {
+			saved = append(saved, v)
+			return nil
+		}
+	}
+
+	// Holding a key saves once, what it was last set to.
+	s.schedule(save(1))
+	s.schedule(save(2))
+	require.Nil(t, onSaveDue(saveDueMsg{saver: s, gen: 1, save: save(1)}))
+	require.Nil(t, onSaveDue(saveDueMsg{saver: s, gen: 2, save: save(2)})())
+	require.Equal(t, []int{2}, saved)
+
+	s.schedule(func() error { return errors.New("disk full") })
+	msg := onSaveDue(saveDueMsg{saver: s, gen: 3, save: func() error { return errors.New("disk full") }})()
+	require.EqualError(t, msg.(rmxerr.ErrMsg).Err, "save mixer settings: disk full")
+}
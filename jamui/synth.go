@@ -0,0 +1,41 @@
+package jamui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/midi"
+)
+
+// SynthCommand picks a built-in synth preset to play instead of a General MIDI instrument, or
+// goes back to the instrument.
+func (m *model) synthCommand(c chatui.CommandMsg) tea.Cmd {
+	switch c.Raw {
+	case "":
+		return m.notice("Playing %s. Presets are %s", instrumentName(m.instrument, m.preset), strings.Join(midi.PresetNames(), ", "))
+	case "off":
+		m.preset = ""
+	default:
+		p, err := midi.FindPreset(c.Raw)
+		if err != nil {
+			return m.notice("/synth: %v", err)
+		}
+		m.preset = p.Name
+	}
+	m.roster.upsert(m.selfInfo())
+	return tea.Batch(
+		m.notice("Playing %s", instrumentName(m.instrument, m.preset)),
+		// Let the others update their rosters.
+		m.sendUserUpdate(),
+	)
+}
+
+// InstrumentName names what a player plays: a built-in synth preset, or their General MIDI
+// instrument.
+func instrumentName(program int, preset string) string {
+	if preset != "" {
+		return preset + " synth"
+	}
+	return midi.ProgramName(program)
+}
@@ -0,0 +1,25 @@
+package jamui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	theoryStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	theoryValueStyle = lipgloss.NewStyle().Foreground(special).Bold(true)
+)
+
+// RenderTheory names the chord sounding in the jam and the key it seems to be in.
+func (m model) renderTheory() string {
+	now := m.clock.Now()
+	chord, ok := m.theory.Chord(now)
+	if !ok {
+		chord = "–"
+	}
+	key := "–"
+	if k, ok := m.theory.Key(now); ok {
+		key = k.String()
+	}
+	return theoryStyle.Render("Chord ") + theoryValueStyle.Render(chord) +
+		theoryStyle.Render(" · Key ") + theoryValueStyle.Render(key)
+}
@@ -0,0 +1,203 @@
+package jamui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/rmxerr"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+// How often the song position is redrawn while playing.
+const positionRefresh = 50 * time.Millisecond
+
+var playingStateStyle = lipgloss.NewStyle().Foreground(special).Bold(true)
+
+type (
+	// PositionTickMsg redraws the song position while playing.
+	positionTickMsg struct {
+		gen int
+	}
+
+	recvTransportMsg struct {
+		userID uuid.UUID
+		msg    wsmsg.TransportMsg
+	}
+)
+
+// Play starts the room's playback on the next downbeat.
+func (m *model) play() tea.Cmd {
+	if m.transport.Playing() {
+		return m.notice("Already playing")
+	}
+	m.transport.Play(m.tempo, m.clock.Now())
+	return tea.Batch(
+		m.sendTransportMessage(wsmsg.TRANSPORT_PLAY),
+		m.followTransport(),
+		m.notice("Playing from bar %d on the next downbeat", m.transport.Bar()),
+	)
+}
+
+// Stop stops the room's playback.
+func (m *model) stop() tea.Cmd {
+	if !m.transport.Playing() {
+		return m.notice("Already stopped")
+	}
+	now := m.clock.Now()
+	m.transport.Stop(m.tempo, now)
+	m.looper.Interrupt()
+	return tea.Batch(
+		m.sendTransportMessage(wsmsg.TRANSPORT_STOP),
+		m.notice("Stopped at %s", m.transport.Position(m.tempo, now)),
+	)
+}
+
+// Locate moves the room's song position to the top of a bar.
+func (m *model) locate(bar int) tea.Cmd {
+	m.transport.Locate(m.tempo, bar, m.clock.Now())
+	m.looper.Interrupt()
+	cmds := []tea.Cmd{
+		m.sendTransportMessage(wsmsg.TRANSPORT_LOCATE),
+		m.notice("Moved to bar %d", bar),
+	}
+	if m.transport.Playing() {
+		cmds = append(cmds, m.followTransport())
+	}
+	return tea.Batch(cmds...)
+}
+
+// FollowTransport starts everything that runs while the transport plays.
+func (m *model) followTransport() tea.Cmd {
+	return tea.Batch(m.watchPosition(), m.runSequencer())
+}
+
+// WatchPosition redraws the song position until playback stops.
+func (m *model) watchPosition() tea.Cmd {
+	m.positionGen++
+	gen := m.positionGen
+	return tea.Tick(positionRefresh, func(time.Time) tea.Msg { return positionTickMsg{gen: gen} })
+}
+
+func (m *model) onPositionTick(msg positionTickMsg) tea.Cmd {
+	if msg.gen != m.positionGen || !m.transport.Playing() {
+		return nil
+	}
+	return m.watchPosition()
+}
+
+// OnTransport follows another player's play, stop or locate.
+func (m *model) onTransport(msg recvTransportMsg) tea.Cmd {
+	if msg.userID == m.userID {
+		return nil
+	}
+	if msg.msg.Bar < 1 {
+		return func() tea.Msg { return rmxerr.ErrMsg{Err: fmt.Errorf("TransportMsg: bad bar %d", msg.msg.Bar)} }
+	}
+
+	now := m.clock.Now()
+	start := now.Add(-m.delayFrom(msg.userID) + time.Duration(msg.msg.StartInMS*float64(time.Millisecond)))
+	name := m.roster.name(msg.userID)
+	wasPlaying := m.transport.Playing()
+
+	var info string
+	switch msg.msg.Action {
+	case wsmsg.TRANSPORT_PLAY:
+		m.transport.PlayAt(m.tempo, msg.msg.Bar, start)
+		// Catching up a newcomer doesn't need announcing.
+		if !wasPlaying {
+			info = name + " started playback"
+		}
+
+	case wsmsg.TRANSPORT_STOP:
+		m.transport.Stop(m.tempo, now)
+		if m.transport.Bar() != msg.msg.Bar {
+			m.transport.Locate(m.tempo, msg.msg.Bar, now)
+		}
+		info = name + " stopped playback"
+
+	case wsmsg.TRANSPORT_LOCATE:
+		if msg.msg.Playing {
+			m.transport.PlayAt(m.tempo, msg.msg.Bar, start)
+		} else {
+			m.transport.Stop(m.tempo, now)
+			m.transport.Locate(m.tempo, msg.msg.Bar, now)
+		}
+		info = fmt.Sprintf("%s moved to bar %d", name, msg.msg.Bar)
+	}
+
+	if msg.msg.Action != wsmsg.TRANSPORT_PLAY {
+		m.looper.Interrupt()
+	}
+
+	var cmds []tea.Cmd
+	if info != "" {
+		var cmd tea.Cmd
+		m.chatBox, cmd = m.chatBox.Update(chatui.InfoMsg{Msg: info})
+		cmds = append(cmds, cmd)
+	}
+	if m.transport.Playing() && !wasPlaying {
+		cmds = append(cmds, m.followTransport())
+	}
+	return tea.Batch(cmds...)
+}
+
+// SendTransportMessage shares our transport state.
+func (m model) sendTransportMessage(action wsmsg.TransportAction) tea.Cmd {
+	t := m.transport
+	return func() tea.Msg {
+		msg := wsmsg.TransportMsg{
+			Action:  action,
+			Bar:     t.Bar(),
+			Playing: t.Playing(),
+		}
+		if t.Playing() {
+			msg.StartInMS = float64(t.Start().Sub(m.clock.Now())) / float64(time.Millisecond)
+		}
+		if err := m.sendEnvelope(wsmsg.TRANSPORT, msg); err != nil {
+			return rmxerr.ErrMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// SyncNewcomer brings a player who just joined in time with the room.
+func (m model) syncNewcomer() tea.Cmd {
+	cmds := []tea.Cmd{m.sendTempoMessage()}
+	// The transport only makes sense once the newcomer has our tempo.
+	if m.transport.Playing() {
+		cmds = append(cmds, m.sendTransportMessage(wsmsg.TRANSPORT_PLAY))
+	}
+	if !m.roomKey.IsZero() {
+		cmds = append(cmds, m.sendKeyMessage())
+	}
+	return tea.Sequence(cmds...)
+}
+
+// DelayFrom estimates how long messages from another player take to reach us.
+func (m model) delayFrom(userID uuid.UUID) time.Duration {
+	var senderRTT time.Duration
+	if e, ok := m.roster.users[userID]; ok {
+		senderRTT = time.Duration(e.info.LatencyMS) * time.Millisecond
+	}
+	return tempo.Delay(senderRTT, m.pingStats.Avg)
+}
+
+// RenderTransport shows whether we're playing and the song position as bar:beat:tick.
+func (m model) renderTransport() string {
+	now := m.clock.Now()
+	pos := m.transport.Position(m.tempo, now).String()
+	switch {
+	case m.transport.Started(now):
+		return playingStateStyle.Render("▶ " + pos)
+	case m.transport.Playing():
+		// Waiting for the downbeat.
+		return playingStateStyle.Render("▷ " + pos)
+	default:
+		return "■ " + pos
+	}
+}
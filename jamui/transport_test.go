@@ -0,0 +1,113 @@
+package jamui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/chatui"
+	"github.com/rapidmidiex/rmxtui/clock"
+	"github.com/rapidmidiex/rmxtui/looper"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/transport"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+var origin = time.Date(2023, 2, 4, 20, 0, 0, 0, time.UTC)
+
+// TestModel is a jam at 120 BPM in 4/4, a beat every 500ms, on a clock that only moves when
+// the test says. Nothing is connected, and notes are only ever given voices, never rendered.
+func testModel(c clock.Clock) model {
+	return model{
+		chatBox:   chatui.New(),
+		roster:    newRoster(c),
+		tempo:     tempo.NewClock(120, tempo.CommonTime, origin),
+		transport: transport.New(),
+		clock:     c,
+		looper:    looper.New(),
+		voices:    midi.NewVoices(midi.VoicesOpts{}),
+		renders:   make(chan struct{}, 1),
+	}
+}
+
+// Clicks counts the notes given a voice so far.
+func clicks(m model) int {
+	s := m.voices.Stats()
+	return s.Active + s.Retriggered
+}
+
+func TestFollowTransport(t *testing.T) {
+	c := clock.NewFake(origin.Add(1500 * time.Millisecond))
+	m := testModel(c)
+	jen := uuid.New()
+	m.roster.upsert(wsmsg.UserInfo{UserID: jen, DisplayName: "Jen", LatencyMS: 60})
+	m.pingStats.Avg = 20 * time.Millisecond
+
+	// Jen's play reached us 40ms after she sent it, so bar 3 starts on our next downbeat.
+	m.onTransport(recvTransportMsg{userID: jen, msg: wsmsg.TransportMsg{
+		Action:    wsmsg.TRANSPORT_PLAY,
+		Bar:       3,
+		Playing:   true,
+		StartInMS: 540,
+	}})
+	require.True(t, m.transport.Playing())
+	require.Equal(t, origin.Add(2*time.Second), m.transport.Start())
+	require.False(t, m.transport.Started(c.Now()), "waiting for the downbeat")
+
+	c.Advance(500 * time.Millisecond)
+	require.True(t, m.transport.Started(c.Now()))
+	require.Equal(t, "3:1:000", m.transport.Position(m.tempo, c.Now()).String())
+
+	c.Advance(750 * time.Millisecond)
+	require.Equal(t, "3:2:240", m.transport.Position(m.tempo, c.Now()).String())
+
+	// Our own messages come back from the server, and are already in effect.
+	m.onTransport(recvTransportMsg{userID: m.userID, msg: wsmsg.TransportMsg{Action: wsmsg.TRANSPORT_STOP, Bar: 1}})
+	require.True(t, m.transport.Playing())
+
+	m.onTransport(recvTransportMsg{userID: jen, msg: wsmsg.TransportMsg{Action: wsmsg.TRANSPORT_STOP, Bar: 3}})
+	require.False(t, m.transport.Playing())
+	c.Advance(time.Second)
+	require.Equal(t, "3:2:240", m.transport.Position(m.tempo, c.Now()).String(), "stopped where it was")
+}
+
+func TestMetronomeCountIn(t *testing.T) {
+	// Halfway through the first beat.
+	c := clock.NewFake(origin.Add(250 * time.Millisecond))
+	m := testModel(c)
+	m.countIn = m.tempo.Signature.Beats
+
+	// Plays each beat up to the next bar's downbeat, reporting which clicked.
+	beats := func(n int) []bool {
+		var clicked []bool
+		for i := 0; i < n; i++ {
+			at, pos := m.tempo.NextBeat(c.Now())
+			c.Set(at)
+			before := clicks(m)
+			m.onBeat(beatMsg{gen: m.beatGen, at: at, pos: pos})
+			require.Equal(t, pos, m.beat)
+			clicked = append(clicked, clicks(m) > before)
+		}
+		return clicked
+	}
+
+	// The count-in waits for the downbeat, clicks a bar, then stops.
+	require.Equal(t, []bool{false, false, false}, beats(3))
+	require.Equal(t, []bool{true, true, true, true}, beats(4))
+	require.Zero(t, m.countIn)
+	require.Equal(t, []bool{false, false}, beats(2))
+
+	// With the metronome on, every beat clicks.
+	m.metronome = true
+	require.Equal(t, []bool{true, true}, beats(2))
+
+	// Beats from a clock that's since been replaced are dropped.
+	m.setClock(tempo.NewClock(90, tempo.CommonTime, c.Now()))
+	before := clicks(m)
+	at, pos := c.Now().Add(time.Second), tempo.Position{Bar: 9, Beat: 1}
+	m.onBeat(beatMsg{gen: m.beatGen - 1, at: at, pos: pos})
+	require.Equal(t, before, clicks(m))
+	require.NotEqual(t, pos, m.beat)
+}
@@ -9,6 +9,14 @@ type Mapping struct {
 	CycleFocus key.Binding
 	GoBack     key.Binding
 	Quit       key.Binding
+	// Tap along to set the jam tempo.
+	TapTempo key.Binding
+	// Start or stop the jam's playback.
+	PlayStop key.Binding
+	// Record a loop, or overdub the one playing.
+	Loop key.Binding
+	// Freeze the piano roll.
+	PauseRoll key.Binding
 }
 
 var DefaultMapping = Mapping{
@@ -24,4 +32,20 @@ var DefaultMapping = Mapping{
 		key.WithKeys(tea.KeyCtrlC.String()),
 		key.WithHelp("ctrl+c", "quit"),
 	),
+	TapTempo: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "tap tempo"),
+	),
+	PlayStop: key.NewBinding(
+		key.WithKeys(tea.KeyEnter.String()),
+		key.WithHelp("enter", "play/stop"),
+	),
+	Loop: key.NewBinding(
+		key.WithKeys(tea.KeyCtrlR.String()),
+		key.WithHelp("ctrl+r", "record/overdub loop"),
+	),
+	PauseRoll: key.NewBinding(
+		key.WithKeys(tea.KeyCtrlP.String()),
+		key.WithHelp("ctrl+p", "pause piano roll"),
+	),
 }
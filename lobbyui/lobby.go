@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,9 +36,8 @@ type jamCreated struct {
 // Commands
 func (m Model) listJams() tea.Cmd {
 	return func() tea.Msg {
-		// Create an HTTP client and make a GET request.
-		c := &http.Client{Timeout: 10 * time.Second}
-		res, err := c.Get(m.apiURL + "/jam")
+		// Make a GET request with the (possibly authenticated) API client.
+		res, err := m.client.Get(m.apiURL + "/jam")
 		if err != nil {
 			// There was an error making our request. Wrap the error we received
 			// in a message and return it.
@@ -62,7 +60,8 @@ func (m Model) listJams() tea.Cmd {
 }
 
 type Model struct {
-	apiURL   string // REST API base endpoint
+	apiURL   string       // REST API base endpoint
+	client   *http.Client // REST API client, attaches credentials when logged in
 	jams     []Jam
 	jamTable table.Model
 	help     tea.Model
@@ -70,9 +69,10 @@ type Model struct {
 	// log      log.Logger
 }
 
-func New(apiURL string) tea.Model {
+func New(apiURL string, client *http.Client) tea.Model {
 	return Model{
 		apiURL:  apiURL,
+		client:  client,
 		help:    NewHelpModel(),
 		loading: true,
 		// log:     *log.Default(),
@@ -107,7 +107,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, jamSelect(jamID))
 		case "n":
 			// Create new Jam Session
-			cmds = append(cmds, jamCreate(m.apiURL))
+			cmds = append(cmds, jamCreate(m.client, m.apiURL))
 		}
 	}
 	newJamTable, jtCmd := m.jamTable.Update(msg)
@@ -196,13 +196,13 @@ func jamSelect(id string) tea.Cmd {
 	}
 }
 
-func jamCreate(baseURL string) tea.Cmd {
+func jamCreate(client *http.Client, baseURL string) tea.Cmd {
 	// For now, we're just creating the Jam Session without
 	// and options.
 	// Next step would be to show inputs for Jam details
 	// (name, bpm, etc) before creating the Jam.
 	return func() tea.Msg {
-		resp, err := http.Post(baseURL+"/jam", "application/json", strings.NewReader("{}"))
+		resp, err := client.Post(baseURL+"/jam", "application/json", strings.NewReader("{}"))
 		if err != nil {
 			return rmxerr.ErrMsg{Err: fmt.Errorf("jamCreate: %v", err)}
 		}
@@ -0,0 +1,175 @@
+// Package loginui contains the username/password prompt for logging in to the RMX API.
+package loginui
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidmidiex/rmxtui/auth"
+	"github.com/rapidmidiex/rmxtui/styles"
+	"golang.org/x/term"
+)
+
+var (
+	docStyle   = styles.DocStyle
+	titleStyle = styles.BoldStyle.Copy().MarginBottom(1)
+	hintStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+)
+
+type (
+	// LoggedInMsg is sent once the server accepted the user's credentials.
+	LoggedInMsg struct{}
+
+	// SkippedMsg is sent when the user chooses to continue without logging in.
+	SkippedMsg struct{}
+
+	loginFailedMsg struct {
+		err error
+	}
+
+	keyMap struct {
+		NextField key.Binding
+		PrevField key.Binding
+		Submit    key.Binding
+		Skip      key.Binding
+	}
+
+	model struct {
+		keys       keyMap
+		client     *auth.Client
+		inputs     []textinput.Model
+		focusIdx   int
+		submitting bool
+		err        error
+	}
+)
+
+const (
+	usernameField = iota
+	passwordField
+)
+
+var keys = keyMap{
+	NextField: key.NewBinding(
+		key.WithKeys("tab", "down"),
+		key.WithHelp("tab", "next field"),
+	),
+	PrevField: key.NewBinding(
+		key.WithKeys("shift+tab", "up"),
+		key.WithHelp("shift+tab", "previous field"),
+	),
+	Submit: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "log in"),
+	),
+	Skip: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "continue anonymously"),
+	),
+}
+
+func New(client *auth.Client) model {
+	username := textinput.New()
+	username.Placeholder = "Username"
+	username.Focus()
+
+	password := textinput.New()
+	password.Placeholder = "Password"
+	password.EchoMode = textinput.EchoPassword
+	password.EchoCharacter = '•'
+
+	return model{
+		keys:   keys,
+		client: client,
+		inputs: []textinput.Model{username, password},
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case loginFailedMsg:
+		m.submitting = false
+		m.err = msg.err
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.submitting {
+			return m, nil
+		}
+		switch {
+		case key.Matches(msg, m.keys.Skip):
+			return m, func() tea.Msg { return SkippedMsg{} }
+		case key.Matches(msg, m.keys.NextField):
+			return m, m.focus((m.focusIdx + 1) % len(m.inputs))
+		case key.Matches(msg, m.keys.PrevField):
+			return m, m.focus((m.focusIdx - 1 + len(m.inputs)) % len(m.inputs))
+		case key.Matches(msg, m.keys.Submit):
+			if m.focusIdx == usernameField {
+				return m, m.focus(passwordField)
+			}
+			m.submitting = true
+			m.err = nil
+			return m, m.login(
+				strings.TrimSpace(m.inputs[usernameField].Value()),
+				m.inputs[passwordField].Value(),
+			)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focusIdx], cmd = m.inputs[m.focusIdx].Update(msg)
+	return m, cmd
+}
+
+func (m model) View() string {
+	physicalWidth, _, _ := term.GetSize(int(os.Stdout.Fd()))
+	doc := strings.Builder{}
+
+	doc.WriteString(titleStyle.Render("Log in to RMX") + "\n")
+	for _, in := range m.inputs {
+		doc.WriteString(in.View() + "\n")
+	}
+	doc.WriteString("\n")
+
+	if m.submitting {
+		doc.WriteString("Logging in...\n\n")
+	}
+	if m.err != nil {
+		doc.WriteString(styles.RenderError(m.err.Error()) + "\n\n")
+	}
+	doc.WriteString(hintStyle.Render("tab next field • enter log in • esc continue anonymously"))
+
+	if physicalWidth > 0 {
+		docStyle = styles.DocStyle.MaxWidth(physicalWidth)
+	}
+	return docStyle.Render(doc.String())
+}
+
+// Focus moves the cursor to the input at idx.
+// The inputs slice is shared between copies of the model, so it can be updated in place.
+func (m *model) focus(idx int) tea.Cmd {
+	m.inputs[m.focusIdx].Blur()
+	m.focusIdx = idx
+	return m.inputs[idx].Focus()
+}
+
+func (m model) login(username, password string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := m.client.Login(ctx, username, password); err != nil {
+			return loginFailedMsg{err: err}
+		}
+		return LoggedInMsg{}
+	}
+}
@@ -0,0 +1,234 @@
+// Package looper records phrases played on the piano and loops them back in time with the song.
+//
+// Times are counted in sixteenth notes from the top of the song, so a loop lines up with the
+// transport and the drum machine. Recorded notes are quantized to the nearest sixteenth.
+package looper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// Idle has nothing recorded.
+	Idle State = iota
+	// Armed waits for the next downbeat to start recording.
+	Armed
+	// Recording records the first layer, which sets the loop length.
+	Recording
+	// Playing loops the recorded layers.
+	Playing
+	// Overdubbing loops the layers while recording another on top.
+	Overdubbing
+)
+
+// Longest loop allowed.
+const MaxBars = 16
+
+var (
+	ErrBusy       = errors.New("the looper is already recording, clear it first")
+	ErrNotPlaying = errors.New("nothing is looping yet")
+)
+
+type (
+	State int
+
+	// Event is a note in a layer.
+	Event struct {
+		// Sixteenths from the start of the loop.
+		Step int
+		MIDI wsmsg.MIDIMsg
+	}
+
+	// Layer is one recorded pass.
+	Layer []Event
+
+	Looper struct {
+		state State
+		// Loop length and the song sixteenth it first started on.
+		length int
+		start  int
+		bars   int
+		layers []Layer
+		// Layer being recorded.
+		take Layer
+	}
+)
+
+func (s State) String() string {
+	switch s {
+	case Armed:
+		return "armed"
+	case Recording:
+		return "recording"
+	case Playing:
+		return "playing"
+	case Overdubbing:
+		return "overdubbing"
+	}
+	return "idle"
+}
+
+// New returns an empty looper.
+func New() *Looper {
+	return &Looper{}
+}
+
+// State returns what the looper is doing.
+func (l *Looper) State() State {
+	return l.state
+}
+
+// Bars returns the length of the loop.
+func (l *Looper) Bars() int {
+	return l.bars
+}
+
+// Layers returns the number of recorded layers.
+func (l *Looper) Layers() int {
+	return len(l.layers)
+}
+
+// Pass returns the bar of the loop being played or recorded at a song sixteenth, counting from 1.
+func (l *Looper) Pass(step int) int {
+	if l.state == Idle || l.state == Armed {
+		return 0
+	}
+	barLength := l.length / l.bars
+	return mod(step-l.start, l.length)/barLength + 1
+}
+
+// Record arms a new loop of the given number of bars, starting on the first downbeat at or after at.
+// barLength is the number of sixteenths in a bar.
+func (l *Looper) Record(at float64, bars, barLength int) error {
+	if l.state != Idle {
+		return ErrBusy
+	}
+	if bars < 1 || bars > MaxBars {
+		return fmt.Errorf("loops can be 1 to %d bars long", MaxBars)
+	}
+	l.state = Armed
+	l.bars = bars
+	l.length = bars * barLength
+	// Don't make someone who hit record right on the downbeat wait a bar.
+	l.start = int(math.Ceil(at/float64(barLength)-0.05)) * barLength
+	l.take = nil
+	return nil
+}
+
+// Overdub starts recording a layer on top of the loop, or stops if already overdubbing.
+func (l *Looper) Overdub() error {
+	switch l.state {
+	case Playing:
+		l.state = Overdubbing
+		l.take = nil
+	case Overdubbing:
+		l.commit()
+		l.state = Playing
+	default:
+		return ErrNotPlaying
+	}
+	return nil
+}
+
+// Undo drops the last recorded layer. It reports false if there was nothing to undo.
+func (l *Looper) Undo() bool {
+	if l.state == Overdubbing && len(l.take) > 0 {
+		l.take = nil
+		return true
+	}
+	if len(l.layers) == 0 {
+		return false
+	}
+	l.layers = l.layers[:len(l.layers)-1]
+	if len(l.layers) == 0 {
+		l.Clear()
+	}
+	return true
+}
+
+// Clear throws away the loop.
+func (l *Looper) Clear() {
+	*l = Looper{}
+}
+
+// Interrupt is called when playback stops. An unfinished first take is thrown away,
+// an overdub is kept.
+func (l *Looper) Interrupt() {
+	switch l.state {
+	case Armed, Recording:
+		l.Clear()
+	case Overdubbing:
+		l.commit()
+		l.state = Playing
+	}
+}
+
+// Note records a note played at song sixteenth at, if we're recording.
+func (l *Looper) Note(at float64, msg wsmsg.MIDIMsg) {
+	if l.state != Armed && l.state != Recording && l.state != Overdubbing {
+		return
+	}
+	// Rounding puts a note played just ahead of the downbeat on it, anything earlier isn't in the loop.
+	step := int(math.Round(at))
+	if step < l.start && l.state != Overdubbing {
+		return
+	}
+	l.take = append(l.take, Event{Step: mod(step-l.start, l.length), MIDI: msg})
+}
+
+// Tick moves the looper to a song sixteenth and returns the notes to play on it.
+// It should be called for every sixteenth while the transport plays.
+func (l *Looper) Tick(step int) []wsmsg.MIDIMsg {
+	switch l.state {
+	case Armed:
+		if step >= l.start {
+			l.state = Recording
+		}
+		return nil
+	case Recording:
+		if step < l.start+l.length {
+			return nil
+		}
+		// A first take with nothing in it leaves nothing to loop.
+		if len(l.take) == 0 {
+			l.Clear()
+			return nil
+		}
+		l.commit()
+		l.state = Playing
+	case Overdubbing:
+		// Every pass of an overdub is its own layer, so undo only drops the last one.
+		if mod(step-l.start, l.length) == 0 {
+			l.commit()
+		}
+	case Idle:
+		return nil
+	}
+
+	rel := mod(step-l.start, l.length)
+	notes := make([]wsmsg.MIDIMsg, 0)
+	for _, layer := range l.layers {
+		for _, e := range layer {
+			if e.Step == rel {
+				notes = append(notes, e.MIDI)
+			}
+		}
+	}
+	return notes
+}
+
+// Commit saves the take as a layer.
+func (l *Looper) commit() {
+	if len(l.take) > 0 {
+		l.layers = append(l.layers, l.take)
+	}
+	l.take = nil
+}
+
+func mod(a, b int) int {
+	return ((a % b) + b) % b
+}
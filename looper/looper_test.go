@@ -0,0 +1,143 @@
+package looper_test
+
+import (
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/looper"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+// Sixteenths in a bar of 4/4.
+const bar = 16
+
+func note(n int) wsmsg.MIDIMsg {
+	return wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: n, Velocity: 100}
+}
+
+// Play ticks the looper through song sixteenths [from, to) and returns the notes it played on each.
+func play(l *looper.Looper, from, to int) map[int][]int {
+	played := make(map[int][]int)
+	for step := from; step < to; step++ {
+		for _, msg := range l.Tick(step) {
+			played[step] = append(played[step], msg.Number)
+		}
+	}
+	return played
+}
+
+func TestRecordAndLoop(t *testing.T) {
+	l := looper.New()
+
+	// Hit record partway through bar 1, recording starts on bar 2.
+	require.NoError(t, l.Record(5.5, 1, bar))
+	require.Equal(t, looper.Armed, l.State())
+	require.ErrorIs(t, l.Record(6, 1, bar), looper.ErrBusy)
+
+	l.Note(8, note(40)) // Too early, not part of the loop.
+	require.Empty(t, play(l, 0, 16))
+	require.Equal(t, looper.Armed, l.State())
+
+	l.Tick(16)
+	require.Equal(t, looper.Recording, l.State())
+	l.Note(16.2, note(60))
+	require.Empty(t, play(l, 17, 20))
+	l.Note(19.6, note(64)) // Quantized to step 4.
+	require.Empty(t, play(l, 20, 32))
+
+	// The loop comes around.
+	played := play(l, 32, 64)
+	require.Equal(t, looper.Playing, l.State())
+	require.Equal(t, map[int][]int{32: {60}, 36: {64}, 48: {60}, 52: {64}}, played)
+	require.Equal(t, 1, l.Layers())
+	require.Equal(t, 1, l.Pass(40))
+}
+
+func TestRecordOnTheDownbeat(t *testing.T) {
+	l := looper.New()
+	// Hitting record a hair after the downbeat starts recording on it.
+	require.NoError(t, l.Record(16.1, 2, bar))
+	l.Tick(16)
+	require.Equal(t, looper.Recording, l.State())
+
+	// Two bar loop.
+	l.Note(40, note(60))
+	play(l, 17, 48)
+	require.Equal(t, looper.Recording, l.State())
+	require.Equal(t, map[int][]int{72: {60}}, play(l, 48, 80))
+	require.Equal(t, looper.Playing, l.State())
+	require.Equal(t, 2, l.Pass(64))
+}
+
+func TestRecordNothing(t *testing.T) {
+	l := looper.New()
+	require.NoError(t, l.Record(0, 1, bar))
+	require.Empty(t, play(l, 0, 32))
+	require.Equal(t, looper.Idle, l.State())
+	require.Equal(t, 0, l.Layers())
+	require.NoError(t, l.Record(32, 1, bar))
+}
+
+func TestOverdubAndUndo(t *testing.T) {
+	l := looper.New()
+	require.NoError(t, l.Record(0, 1, bar))
+	l.Tick(0)
+	l.Note(0, note(60))
+	play(l, 1, 16)
+	require.ErrorIs(t, l.Overdub(), looper.ErrNotPlaying)
+	require.Equal(t, map[int][]int{16: {60}}, play(l, 16, 20))
+
+	require.NoError(t, l.Overdub())
+	require.Equal(t, looper.Overdubbing, l.State())
+	l.Note(20, note(64))
+	play(l, 21, 32)
+
+	// Each pass of an overdub is a layer.
+	require.Equal(t, map[int][]int{32: {60}, 36: {64}}, play(l, 32, 40))
+	require.Equal(t, 2, l.Layers())
+	l.Note(40, note(67))
+	play(l, 41, 48)
+	require.NoError(t, l.Overdub())
+	require.Equal(t, looper.Playing, l.State())
+	require.Equal(t, 3, l.Layers())
+	require.Equal(t, map[int][]int{48: {60}, 52: {64}, 56: {67}}, play(l, 48, 64))
+
+	require.True(t, l.Undo())
+	require.Equal(t, map[int][]int{64: {60}, 68: {64}}, play(l, 64, 80))
+	require.True(t, l.Undo())
+	require.True(t, l.Undo())
+	require.Equal(t, looper.Idle, l.State())
+	require.False(t, l.Undo())
+	require.ErrorIs(t, l.Overdub(), looper.ErrNotPlaying)
+}
+
+func TestInterrupt(t *testing.T) {
+	l := looper.New()
+	require.NoError(t, l.Record(0, 1, bar))
+	l.Tick(0)
+	l.Note(2, note(60))
+	// Stopping the transport mid take throws it away.
+	l.Interrupt()
+	require.Equal(t, looper.Idle, l.State())
+
+	require.NoError(t, l.Record(0, 1, bar))
+	l.Tick(0)
+	l.Note(2, note(60))
+	play(l, 1, 17)
+	require.NoError(t, l.Overdub())
+	l.Note(20, note(64))
+	// An overdub is kept.
+	l.Interrupt()
+	require.Equal(t, looper.Playing, l.State())
+	require.Equal(t, 2, l.Layers())
+
+	l.Clear()
+	require.Equal(t, looper.Idle, l.State())
+	require.Empty(t, play(l, 0, 32))
+}
+
+func TestRecordRejectsBadLengths(t *testing.T) {
+	l := looper.New()
+	require.Error(t, l.Record(0, 0, bar))
+	require.Error(t, l.Record(0, looper.MaxBars+1, bar))
+}
@@ -0,0 +1,103 @@
+package midi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// SoundFontEngine plays notes with the embedded SoundFont, falling back to the built-in
+	// synth for notes that ask for a preset.
+	SoundFontEngine EngineName = iota
+	// OscEngine plays every note with the built-in synth, without loading the SoundFont.
+	OscEngine
+)
+
+type (
+	// Engine renders notes to sound. Synth and Osc are engines.
+	Engine interface {
+		// SampleRate returns the sample rate notes are rendered at.
+		SampleRate() int
+		// Note renders length of the note, ready to play.
+		Note(msg wsmsg.MIDIMsg, length time.Duration) (beep.Streamer, error)
+	}
+
+	// EngineName picks the engine notes are played with.
+	EngineName int
+
+	NewEnginesOpts struct {
+		Engine EngineName
+		// Reverb and chorus for the SoundFont to start with.
+		Effects Effects
+		// Sample rate to render at, the same as the audio output's. Zero is DefaultSampleRate.
+		SampleRate int
+	}
+
+	// Engines plays each note with the engine it asks for: the built-in synth for a preset, and
+	// the SoundFont for a General MIDI program, if it's loaded.
+	Engines struct {
+		// Nil with the OscEngine.
+		soundFont *Synth
+		osc       *Osc
+	}
+)
+
+// ParseEngine reads an engine's name: soundfont or osc.
+func ParseEngine(s string) (EngineName, error) {
+	for e := SoundFontEngine; e <= OscEngine; e++ {
+		if e.String() == s {
+			return e, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown synth %q, should be soundfont or osc", s)
+}
+
+func (e EngineName) String() string {
+	if e == OscEngine {
+		return "osc"
+	}
+	return "soundfont"
+}
+
+// NewEngines returns the engines to play notes with, loading the SoundFont unless only the
+// built-in synth is wanted.
+func NewEngines(o NewEnginesOpts) (*Engines, error) {
+	e := &Engines{osc: NewOsc(OscOpts{SampleRate: o.SampleRate})}
+	if o.Engine == SoundFontEngine {
+		sf, err := NewSynth(NewSynthOpts{SoundFontName: GeneralUser, Effects: o.Effects, SampleRate: o.SampleRate})
+		if err != nil {
+			return nil, err
+		}
+		e.soundFont = &sf
+	}
+	return e, nil
+}
+
+// SampleRate returns the sample rate notes are rendered at.
+func (e *Engines) SampleRate() int {
+	return e.osc.SampleRate()
+}
+
+// Note renders length of the note with its engine. Without the SoundFont, General MIDI
+// programs are played on the built-in preset closest to them.
+func (e *Engines) Note(msg wsmsg.MIDIMsg, length time.Duration) (beep.Streamer, error) {
+	if msg.Preset != "" || e.soundFont == nil {
+		return e.osc.Note(msg, length)
+	}
+	return e.soundFont.Note(msg, length)
+}
+
+// SoundFont reports whether the SoundFont is loaded.
+func (e *Engines) SoundFont() bool {
+	return e.soundFont != nil
+}
+
+// SetEffects changes the SoundFont's reverb and chorus. The built-in synth has neither.
+func (e *Engines) SetEffects(fx Effects) {
+	if e.soundFont != nil {
+		e.soundFont.SetEffects(fx)
+	}
+}
@@ -0,0 +1,107 @@
+package midi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PercussionChannel is the General MIDI channel reserved for drums (channel 10, zero indexed).
+const PercussionChannel = 9
+
+// General MIDI percussion notes used for the metronome click.
+const (
+	HiWoodBlock  = 76
+	LowWoodBlock = 77
+)
+
+// General MIDI level 1 program names, indexed by program number.
+var gmPrograms = [128]string{
+	// Piano
+	"Acoustic Grand Piano", "Bright Acoustic Piano", "Electric Grand Piano", "Honky-tonk Piano",
+	"Electric Piano 1", "Electric Piano 2", "Harpsichord", "Clavinet",
+	// Chromatic Percussion
+	"Celesta", "Glockenspiel", "Music Box", "Vibraphone",
+	"Marimba", "Xylophone", "Tubular Bells", "Dulcimer",
+	// Organ
+	"Drawbar Organ", "Percussive Organ", "Rock Organ", "Church Organ",
+	"Reed Organ", "Accordion", "Harmonica", "Tango Accordion",
+	// Guitar
+	"Acoustic Guitar (nylon)", "Acoustic Guitar (steel)", "Electric Guitar (jazz)", "Electric Guitar (clean)",
+	"Electric Guitar (muted)", "Overdriven Guitar", "Distortion Guitar", "Guitar Harmonics",
+	// Bass
+	"Acoustic Bass", "Electric Bass (finger)", "Electric Bass (pick)", "Fretless Bass",
+	"Slap Bass 1", "Slap Bass 2", "Synth Bass 1", "Synth Bass 2",
+	// Strings
+	"Violin", "Viola", "Cello", "Contrabass",
+	"Tremolo Strings", "Pizzicato Strings", "Orchestral Harp", "Timpani",
+	// Ensemble
+	"String Ensemble 1", "String Ensemble 2", "Synth Strings 1", "Synth Strings 2",
+	"Choir Aahs", "Voice Oohs", "Synth Voice", "Orchestra Hit",
+	// Brass
+	"Trumpet", "Trombone", "Tuba", "Muted Trumpet",
+	"French Horn", "Brass Section", "Synth Brass 1", "Synth Brass 2",
+	// Reed
+	"Soprano Sax", "Alto Sax", "Tenor Sax", "Baritone Sax",
+	"Oboe", "English Horn", "Bassoon", "Clarinet",
+	// Pipe
+	"Piccolo", "Flute", "Recorder", "Pan Flute",
+	"Blown Bottle", "Shakuhachi", "Whistle", "Ocarina",
+	// Synth Lead
+	"Lead 1 (square)", "Lead 2 (sawtooth)", "Lead 3 (calliope)", "Lead 4 (chiff)",
+	"Lead 5 (charang)", "Lead 6 (voice)", "Lead 7 (fifths)", "Lead 8 (bass + lead)",
+	// Synth Pad
+	"Pad 1 (new age)", "Pad 2 (warm)", "Pad 3 (polysynth)", "Pad 4 (choir)",
+	"Pad 5 (bowed)", "Pad 6 (metallic)", "Pad 7 (halo)", "Pad 8 (sweep)",
+	// Synth Effects
+	"FX 1 (rain)", "FX 2 (soundtrack)", "FX 3 (crystal)", "FX 4 (atmosphere)",
+	"FX 5 (brightness)", "FX 6 (goblins)", "FX 7 (echoes)", "FX 8 (sci-fi)",
+	// Ethnic
+	"Sitar", "Banjo", "Shamisen", "Koto",
+	"Kalimba", "Bagpipe", "Fiddle", "Shanai",
+	// Percussive
+	"Tinkle Bell", "Agogo", "Steel Drums", "Woodblock",
+	"Taiko Drum", "Melodic Tom", "Synth Drum", "Reverse Cymbal",
+	// Sound Effects
+	"Guitar Fret Noise", "Breath Noise", "Seashore", "Bird Tweet",
+	"Telephone Ring", "Helicopter", "Applause", "Gunshot",
+}
+
+// ProgramName returns the General MIDI name of the given program number (0-127).
+func ProgramName(program int) string {
+	if program < 0 || program >= len(gmPrograms) {
+		return fmt.Sprintf("Program %d", program)
+	}
+	return gmPrograms[program]
+}
+
+// FindProgram looks up a General MIDI program by number, ex: "25", or by name, ex: "nylon guitar".
+// Every word of a name query must appear in the program name.
+func FindProgram(query string) (int, error) {
+	query = strings.TrimSpace(query)
+	if n, err := strconv.Atoi(query); err == nil {
+		if n < 0 || n >= len(gmPrograms) {
+			return 0, fmt.Errorf("program must be between 0 and %d", len(gmPrograms)-1)
+		}
+		return n, nil
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return 0, fmt.Errorf("no instrument given")
+	}
+	for program, name := range gmPrograms {
+		name = strings.ToLower(name)
+		found := true
+		for _, w := range words {
+			if !strings.Contains(name, w) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return program, nil
+		}
+	}
+	return 0, fmt.Errorf("no instrument matches %q", query)
+}
@@ -4,8 +4,10 @@ import (
 	"embed"
 	"fmt"
 	"path"
+	"sync"
 	"time"
 
+	"github.com/faiface/beep"
 	"github.com/rapidmidiex/rmxtui/wsmsg"
 	"github.com/sinshu/go-meltysynth/meltysynth"
 )
@@ -17,12 +19,28 @@ const (
 	GeneralUser SoundFontName = iota
 )
 
+// DefaultSampleRate is the sample rate notes are rendered at if none is given.
+const DefaultSampleRate = 44100
+
 type (
 	Synth struct {
 		// SoundFonts available in the embedded FS.
 		soundFontPaths map[SoundFontName]string
 		soundFont      *meltysynth.SoundFont
 		synthSettings  *meltysynth.SynthesizerSettings
+		effects        *effects
+	}
+
+	// Effects are how much of the synth's reverb and chorus each note gets, as MIDI controller
+	// values from 0 to 127. 0 leaves the effect out.
+	Effects struct {
+		Reverb int
+		Chorus int
+	}
+
+	effects struct {
+		mu sync.Mutex
+		Effects
 	}
 
 	SoundFontName int
@@ -30,6 +48,10 @@ type (
 	NewSynthOpts struct {
 		// Name of SoundFont to use for the synthesizer.
 		SoundFontName SoundFontName
+		// Reverb and chorus to start with. They can be changed with SetEffects.
+		Effects Effects
+		// Sample rate to render at, the same as the audio output's. Zero is DefaultSampleRate.
+		SampleRate int
 	}
 
 	MidiStreamer struct {
@@ -59,31 +81,79 @@ func NewSynth(o NewSynthOpts) (Synth, error) {
 	sf2.Close()
 
 	// Create the synthesizer.
-	settings := meltysynth.NewSynthesizerSettings(44100)
+	if o.SampleRate == 0 {
+		o.SampleRate = DefaultSampleRate
+	}
+	settings := meltysynth.NewSynthesizerSettings(int32(o.SampleRate))
 
 	return Synth{
 		soundFontPaths: soundFonts,
 		synthSettings:  settings,
 		soundFont:      soundFont,
+		effects:        &effects{Effects: o.Effects},
 	}, nil
 }
 
+// SampleRate returns the sample rate notes are rendered at.
+func (p Synth) SampleRate() int {
+	return int(p.synthSettings.SampleRate)
+}
+
+// NewStreamer returns a streamer long enough for clipLength of a note at the synth's sample rate.
+func (p Synth) NewStreamer(clipLength time.Duration) *MidiStreamer {
+	return NewMIDIStreamer(p.SampleRate(), clipLength)
+}
+
+// Note renders length of the note, ready to play.
+func (p Synth) Note(msg wsmsg.MIDIMsg, length time.Duration) (beep.Streamer, error) {
+	s := p.NewStreamer(length)
+	if err := p.Render(msg, s); err != nil {
+		return nil, err
+	}
+	return beep.Take(s.Len(), s), nil
+}
+
+// SetEffects changes the reverb and chorus of notes rendered from now on.
+func (p Synth) SetEffects(e Effects) {
+	p.effects.mu.Lock()
+	defer p.effects.mu.Unlock()
+	p.effects.Effects = e
+}
+
+// Effects returns the reverb and chorus notes are rendered with.
+func (p Synth) Effects() Effects {
+	p.effects.mu.Lock()
+	defer p.effects.mu.Unlock()
+	return p.effects.Effects
+}
+
 // Render synthesizes the given MIDI note and write the audio data to the streamer's left/right buffers.
 func (p Synth) Render(msg wsmsg.MIDIMsg, streamer *MidiStreamer) error {
 	note := int32(msg.Number)
 	vel := int32(msg.Velocity)
 
+	// The synth's reverb and chorus cost CPU, only run them if they'll be heard.
+	fx := p.Effects()
+	settings := *p.synthSettings
+	settings.EnableReverbAndChorus = fx.Reverb > 0 || fx.Chorus > 0
+
 	// Create a new synth on every note to prevent race conditions with using the same synth buffers when notes are played concurrently.
-	synth, err := meltysynth.NewSynthesizer(p.soundFont, p.synthSettings)
+	synth, err := meltysynth.NewSynthesizer(p.soundFont, &settings)
 	if err != nil {
 		return fmt.Errorf("newSynthesizer: %w", err)
 	}
 
+	// Select the note's instrument and how much reverb (CC 91) and chorus (CC 93) it gets.
+	ch := int32(msg.Channel)
+	synth.ProcessMidiMessage(ch, 0xC0, int32(msg.Program), 0)
+	synth.ProcessMidiMessage(ch, 0xB0, 91, int32(fx.Reverb))
+	synth.ProcessMidiMessage(ch, 0xB0, 93, int32(fx.Chorus))
+
 	switch msg.State {
 	case wsmsg.NOTE_ON:
-		synth.NoteOn(0, note, vel)
+		synth.NoteOn(ch, note, vel)
 	case wsmsg.NOTE_OFF:
-		synth.NoteOff(0, note)
+		synth.NoteOff(ch, note)
 	}
 
 	// Render the waveform.
@@ -91,9 +161,9 @@ func (p Synth) Render(msg wsmsg.MIDIMsg, streamer *MidiStreamer) error {
 	return nil
 }
 
-func NewMIDIStreamer(clipLength time.Duration) *MidiStreamer {
-	// TODO: Get sample rate from config or struct
-	bufLen := int(44100 * clipLength.Seconds())
+// NewMIDIStreamer returns a streamer long enough for clipLength of a note rendered at sampleRate.
+func NewMIDIStreamer(sampleRate int, clipLength time.Duration) *MidiStreamer {
+	bufLen := int(float64(sampleRate) * clipLength.Seconds())
 	return &MidiStreamer{
 		left:  make([]float32, bufLen),
 		right: make([]float32, bufLen),
@@ -1,6 +1,8 @@
 package midi_test
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"testing"
 	"time"
@@ -38,7 +40,7 @@ func TestMIDItoAudio(t *testing.T) {
 			Velocity: 127,
 		}
 
-		streamer := midi.NewMIDIStreamer(noteDuration)
+		streamer := synth.NewStreamer(noteDuration)
 		err := synth.Render(msg, streamer)
 		require.NoError(t, err)
 
@@ -57,7 +59,7 @@ func TestMIDItoAudio(t *testing.T) {
 	})
 
 	t.Run("plays multiple notes at once", func(t *testing.T) {
-		streamer1 := midi.NewMIDIStreamer(noteDuration)
+		streamer1 := synth.NewStreamer(noteDuration)
 		err := synth.Render(wsmsg.MIDIMsg{
 			State:    wsmsg.NOTE_ON,
 			Number:   70, // Bb4
@@ -65,7 +67,7 @@ func TestMIDItoAudio(t *testing.T) {
 		}, streamer1)
 		require.NoError(t, err)
 
-		streamer2 := midi.NewMIDIStreamer(noteDuration)
+		streamer2 := synth.NewStreamer(noteDuration)
 		err = synth.Render(wsmsg.MIDIMsg{
 			State:    wsmsg.NOTE_ON,
 			Number:   67, // G4
@@ -73,7 +75,7 @@ func TestMIDItoAudio(t *testing.T) {
 		}, streamer2)
 		require.NoError(t, err)
 
-		streamer3 := midi.NewMIDIStreamer(noteDuration)
+		streamer3 := synth.NewStreamer(noteDuration)
 		err = synth.Render(wsmsg.MIDIMsg{
 			State:    wsmsg.NOTE_ON,
 			Number:   76, // E5
@@ -103,7 +105,7 @@ func TestMIDItoAudio(t *testing.T) {
 	})
 
 	t.Run("plays chords", func(t *testing.T) {
-		streamer1 := midi.NewMIDIStreamer(noteDuration)
+		streamer1 := synth.NewStreamer(noteDuration)
 		err := synth.Render(wsmsg.MIDIMsg{
 			State:    wsmsg.NOTE_ON,
 			Number:   40,
@@ -111,7 +113,7 @@ func TestMIDItoAudio(t *testing.T) {
 		}, streamer1)
 		require.NoError(t, err)
 
-		streamer2 := midi.NewMIDIStreamer(noteDuration)
+		streamer2 := synth.NewStreamer(noteDuration)
 		err = synth.Render(wsmsg.MIDIMsg{
 			State:    wsmsg.NOTE_ON,
 			Number:   42,
@@ -142,3 +144,72 @@ func TestMIDItoAudio(t *testing.T) {
 		time.Sleep(noteDuration + time.Millisecond*100)
 	})
 }
+
+func TestRenderPercussion(t *testing.T) {
+	synth, err := midi.NewSynth(midi.NewSynthOpts{
+		SoundFontName: midi.GeneralUser,
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("SoundFont not downloaded, see sound_fonts/README.md")
+	}
+	require.NoError(t, err)
+
+	streamer := synth.NewStreamer(time.Millisecond * 200)
+	err = synth.Render(wsmsg.MIDIMsg{
+		State:    wsmsg.NOTE_ON,
+		Number:   midi.HiWoodBlock,
+		Velocity: 100,
+		Channel:  midi.PercussionChannel,
+	}, streamer)
+	require.NoError(t, err)
+
+	samples := make([][2]float64, streamer.Len())
+	n, ok := streamer.Stream(samples)
+	require.True(t, ok)
+
+	var peak float64
+	for _, s := range samples[:n] {
+		if s[0] > peak {
+			peak = s[0]
+		}
+	}
+	require.Greater(t, peak, 0.0, "click should not be silent")
+}
+
+func TestRenderEffects(t *testing.T) {
+	synth, err := midi.NewSynth(midi.NewSynthOpts{
+		SoundFontName: midi.GeneralUser,
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("SoundFont not downloaded, see sound_fonts/README.md")
+	}
+	require.NoError(t, err)
+	require.Equal(t, midi.Effects{}, synth.Effects())
+
+	render := func() [][2]float64 {
+		streamer := synth.NewStreamer(time.Millisecond * 500)
+		err := synth.Render(wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 60, Velocity: 100}, streamer)
+		require.NoError(t, err)
+		samples := make([][2]float64, streamer.Len())
+		streamer.Stream(samples)
+		return samples
+	}
+	dry := render()
+
+	synth.SetEffects(midi.Effects{Reverb: 127, Chorus: 64})
+	require.Equal(t, midi.Effects{Reverb: 127, Chorus: 64}, synth.Effects())
+	require.NotEqual(t, dry, render(), "reverb and chorus should change the sound")
+}
+
+func TestSampleRate(t *testing.T) {
+	synth, err := midi.NewSynth(midi.NewSynthOpts{
+		SoundFontName: midi.GeneralUser,
+		SampleRate:    48000,
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("SoundFont not downloaded, see sound_fonts/README.md")
+	}
+	require.NoError(t, err)
+	require.Equal(t, 48000, synth.SampleRate())
+	require.Equal(t, 24000, synth.NewStreamer(time.Millisecond*500).Len())
+}
@@ -0,0 +1,367 @@
+package midi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	Sine Waveform = iota
+	Saw
+	Square
+)
+
+// Frames between recalculating a note's filter, as its envelope moves the cutoff.
+const filterStep = 16
+
+type (
+	// Waveform is the shape of an oscillator.
+	Waveform int
+
+	// ADSR is an envelope: how long a note takes to reach full level, to fall to its sustain
+	// level (0 to 1) while the key is held, and to fade out once it's let go.
+	ADSR struct {
+		Attack  time.Duration
+		Decay   time.Duration
+		Sustain float64
+		Release time.Duration
+	}
+
+	// Preset is a sound for the built-in synth: oscillators through a low-pass filter, shaped
+	// by an envelope.
+	Preset struct {
+		Name string
+		Wave Waveform
+		// Pitch of a second oscillator above the first, in cents. 0 plays one oscillator.
+		Detune float64
+		Env    ADSR
+		// Low-pass cutoff in Hz with the envelope closed, how many octaves the envelope opens
+		// it, and its resonance from 0 to 1.
+		Cutoff     float64
+		EnvOctaves float64
+		Resonance  float64
+		// Level at full velocity.
+		Level float64
+	}
+
+	OscOpts struct {
+		// Sample rate to render at, the same as the audio output's. Zero is DefaultSampleRate.
+		SampleRate int
+	}
+
+	// Osc is a small subtractive synth, an engine that needs no SoundFont. Notes are rendered
+	// as they're streamed, and the same note always sounds the same.
+	Osc struct {
+		sr beep.SampleRate
+	}
+
+	// OscVoice streams one note of a preset.
+	oscVoice struct {
+		p  Preset
+		sr float64
+		// Oscillator phases from 0 to 1, and how far they move each frame.
+		phase [2]float64
+		inc   [2]float64
+		// Frames played, and how many the key is held for.
+		pos, gate int
+		// Filter state and coefficients.
+		ic1, ic2   float64
+		a1, a2, a3 float64
+		gain       float64
+	}
+
+	// DrumVoice streams one hit of the drum kit: a falling tone and a burst of noise.
+	drumVoice struct {
+		d    drum
+		sr   float64
+		gain float64
+		pos  int
+		// Tone phase, and the noise generator's state.
+		phase float64
+		seed  uint32
+		last  float64
+	}
+
+	// Drum is how the built-in synth plays a General MIDI percussion note.
+	drum struct {
+		// Tone starting pitch in Hz, and what it falls to.
+		from, to float64
+		// Noise level against the tone's, and how long the hit takes to die away.
+		noise float64
+		decay time.Duration
+	}
+)
+
+// Presets are the built-in synth's sounds.
+var Presets = []Preset{
+	{Name: "keys", Wave: Square, Env: ADSR{Attack: 5 * time.Millisecond, Decay: 600 * time.Millisecond, Sustain: 0.3, Release: 200 * time.Millisecond},
+		Cutoff: 800, EnvOctaves: 3, Resonance: 0.1, Level: 0.3},
+	{Name: "pluck", Wave: Saw, Env: ADSR{Attack: time.Millisecond, Decay: 300 * time.Millisecond, Sustain: 0, Release: 100 * time.Millisecond},
+		Cutoff: 300, EnvOctaves: 4, Resonance: 0.2, Level: 0.35},
+	{Name: "bass", Wave: Saw, Env: ADSR{Attack: 2 * time.Millisecond, Decay: 250 * time.Millisecond, Sustain: 0.5, Release: 80 * time.Millisecond},
+		Cutoff: 200, EnvOctaves: 3, Resonance: 0.5, Level: 0.35},
+	{Name: "saw-lead", Wave: Saw, Detune: 8, Env: ADSR{Attack: 10 * time.Millisecond, Decay: 200 * time.Millisecond, Sustain: 0.7, Release: 150 * time.Millisecond},
+		Cutoff: 1200, EnvOctaves: 2.5, Resonance: 0.3, Level: 0.25},
+	{Name: "square-lead", Wave: Square, Env: ADSR{Attack: 5 * time.Millisecond, Decay: 150 * time.Millisecond, Sustain: 0.8, Release: 100 * time.Millisecond},
+		Cutoff: 2000, EnvOctaves: 1.5, Resonance: 0.2, Level: 0.22},
+	{Name: "pad", Wave: Saw, Detune: 12, Env: ADSR{Attack: 400 * time.Millisecond, Decay: 500 * time.Millisecond, Sustain: 0.8, Release: 600 * time.Millisecond},
+		Cutoff: 600, EnvOctaves: 1.5, Resonance: 0.1, Level: 0.2},
+	{Name: "sine", Wave: Sine, Env: ADSR{Attack: 5 * time.Millisecond, Decay: 100 * time.Millisecond, Sustain: 0.9, Release: 100 * time.Millisecond},
+		Cutoff: 20000, Level: 0.3},
+}
+
+// Presets played for each General MIDI program family, eight programs to a family, when
+// there's no SoundFont to play them.
+var familyPresets = [16]string{
+	"keys", "keys", "square-lead", "pluck", // Piano, chromatic percussion, organ, guitar.
+	"bass", "pad", "pad", "saw-lead", // Bass, strings, ensemble, brass.
+	"square-lead", "sine", "saw-lead", "pad", // Reed, pipe, synth lead, synth pad.
+	"pad", "pluck", "pluck", "sine", // Synth effects, ethnic, percussive, sound effects.
+}
+
+// The drum kit, by General MIDI percussion note. Other notes play a tom at their pitch.
+var drums = map[int]drum{
+	35: {from: 120, to: 45, decay: 350 * time.Millisecond},                // Acoustic bass drum.
+	36: {from: 150, to: 50, decay: 300 * time.Millisecond},                // Bass drum.
+	37: {from: 800, to: 800, noise: 0.5, decay: 40 * time.Millisecond},    // Side stick.
+	38: {from: 200, to: 160, noise: 0.7, decay: 180 * time.Millisecond},   // Snare.
+	39: {from: 1000, to: 1000, noise: 1, decay: 120 * time.Millisecond},   // Hand clap.
+	40: {from: 220, to: 180, noise: 0.8, decay: 160 * time.Millisecond},   // Electric snare.
+	42: {from: 8000, to: 8000, noise: 1, decay: 50 * time.Millisecond},    // Closed hi-hat.
+	44: {from: 8000, to: 8000, noise: 1, decay: 70 * time.Millisecond},    // Pedal hi-hat.
+	46: {from: 8000, to: 8000, noise: 1, decay: 350 * time.Millisecond},   // Open hi-hat.
+	49: {from: 6000, to: 6000, noise: 1, decay: 900 * time.Millisecond},   // Crash.
+	51: {from: 5000, to: 5000, noise: 0.8, decay: 600 * time.Millisecond}, // Ride.
+	76: {from: 1600, to: 1600, decay: 40 * time.Millisecond},              // Hi wood block.
+	77: {from: 1200, to: 1200, decay: 40 * time.Millisecond},              // Low wood block.
+}
+
+func (w Waveform) String() string {
+	switch w {
+	case Saw:
+		return "saw"
+	case Square:
+		return "square"
+	}
+	return "sine"
+}
+
+// FindPreset looks up a built-in preset by name.
+func FindPreset(name string) (Preset, error) {
+	for _, p := range Presets {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return Preset{}, fmt.Errorf("no preset %q, try %s", name, strings.Join(PresetNames(), ", "))
+}
+
+// PresetNames returns the names of the built-in presets.
+func PresetNames() []string {
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// PresetFor returns the built-in preset closest to a General MIDI program.
+func PresetFor(program int) Preset {
+	if program < 0 || program >= len(gmPrograms) {
+		program = 0
+	}
+	p, _ := FindPreset(familyPresets[program/8])
+	return p
+}
+
+// NewOsc returns the built-in synth.
+func NewOsc(o OscOpts) *Osc {
+	if o.SampleRate == 0 {
+		o.SampleRate = DefaultSampleRate
+	}
+	return &Osc{sr: beep.SampleRate(o.SampleRate)}
+}
+
+// SampleRate returns the sample rate notes are rendered at.
+func (o *Osc) SampleRate() int {
+	return int(o.sr)
+}
+
+// Note plays length of the note with the preset it names, or the one closest to its General
+// MIDI program. Percussion plays the drum kit, and note offs are silent, as with the SoundFont.
+func (o *Osc) Note(msg wsmsg.MIDIMsg, length time.Duration) (beep.Streamer, error) {
+	if msg.Channel == PercussionChannel && msg.State == wsmsg.NOTE_ON && msg.Velocity > 0 {
+		return beep.Take(o.sr.N(length), o.drum(msg)), nil
+	}
+	p, err := FindPreset(msg.Preset)
+	if err != nil {
+		// No preset, or one from a newer rmxtui. Play something rather than nothing.
+		p = PresetFor(msg.Program)
+	}
+	return o.Play(p, msg, length), nil
+}
+
+// Play plays length of the note with the given preset, for presets of your own. The key is let
+// go in time for the note to finish its release.
+func (o *Osc) Play(p Preset, msg wsmsg.MIDIMsg, length time.Duration) beep.Streamer {
+	n := o.sr.N(length)
+	if msg.State != wsmsg.NOTE_ON || msg.Velocity <= 0 {
+		return beep.Silence(n)
+	}
+	freq := 440 * math.Pow(2, float64(msg.Number-69)/12)
+	v := &oscVoice{
+		p:    p,
+		sr:   float64(o.sr),
+		gate: n - o.sr.N(p.Env.Release),
+		gain: p.Level * float64(msg.Velocity) / 127,
+	}
+	v.inc[0] = freq / v.sr
+	v.inc[1] = freq * math.Pow(2, p.Detune/1200) / v.sr
+	if p.Detune != 0 {
+		v.gain /= 2
+	}
+	return beep.Take(n, v)
+}
+
+func (v *oscVoice) Stream(samples [][2]float64) (n int, ok bool) {
+	for i := range samples {
+		env := v.envelope(v.pos)
+		if v.pos%filterStep == 0 {
+			v.tune(env)
+		}
+		x := v.osc(0)
+		if v.p.Detune != 0 {
+			x += v.osc(1)
+		}
+
+		// A state variable low-pass filter, after Zavalishin's "The Art of VA Filter Design".
+		v3 := x - v.ic2
+		v1 := v.a1*v.ic1 + v.a2*v3
+		v2 := v.ic2 + v.a2*v.ic1 + v.a3*v3
+		v.ic1, v.ic2 = 2*v1-v.ic1, 2*v2-v.ic2
+
+		s := v2 * env * v.gain
+		samples[i] = [2]float64{s, s}
+		v.pos++
+	}
+	return len(samples), true
+}
+
+func (v *oscVoice) Err() error {
+	return nil
+}
+
+// Envelope returns the note's level at frame i.
+func (v *oscVoice) envelope(i int) float64 {
+	if i >= v.gate {
+		release := v.sr * v.p.Env.Release.Seconds()
+		if release <= 0 {
+			return 0
+		}
+		return math.Max(0, v.held(v.gate)*(1-float64(i-v.gate)/release))
+	}
+	return v.held(i)
+}
+
+// Held returns the note's level at frame i while the key is down.
+func (v *oscVoice) held(i int) float64 {
+	e := v.p.Env
+	attack, decay := v.sr*e.Attack.Seconds(), v.sr*e.Decay.Seconds()
+	t := float64(i)
+	switch {
+	case t < attack:
+		return t / attack
+	case t < attack+decay:
+		return 1 - (1-e.Sustain)*(t-attack)/decay
+	}
+	return e.Sustain
+}
+
+// Tune sets the filter's cutoff for the envelope's level.
+func (v *oscVoice) tune(env float64) {
+	cutoff := math.Min(v.p.Cutoff*math.Pow(2, v.p.EnvOctaves*env), 0.45*v.sr)
+	g := math.Tan(math.Pi * cutoff / v.sr)
+	k := 2 - 1.9*v.p.Resonance
+	v.a1 = 1 / (1 + g*(g+k))
+	v.a2 = g * v.a1
+	v.a3 = g * v.a2
+}
+
+// Osc returns the next sample of oscillator i, its edges smoothed with PolyBLEP to keep high
+// notes from aliasing.
+func (v *oscVoice) osc(i int) float64 {
+	t, dt := v.phase[i], v.inc[i]
+	v.phase[i] += dt
+	v.phase[i] -= math.Floor(v.phase[i])
+	switch v.p.Wave {
+	case Saw:
+		return 2*t - 1 - polyBLEP(t, dt)
+	case Square:
+		s := 1.0
+		if t >= 0.5 {
+			s = -1
+		}
+		return s + polyBLEP(t, dt) - polyBLEP(math.Mod(t+0.5, 1), dt)
+	}
+	return math.Sin(2 * math.Pi * t)
+}
+
+func polyBLEP(t, dt float64) float64 {
+	switch {
+	case t < dt:
+		t /= dt
+		return t + t - t*t - 1
+	case t > 1-dt:
+		t = (t - 1) / dt
+		return t*t + t + t + 1
+	}
+	return 0
+}
+
+func (o *Osc) drum(msg wsmsg.MIDIMsg) *drumVoice {
+	d, ok := drums[msg.Number]
+	if !ok {
+		freq := 440 * math.Pow(2, float64(msg.Number-69)/12)
+		d = drum{from: freq * 1.5, to: freq, noise: 0.2, decay: 250 * time.Millisecond}
+	}
+	return &drumVoice{
+		d:    d,
+		sr:   float64(o.sr),
+		gain: 0.5 * float64(msg.Velocity) / 127,
+		// Seeded by the note, so each drum sounds the same every time.
+		seed: uint32(msg.Number) + 1,
+	}
+}
+
+func (v *drumVoice) Stream(samples [][2]float64) (n int, ok bool) {
+	decay := v.sr * v.d.decay.Seconds()
+	for i := range samples {
+		t := float64(v.pos)
+		// Falls away exponentially, to a thousandth by the end of the decay.
+		env := math.Exp(-6.9 * t / decay)
+		freq := v.d.to + (v.d.from-v.d.to)*math.Exp(-t/(0.03*v.sr))
+		v.phase += freq / v.sr
+		v.phase -= math.Floor(v.phase)
+		tone := math.Sin(2 * math.Pi * v.phase)
+
+		v.seed = v.seed*1664525 + 1013904223
+		white := float64(v.seed>>8)/(1<<23) - 1
+		// Noise with its lows taken out, brighter like a cymbal.
+		noise := white - v.last
+		v.last = white
+
+		s := ((1-v.d.noise)*tone + v.d.noise*0.5*noise) * env * v.gain
+		samples[i] = [2]float64{s, s}
+		v.pos++
+	}
+	return len(samples), true
+}
+
+func (v *drumVoice) Err() error {
+	return nil
+}
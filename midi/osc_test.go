@@ -0,0 +1,170 @@
+package midi_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+const oscRate = 44100
+
+// Render plays all of a note.
+func render(t *testing.T, e midi.Engine, msg wsmsg.MIDIMsg, length time.Duration) []float64 {
+	t.Helper()
+	s, err := e.Note(msg, length)
+	require.NoError(t, err)
+	return samples(s)
+}
+
+// Samples streams s until it ends, returning its left channel.
+func samples(s beep.Streamer) []float64 {
+	var out []float64
+	buf := make([][2]float64, 100)
+	for {
+		n, ok := s.Stream(buf)
+		for _, f := range buf[:n] {
+			out = append(out, f[0])
+		}
+		if !ok {
+			return out
+		}
+	}
+}
+
+func peakOf(s []float64) float64 {
+	p := 0.0
+	for _, v := range s {
+		p = math.Max(p, math.Abs(v))
+	}
+	return p
+}
+
+func noteOn(number int, preset string) wsmsg.MIDIMsg {
+	return wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: number, Velocity: 127, Preset: preset}
+}
+
+func TestOsc(t *testing.T) {
+	osc := midi.NewOsc(midi.OscOpts{SampleRate: oscRate})
+	require.Equal(t, oscRate, osc.SampleRate())
+
+	t.Run("plays every preset the same every time", func(t *testing.T) {
+		for _, p := range midi.Presets {
+			first := render(t, osc, noteOn(60, p.Name), time.Second)
+			require.Len(t, first, oscRate, p.Name)
+			require.Equal(t, first, render(t, osc, noteOn(60, p.Name), time.Second), p.Name)
+			require.Greater(t, peakOf(first), 0.05, p.Name)
+			require.LessOrEqual(t, peakOf(first), 1.0, p.Name)
+		}
+	})
+
+	t.Run("plays in tune", func(t *testing.T) {
+		s := render(t, osc, noteOn(69, "sine"), time.Second)
+		crossings := 0
+		for i := 1; i < len(s); i++ {
+			if s[i-1] < 0 && s[i] >= 0 {
+				crossings++
+			}
+		}
+		require.InDelta(t, 440, crossings, 2)
+	})
+
+	t.Run("follows the envelope", func(t *testing.T) {
+		p, err := midi.FindPreset("sine")
+		require.NoError(t, err)
+		p.Env = midi.ADSR{Attack: 100 * time.Millisecond, Decay: 100 * time.Millisecond, Sustain: 0.5, Release: 200 * time.Millisecond}
+		s := samples(osc.Play(p, noteOn(69, ""), time.Second))
+		window := func(at time.Duration) float64 {
+			i := beep.SampleRate(oscRate).N(at)
+			return peakOf(s[i : i+oscRate/100])
+		}
+		require.InDelta(t, p.Level*0.5, window(45*time.Millisecond), 0.02, "half way up the attack")
+		require.InDelta(t, p.Level, window(100*time.Millisecond), 0.02, "top of the attack")
+		require.InDelta(t, p.Level*0.5, window(500*time.Millisecond), 0.01, "sustaining")
+		require.InDelta(t, p.Level*0.25, window(895*time.Millisecond), 0.02, "half way through the release")
+		require.Less(t, peakOf(s[len(s)-10:]), 0.01, "let go in time to finish")
+	})
+
+	t.Run("filters out the highs", func(t *testing.T) {
+		// Saws with the filter closed and wide open. The closed one bends less from sample to
+		// sample, as it has fewer highs.
+		p := midi.Preset{Wave: midi.Saw, Env: midi.ADSR{Sustain: 1}, Level: 0.5, Cutoff: 20000}
+		roughness := func(p midi.Preset) float64 {
+			s := samples(osc.Play(p, noteOn(57, ""), time.Second/2))
+			sum := 0.0
+			for i := 2; i < len(s); i++ {
+				sum += math.Abs(s[i] - 2*s[i-1] + s[i-2])
+			}
+			return sum
+		}
+		open := roughness(p)
+		p.Cutoff = 300
+		require.Less(t, roughness(p), open/10)
+	})
+
+	t.Run("velocity sets the level", func(t *testing.T) {
+		loud := render(t, osc, noteOn(60, "keys"), time.Second/2)
+		soft := noteOn(60, "keys")
+		soft.Velocity = 32
+		require.InDelta(t, peakOf(loud)*32/127, peakOf(render(t, osc, soft, time.Second/2)), 0.01)
+	})
+
+	t.Run("note offs are silent", func(t *testing.T) {
+		off := wsmsg.MIDIMsg{State: wsmsg.NOTE_OFF, Number: 60, Preset: "keys"}
+		s := render(t, osc, off, time.Second/10)
+		require.Len(t, s, oscRate/10)
+		require.Zero(t, peakOf(s))
+	})
+
+	t.Run("plays drums and programs without a preset", func(t *testing.T) {
+		kick := wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 36, Velocity: 127, Channel: midi.PercussionChannel}
+		first := render(t, osc, kick, time.Second/2)
+		require.Greater(t, peakOf(first), 0.1)
+		require.Equal(t, first, render(t, osc, kick, time.Second/2))
+		require.Less(t, peakOf(first[len(first)-100:]), 0.01, "dies away")
+
+		bass := noteOn(40, "")
+		bass.Program = 33 // Electric Bass (finger)
+		require.Equal(t, render(t, osc, noteOn(40, "bass"), time.Second/2), render(t, osc, bass, time.Second/2))
+		// An unknown preset, say from a newer rmxtui, plays the program's.
+		bass.Preset = "theremin"
+		require.Equal(t, render(t, osc, noteOn(40, "bass"), time.Second/2), render(t, osc, bass, time.Second/2))
+	})
+}
+
+func TestFindPreset(t *testing.T) {
+	p, err := midi.FindPreset("Saw-Lead")
+	require.NoError(t, err)
+	require.Equal(t, "saw-lead", p.Name)
+	require.Equal(t, midi.Saw, p.Wave)
+
+	_, err = midi.FindPreset("theremin")
+	require.ErrorContains(t, err, "keys, pluck")
+
+	require.Equal(t, "keys", midi.PresetFor(0).Name)
+	require.Equal(t, "pad", midi.PresetFor(48).Name)
+	require.Equal(t, "keys", midi.PresetFor(200).Name)
+}
+
+func TestEngines(t *testing.T) {
+	e, err := midi.ParseEngine("osc")
+	require.NoError(t, err)
+	require.Equal(t, "osc", e.String())
+	_, err = midi.ParseEngine("fm")
+	require.Error(t, err)
+
+	engines, err := midi.NewEngines(midi.NewEnginesOpts{Engine: midi.OscEngine, SampleRate: oscRate})
+	require.NoError(t, err)
+	require.False(t, engines.SoundFont())
+	require.Equal(t, oscRate, engines.SampleRate())
+	// Effects are for the SoundFont, and left alone without it.
+	engines.SetEffects(midi.Effects{Reverb: 64})
+
+	osc := midi.NewOsc(midi.OscOpts{SampleRate: oscRate})
+	piano := noteOn(60, "")
+	require.Equal(t, render(t, osc, piano, time.Second/4), render(t, engines, piano, time.Second/4))
+}
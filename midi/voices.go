@@ -0,0 +1,272 @@
+package midi
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultMaxVoices is how many notes play at once if no limit is given.
+	DefaultMaxVoices = 32
+	// How long a stolen or retriggered voice takes to fade out, so it doesn't click.
+	releaseTime = 5 * time.Millisecond
+)
+
+const (
+	// StealOldest makes room for a new note by ending the note that started first.
+	StealOldest StealPolicy = iota
+	// StealQuietest ends the note that's quietest now, the one least likely to be missed.
+	StealQuietest
+)
+
+type (
+	// StealPolicy picks which note ends when too many are playing.
+	StealPolicy int
+
+	VoicesOpts struct {
+		// Most notes playing at once. Zero is DefaultMaxVoices.
+		Max   int
+		Steal StealPolicy
+		// Sample rate the notes are played at. Zero is DefaultSampleRate.
+		SampleRate int
+	}
+
+	// VoiceKey is who played a note and which note it is. A note played again by the same
+	// player replaces the one still ringing.
+	VoiceKey struct {
+		Player  uuid.UUID
+		Channel int
+		Note    int
+	}
+
+	// VoiceStats are how the voices have been used.
+	VoiceStats struct {
+		// Notes playing now, and the most that can.
+		Active int
+		Max    int
+		// Most notes ever playing at once.
+		Peak int
+		// Notes ended early to make room for another, or by playing the same note again.
+		Stolen      int
+		Retriggered int
+	}
+
+	// Voices limits how many notes play at once. Each note is a Voice, streamed by the mixer
+	// alongside the rest; when there are too many, one of them is faded out.
+	Voices struct {
+		mu    sync.Mutex
+		steal StealPolicy
+		// Samples a voice takes to fade out.
+		release int
+		// Voices still sounding, oldest first.
+		playing []*Voice
+		stats   VoiceStats
+	}
+
+	// Voice is a beep.Streamer playing one note until it ends, or is faded out.
+	Voice struct {
+		voices   *Voices
+		key      VoiceKey
+		streamer beep.Streamer
+		// Peak of the last samples streamed.
+		level float64
+		// Samples left to fade out over, once released.
+		released bool
+		fade     int
+		done     bool
+	}
+)
+
+// ParseStealPolicy reads "oldest" or "quietest".
+func ParseStealPolicy(s string) (StealPolicy, error) {
+	for _, p := range []StealPolicy{StealOldest, StealQuietest} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("voice stealing %q should be oldest or quietest", s)
+}
+
+func (p StealPolicy) String() string {
+	if p == StealQuietest {
+		return "quietest"
+	}
+	return "oldest"
+}
+
+// NewVoices returns voices limited as o says.
+func NewVoices(o VoicesOpts) *Voices {
+	if o.Max <= 0 {
+		o.Max = DefaultMaxVoices
+	}
+	if o.SampleRate == 0 {
+		o.SampleRate = DefaultSampleRate
+	}
+	return &Voices{
+		steal:   o.Steal,
+		release: beep.SampleRate(o.SampleRate).N(releaseTime),
+		stats:   VoiceStats{Max: o.Max},
+	}
+}
+
+// Start returns a voice playing s, to be added to the mixer. If the same note is still ringing
+// it's faded out, and if too many notes are playing, one is stolen.
+func (vs *Voices) Start(key VoiceKey, s beep.Streamer) *Voice {
+	v := vs.Reserve(key)
+	v.Play(s)
+	return v
+}
+
+// Reserve makes room for a note whose sound is still to be rendered, as Start does, and returns
+// its voice. Play gives the voice its sound once it's rendered, and Cancel gives it back if it
+// never is. A reserved voice can be stolen before it plays, so Released tells whether it's still
+// worth rendering.
+func (vs *Voices) Reserve(key VoiceKey) *Voice {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	for _, v := range vs.playing {
+		if v.key == key && !v.released {
+			v.releaseLocked()
+			vs.stats.Retriggered++
+		}
+	}
+	for vs.activeLocked() >= vs.stats.Max {
+		vs.victimLocked().releaseLocked()
+		vs.stats.Stolen++
+	}
+
+	// Nothing's been heard of a new note yet, so it's the last to be thought quiet.
+	v := &Voice{voices: vs, key: key, level: math.Inf(1)}
+	vs.playing = append(vs.playing, v)
+	if a := vs.activeLocked(); a > vs.stats.Peak {
+		vs.stats.Peak = a
+	}
+	return v
+}
+
+// Stats returns how the voices have been used.
+func (vs *Voices) Stats() VoiceStats {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	s := vs.stats
+	s.Active = vs.activeLocked()
+	return s
+}
+
+// ActiveLocked counts the voices that aren't fading out.
+func (vs *Voices) activeLocked() int {
+	n := 0
+	for _, v := range vs.playing {
+		if !v.released {
+			n++
+		}
+	}
+	return n
+}
+
+// VictimLocked picks the voice to steal. There's always one, as it's only called while the
+// voices are full.
+func (vs *Voices) victimLocked() *Voice {
+	var victim *Voice
+	for _, v := range vs.playing {
+		if v.released {
+			continue
+		}
+		if victim == nil {
+			victim = v
+			if vs.steal == StealOldest {
+				break
+			}
+		}
+		// Oldest first, so the oldest of equally quiet voices goes.
+		if v.level < victim.level {
+			victim = v
+		}
+	}
+	return victim
+}
+
+func (vs *Voices) removeLocked(v *Voice) {
+	for i, p := range vs.playing {
+		if p == v {
+			vs.playing = append(vs.playing[:i], vs.playing[i+1:]...)
+			return
+		}
+	}
+}
+
+// Play gives a reserved voice the sound it plays.
+func (v *Voice) Play(s beep.Streamer) {
+	v.voices.mu.Lock()
+	defer v.voices.mu.Unlock()
+	v.streamer = s
+}
+
+// Released reports whether the voice has been stolen or retriggered, and is fading out.
+func (v *Voice) Released() bool {
+	v.voices.mu.Lock()
+	defer v.voices.mu.Unlock()
+	return v.released
+}
+
+// Cancel gives back a reserved voice that won't be played after all.
+func (v *Voice) Cancel() {
+	vs := v.voices
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	v.done = true
+	vs.removeLocked(v)
+}
+
+// ReleaseLocked starts the voice fading out.
+func (v *Voice) releaseLocked() {
+	v.released = true
+	v.fade = v.voices.release
+}
+
+// Stream plays the note, fading it out once released. It stops, and the mixer drops it, when
+// the note ends or has faded out.
+func (v *Voice) Stream(samples [][2]float64) (n int, ok bool) {
+	vs := v.voices
+	vs.mu.Lock()
+	done := v.done
+	vs.mu.Unlock()
+	if done {
+		return 0, false
+	}
+
+	n, ok = v.streamer.Stream(samples)
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	peak := 0.0
+	for i := range samples[:n] {
+		if v.released {
+			if v.fade <= 0 {
+				n, ok = i, false
+				break
+			}
+			g := float64(v.fade) / float64(vs.release+1)
+			samples[i][0] *= g
+			samples[i][1] *= g
+			v.fade--
+		}
+		peak = math.Max(peak, math.Max(math.Abs(samples[i][0]), math.Abs(samples[i][1])))
+	}
+	v.level = peak
+	if !ok || n < len(samples) {
+		v.done = true
+		vs.removeLocked(v)
+	}
+	return n, n > 0
+}
+
+func (v *Voice) Err() error {
+	return v.streamer.Err()
+}
@@ -0,0 +1,166 @@
+package midi_test
+
+import (
+	"testing"
+
+	"github.com/faiface/beep"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/stretchr/testify/require"
+)
+
+// Tone is a note held at a level for n samples.
+func tone(level float64, n int) beep.Streamer {
+	return beep.Take(n, beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			samples[i] = [2]float64{level, level}
+		}
+		return len(samples), true
+	}))
+}
+
+// Drain streams v until it ends, returning how many samples it played.
+func drain(v beep.Streamer) int {
+	total := 0
+	buf := make([][2]float64, 64)
+	for {
+		n, ok := v.Stream(buf)
+		total += n
+		if !ok {
+			return total
+		}
+	}
+}
+
+func TestVoices(t *testing.T) {
+	const sr = 48000
+	player := uuid.New()
+	key := func(note int) midi.VoiceKey {
+		return midi.VoiceKey{Player: player, Note: note}
+	}
+	buf := make([][2]float64, 64)
+
+	t.Run("steals the oldest voice when full", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{Max: 2, SampleRate: sr})
+		first := vs.Start(key(60), tone(0.5, sr))
+		vs.Start(key(62), tone(0.5, sr))
+		vs.Start(key(64), tone(0.5, sr))
+
+		stats := vs.Stats()
+		require.Equal(t, 2, stats.Active)
+		require.Equal(t, 1, stats.Stolen)
+		require.Equal(t, 2, stats.Peak)
+
+		// The stolen voice fades out in 5ms, rather than playing its whole second.
+		n := drain(first)
+		require.LessOrEqual(t, n, sr/200)
+		require.Greater(t, n, 0)
+	})
+
+	t.Run("steals the quietest voice", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{Max: 2, Steal: midi.StealQuietest, SampleRate: sr})
+		loud := vs.Start(key(60), tone(0.8, sr))
+		quiet := vs.Start(key(62), tone(0.1, sr))
+		loud.Stream(buf)
+		quiet.Stream(buf)
+
+		vs.Start(key(64), tone(0.5, sr))
+		require.Less(t, drain(quiet), sr/200+64)
+		n, ok := loud.Stream(buf)
+		require.True(t, ok)
+		require.Equal(t, len(buf), n)
+		require.Equal(t, 0.8, buf[0][0])
+	})
+
+	t.Run("doesn't steal a voice that hasn't been heard yet for being quiet", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{Max: 2, Steal: midi.StealQuietest, SampleRate: sr})
+		heard := vs.Start(key(60), tone(0.5, sr))
+		unheard := vs.Start(key(62), tone(0.1, sr))
+		heard.Stream(buf)
+
+		vs.Start(key(64), tone(0.5, sr))
+		require.Less(t, drain(heard), sr/200+1)
+		n, ok := unheard.Stream(buf)
+		require.True(t, ok)
+		require.Equal(t, len(buf), n)
+	})
+
+	t.Run("playing the same note again replaces it", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{Max: 4, SampleRate: sr})
+		old := vs.Start(key(60), tone(0.5, sr))
+		other := midi.VoiceKey{Player: uuid.New(), Note: 60}
+		vs.Start(other, tone(0.5, sr))
+		vs.Start(key(60), tone(0.5, sr))
+
+		stats := vs.Stats()
+		require.Equal(t, 2, stats.Active)
+		require.Equal(t, 1, stats.Retriggered)
+		require.Equal(t, 0, stats.Stolen)
+		require.LessOrEqual(t, drain(old), sr/200)
+	})
+
+	t.Run("fades out without a click", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{Max: 1, SampleRate: sr})
+		v := vs.Start(key(60), tone(1, sr))
+		v.Stream(buf)
+		vs.Start(key(62), tone(1, sr))
+
+		prev := 1.0
+		for {
+			n, ok := v.Stream(buf)
+			for _, s := range buf[:n] {
+				require.LessOrEqual(t, s[0], prev)
+				require.InDelta(t, prev, s[0], 0.01)
+				prev = s[0]
+			}
+			if !ok {
+				break
+			}
+		}
+		require.Less(t, prev, 0.01)
+	})
+
+	t.Run("voices leave when their note ends", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{SampleRate: sr})
+		v := vs.Start(key(60), tone(0.5, 100))
+		require.Equal(t, 1, vs.Stats().Active)
+		require.Equal(t, midi.DefaultMaxVoices, vs.Stats().Max)
+
+		require.Equal(t, 100, drain(v))
+		require.Equal(t, 0, vs.Stats().Active)
+		n, ok := v.Stream(buf)
+		require.Zero(t, n)
+		require.False(t, ok)
+	})
+
+	t.Run("reserves a voice before its note is rendered", func(t *testing.T) {
+		vs := midi.NewVoices(midi.VoicesOpts{Max: 2, SampleRate: sr})
+		first := vs.Reserve(key(60))
+		second := vs.Reserve(key(62))
+		require.Equal(t, 2, vs.Stats().Active)
+
+		// Mashing keys steals reservations too, so their notes needn't be rendered.
+		third := vs.Reserve(key(64))
+		require.True(t, first.Released())
+		require.False(t, second.Released())
+		require.Equal(t, 1, vs.Stats().Stolen)
+
+		first.Cancel()
+		second.Cancel()
+		require.Equal(t, 1, vs.Stats().Active)
+
+		third.Play(tone(0.5, 100))
+		require.Equal(t, 100, drain(third))
+		require.Equal(t, 0, vs.Stats().Active)
+	})
+
+	t.Run("parses steal policies", func(t *testing.T) {
+		for _, p := range []midi.StealPolicy{midi.StealOldest, midi.StealQuietest} {
+			got, err := midi.ParseStealPolicy(p.String())
+			require.NoError(t, err)
+			require.Equal(t, p, got)
+		}
+		_, err := midi.ParseStealPolicy("newest")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,253 @@
+// Package perform turns a single key press into a chord, a strum or an arpeggio.
+//
+// It knows nothing about the terminal UI, so any client sending MIDI to a jam can use it.
+// Play returns the notes to send and when to send them, the caller does the sending.
+package perform
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// Single plays the key's note, like a plain piano.
+	Single Mode = iota
+	// Chord plays a chord rooted on the key's note.
+	Chord
+	// Strum plays the chord's notes one after another, low to high.
+	Strum
+	// Arp arpeggiates the chord in time with the jam for a bar.
+	Arp
+)
+
+const (
+	Major Quality = iota
+	Minor
+	// Seventh is a dominant seventh chord.
+	Seventh
+	Major7
+	Minor7
+)
+
+const (
+	Up Pattern = iota
+	Down
+	Random
+)
+
+const (
+	// DefaultRate is the arpeggiator's note value, a sixteenth note.
+	DefaultRate = 16
+	// DefaultStrum is the time between strummed notes.
+	DefaultStrum = 30 * time.Millisecond
+)
+
+type (
+	Mode int
+
+	// Quality is the kind of chord played on a key.
+	Quality int
+
+	// Pattern is the order the arpeggiator plays a chord's notes in.
+	Pattern int
+
+	Settings struct {
+		Mode    Mode
+		Quality Quality
+		Pattern Pattern
+		// Arpeggiator note value, ex: 16 for sixteenths, 12 for eighth note triplets.
+		Rate int
+		// Time between strummed notes.
+		Strum time.Duration
+	}
+
+	// Event is a note to send Offset after the key was pressed.
+	Event struct {
+		Offset time.Duration
+		MIDI   wsmsg.MIDIMsg
+	}
+
+	Performer struct {
+		Settings Settings
+		rnd      *rand.Rand
+	}
+)
+
+// Semitones above the root of each chord quality.
+var intervals = map[Quality][]int{
+	Major:   {0, 4, 7},
+	Minor:   {0, 3, 7},
+	Seventh: {0, 4, 7, 10},
+	Major7:  {0, 4, 7, 11},
+	Minor7:  {0, 3, 7, 10},
+}
+
+var (
+	modeNames    = []string{"single", "chord", "strum", "arp"}
+	qualityNames = []string{"maj", "min", "7", "maj7", "min7"}
+	patternNames = []string{"up", "down", "random"}
+)
+
+// DefaultSettings plays single notes.
+func DefaultSettings() Settings {
+	return Settings{Mode: Single, Quality: Major, Pattern: Up, Rate: DefaultRate, Strum: DefaultStrum}
+}
+
+// New returns a performer. rnd drives the random arpeggio, a nil rnd is seeded from the time.
+func New(s Settings, rnd *rand.Rand) *Performer {
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return &Performer{Settings: s, rnd: rnd}
+}
+
+// ChordNotes returns the MIDI notes of a chord, leaving out any above the MIDI range.
+func ChordNotes(root int, q Quality) []int {
+	notes := make([]int, 0, len(intervals[q]))
+	for _, i := range intervals[q] {
+		if n := root + i; n <= 127 {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
+
+// Play returns the notes to send for a key press at time now. c is the jam's clock, which the
+// arpeggiator follows.
+func (p *Performer) Play(msg wsmsg.MIDIMsg, c tempo.Clock, now time.Time) []Event {
+	s := p.Settings
+	if s.Mode == Single {
+		return []Event{{MIDI: msg}}
+	}
+
+	chord := ChordNotes(msg.Number, s.Quality)
+	note := func(n int) wsmsg.MIDIMsg {
+		m := msg
+		m.Number = n
+		return m
+	}
+
+	events := make([]Event, 0)
+	switch s.Mode {
+	case Chord:
+		for _, n := range chord {
+			events = append(events, Event{MIDI: note(n)})
+		}
+
+	case Strum:
+		for i, n := range chord {
+			events = append(events, Event{Offset: time.Duration(i) * s.Strum, MIDI: note(n)})
+		}
+
+	case Arp:
+		length := c.NoteLength(s.Rate)
+		// Start on the closest note of the grid, which may have just gone by.
+		start := c.NextNote(now.Add(-length/2), s.Rate)
+		count := int(c.BarLength() / length)
+		if count < 1 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			offset := start.Add(time.Duration(i) * length).Sub(now)
+			if offset < 0 {
+				offset = 0
+			}
+			events = append(events, Event{Offset: offset, MIDI: note(p.arpNote(chord, i))})
+		}
+	}
+	return events
+}
+
+// ArpNote picks the i-th note of an arpeggio.
+func (p *Performer) arpNote(chord []int, i int) int {
+	switch p.Settings.Pattern {
+	case Down:
+		return chord[len(chord)-1-i%len(chord)]
+	case Random:
+		return chord[p.rnd.Intn(len(chord))]
+	}
+	return chord[i%len(chord)]
+}
+
+// Parse reads settings from words, ex: "arp min7 down 1/8" or "chord 7". Anything left out
+// keeps its value from s.
+func Parse(s Settings, words []string) (Settings, error) {
+	for _, w := range words {
+		w = strings.ToLower(w)
+		if i := indexOf(modeNames, w); i >= 0 {
+			s.Mode = Mode(i)
+			continue
+		}
+		if i := indexOf(qualityNames, w); i >= 0 {
+			s.Quality = Quality(i)
+			continue
+		}
+		if i := indexOf(patternNames, w); i >= 0 {
+			s.Pattern = Pattern(i)
+			continue
+		}
+		if rate, err := tempo.ParseNoteValue(w); err == nil {
+			s.Rate = rate
+			continue
+		}
+		if strings.HasSuffix(w, "ms") {
+			ms, err := strconv.Atoi(strings.TrimSuffix(w, "ms"))
+			if err != nil || ms < 1 || ms > 500 {
+				return s, fmt.Errorf("strum time %q should be 1ms to 500ms", w)
+			}
+			s.Strum = time.Duration(ms) * time.Millisecond
+			continue
+		}
+		return s, fmt.Errorf("unknown setting %q", w)
+	}
+	return s, nil
+}
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(modeNames) {
+		return "unknown"
+	}
+	return modeNames[m]
+}
+
+func (q Quality) String() string {
+	if q < 0 || int(q) >= len(qualityNames) {
+		return "unknown"
+	}
+	return qualityNames[q]
+}
+
+func (p Pattern) String() string {
+	if p < 0 || int(p) >= len(patternNames) {
+		return "unknown"
+	}
+	return patternNames[p]
+}
+
+// String describes the settings, ex: "arp min7 down 1/8".
+func (s Settings) String() string {
+	switch s.Mode {
+	case Chord:
+		return fmt.Sprintf("chord %s", s.Quality)
+	case Strum:
+		return fmt.Sprintf("strum %s %dms", s.Quality, s.Strum.Milliseconds())
+	case Arp:
+		return fmt.Sprintf("arp %s %s %s", s.Quality, s.Pattern, tempo.NoteValueName(s.Rate))
+	}
+	return s.Mode.String()
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
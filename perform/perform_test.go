@@ -0,0 +1,137 @@
+package perform_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/perform"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+var origin = time.Date(2022, 12, 1, 20, 0, 0, 0, time.UTC)
+
+// 120 BPM in 4/4, a sixteenth is 125ms.
+var clock = tempo.NewClock(120, tempo.CommonTime, origin)
+
+func press(n int) wsmsg.MIDIMsg {
+	return wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: n, Velocity: 127, Program: 4}
+}
+
+func notes(events []perform.Event) []int {
+	got := make([]int, len(events))
+	for i, e := range events {
+		got[i] = e.MIDI.Number
+	}
+	return got
+}
+
+func offsets(events []perform.Event) []time.Duration {
+	got := make([]time.Duration, len(events))
+	for i, e := range events {
+		got[i] = e.Offset
+	}
+	return got
+}
+
+func TestChordNotes(t *testing.T) {
+	tests := []struct {
+		quality perform.Quality
+		want    []int
+	}{
+		{perform.Major, []int{60, 64, 67}},
+		{perform.Minor, []int{60, 63, 67}},
+		{perform.Seventh, []int{60, 64, 67, 70}},
+		{perform.Major7, []int{60, 64, 67, 71}},
+		{perform.Minor7, []int{60, 63, 67, 70}},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, perform.ChordNotes(60, tt.quality), tt.quality.String())
+	}
+	// Notes above the MIDI range are left out.
+	require.Equal(t, []int{122, 126}, perform.ChordNotes(122, perform.Major))
+}
+
+func TestSingleAndChord(t *testing.T) {
+	p := perform.New(perform.DefaultSettings(), nil)
+	require.Equal(t, []perform.Event{{MIDI: press(60)}}, p.Play(press(60), clock, origin))
+
+	p.Settings.Mode = perform.Chord
+	p.Settings.Quality = perform.Minor
+	events := p.Play(press(62), clock, origin)
+	require.Equal(t, []int{62, 65, 69}, notes(events))
+	require.Equal(t, []time.Duration{0, 0, 0}, offsets(events))
+	// Chord notes keep the pressed note's instrument and velocity.
+	require.Equal(t, 4, events[2].MIDI.Program)
+	require.Equal(t, 127, events[2].MIDI.Velocity)
+}
+
+func TestStrum(t *testing.T) {
+	s := perform.DefaultSettings()
+	s.Mode = perform.Strum
+	s.Quality = perform.Seventh
+	events := perform.New(s, nil).Play(press(55), clock, origin)
+	require.Equal(t, []int{55, 59, 62, 65}, notes(events))
+	require.Equal(t, []time.Duration{0, 30 * time.Millisecond, 60 * time.Millisecond, 90 * time.Millisecond}, offsets(events))
+}
+
+func TestArp(t *testing.T) {
+	s := perform.DefaultSettings()
+	s.Mode = perform.Arp
+	s.Rate = 4
+	p := perform.New(s, nil)
+
+	// Pressed a little after a beat, the arpeggio starts right away and follows the beat.
+	now := origin.Add(40 * time.Millisecond)
+	events := p.Play(press(60), clock, now)
+	require.Equal(t, []int{60, 64, 67, 60}, notes(events))
+	require.Equal(t, []time.Duration{0, 460 * time.Millisecond, 960 * time.Millisecond, 1460 * time.Millisecond}, offsets(events))
+
+	// Pressed just before a beat, it waits for it.
+	now = origin.Add(490 * time.Millisecond)
+	events = p.Play(press(60), clock, now)
+	require.Equal(t, 10*time.Millisecond, events[0].Offset)
+
+	p.Settings.Pattern = perform.Down
+	require.Equal(t, []int{67, 64, 60, 67}, notes(p.Play(press(60), clock, origin)))
+
+	// A bar of eighth note triplets.
+	p.Settings.Rate = 12
+	require.Len(t, p.Play(press(60), clock, origin), 12)
+}
+
+func TestArpRandom(t *testing.T) {
+	s := perform.DefaultSettings()
+	s.Mode = perform.Arp
+	s.Pattern = perform.Random
+	p := perform.New(s, rand.New(rand.NewSource(1)))
+	events := p.Play(press(60), clock, origin)
+	require.Len(t, events, 16)
+	for _, n := range notes(events) {
+		require.Contains(t, []int{60, 64, 67}, n)
+	}
+
+	// The same seed plays the same arpeggio.
+	again := perform.New(s, rand.New(rand.NewSource(1))).Play(press(60), clock, origin)
+	require.Equal(t, notes(events), notes(again))
+}
+
+func TestParse(t *testing.T) {
+	s, err := perform.Parse(perform.DefaultSettings(), []string{"arp", "min7", "Down", "1/8t"})
+	require.NoError(t, err)
+	require.Equal(t, perform.Settings{
+		Mode: perform.Arp, Quality: perform.Minor7, Pattern: perform.Down, Rate: 12, Strum: perform.DefaultStrum,
+	}, s)
+	require.Equal(t, "arp min7 down 1/8t", s.String())
+
+	s, err = perform.Parse(s, []string{"strum", "45ms"})
+	require.NoError(t, err)
+	require.Equal(t, "strum min7 45ms", s.String())
+
+	_, err = perform.Parse(s, []string{"sus4"})
+	require.Error(t, err)
+	_, err = perform.Parse(s, []string{"0ms"})
+	require.Error(t, err)
+}
@@ -0,0 +1,241 @@
+// Package pianoroll draws recent notes as bars scrolling right to left, one row per two pitches.
+//
+// Each character cell is split into an upper and a lower half block, so a row of text shows two
+// neighbouring pitches, each in the color of the player who played it.
+package pianoroll
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// DefaultSpan is how much time the roll shows, oldest on the left.
+	DefaultSpan = 8 * time.Second
+	// DefaultLength is how long a note sounds when no NOTE_OFF ends it, as long as the jam's
+	// synth plays it.
+	DefaultLength = 2 * time.Second
+	// DefaultColor draws notes from players who haven't picked a color.
+	DefaultColor = "#7D56F4"
+	// Width of the note names on the left.
+	gutter = 4
+)
+
+type (
+	// Note is a bar on the roll.
+	Note struct {
+		Pitch int
+		// Color of the player who played it, in any form lipgloss takes.
+		Color string
+		// Who played it, so a NOTE_OFF ends the right bar.
+		Player string
+		Start  time.Time
+		// When the note ended, or ends at the latest if it's still sounding.
+		End time.Time
+	}
+
+	// Cell is a character of the roll. Each half is the color of the note there, empty if none.
+	Cell struct {
+		Top    string
+		Bottom string
+	}
+
+	// Frame is the roll at one moment, rows top to bottom. Row 0 spans the pitches Low+2*(len(Rows)-1)
+	// and one above.
+	Frame struct {
+		Low  int
+		Rows [][]Cell
+	}
+
+	Roll struct {
+		Span   time.Duration
+		Length time.Duration
+		notes  []Note
+	}
+)
+
+var (
+	gutterStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	gridStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("236"))
+)
+
+// New returns an empty roll with the default span and note length.
+func New() *Roll {
+	return &Roll{Span: DefaultSpan, Length: DefaultLength}
+}
+
+// NoteOn starts a bar for a note a player played at time at. It grows until a NOTE_OFF ends it,
+// or for Length. Players without a color get the default.
+func (r *Roll) NoteOn(player string, pitch int, color string, at time.Time) {
+	if color == "" {
+		color = DefaultColor
+	}
+	r.forget(at)
+	// Playing the note again ends the one still sounding, as it does in the synth.
+	for i := range r.notes {
+		if n := &r.notes[i]; n.Player == player && n.Pitch == pitch && n.End.After(at) {
+			n.End = at
+		}
+	}
+	r.notes = append(r.notes, Note{Pitch: pitch, Color: color, Player: player, Start: at, End: at.Add(r.Length)})
+}
+
+// NoteOff ends a player's latest bar for the pitch at time at, however long it was drawn.
+func (r *Roll) NoteOff(player string, pitch int, at time.Time) {
+	for i := len(r.notes) - 1; i >= 0; i-- {
+		n := &r.notes[i]
+		if n.Player == player && n.Pitch == pitch && !n.Start.After(at) {
+			n.End = at
+			return
+		}
+	}
+}
+
+// Clear removes every note.
+func (r *Roll) Clear() {
+	r.notes = nil
+}
+
+// Active reports whether any note is still on the roll at time now, so it needs redrawing as it scrolls.
+func (r *Roll) Active(now time.Time) bool {
+	for _, n := range r.notes {
+		if now.Sub(n.End) < r.Span {
+			return true
+		}
+	}
+	return false
+}
+
+// Frame lays the notes out in a grid of width columns and height rows, with now at the right edge.
+func (r *Roll) Frame(width, height int, now time.Time) Frame {
+	if width < 1 || height < 1 {
+		return Frame{}
+	}
+	start := now.Add(-r.Span)
+	visible := make([]Note, 0)
+	for _, n := range r.notes {
+		if n.End.After(start) && !n.Start.After(now) {
+			visible = append(visible, n)
+		}
+	}
+	low := fit(visible, height)
+
+	rows := make([][]Cell, height)
+	for i := range rows {
+		rows[i] = make([]Cell, width)
+	}
+	col := r.Span / time.Duration(width)
+	for _, n := range visible {
+		if n.Pitch < low || n.Pitch >= low+2*height {
+			continue
+		}
+		row := height - 1 - (n.Pitch-low)/2
+		first := int(n.Start.Sub(start) / col)
+		// Notes still sounding reach the right edge.
+		end := n.End
+		if end.After(now) {
+			end = now
+		}
+		last := int(end.Sub(start) / col)
+		// Even the shortest note gets a column.
+		if last == first {
+			last++
+		}
+		for c := max(first, 0); c < min(last, width); c++ {
+			// Later notes are drawn over earlier ones.
+			if (n.Pitch-low)%2 == 1 {
+				rows[row][c].Top = n.Color
+			} else {
+				rows[row][c].Bottom = n.Color
+			}
+		}
+	}
+	return Frame{Low: low, Rows: rows}
+}
+
+// Fit picks the lowest pitch shown, centring the notes played.
+func fit(notes []Note, height int) int {
+	pitches := 2 * height
+	if len(notes) == 0 {
+		// Around middle C.
+		return 60 - pitches/2
+	}
+	lo, hi := notes[0].Pitch, notes[0].Pitch
+	for _, n := range notes {
+		lo = min(lo, n.Pitch)
+		hi = max(hi, n.Pitch)
+	}
+	low := lo - (pitches-(hi-lo+1))/2
+	if hi-lo+1 > pitches {
+		// Too many to show, keep the top: melodies usually sit there.
+		low = hi - pitches + 1
+	}
+	return max(0, min(low, 128-pitches))
+}
+
+// Render draws the roll with note names down the left, in width by height characters.
+func (r *Roll) Render(width, height int, now time.Time) string {
+	f := r.Frame(width-gutter, height, now)
+	lines := make([]string, len(f.Rows))
+	for i, row := range f.Rows {
+		// Pitches of the row's lower and upper halves.
+		bottom := f.Low + 2*(len(f.Rows)-1-i)
+		var label string
+		for _, p := range []int{bottom + 1, bottom} {
+			if p%12 == 0 {
+				label = fmt.Sprintf("C%d", p/12-1)
+			}
+		}
+		var b strings.Builder
+		b.WriteString(gutterStyle.Render(fmt.Sprintf("%-*s", gutter, label)))
+		for _, c := range row {
+			b.WriteString(c.String(label != ""))
+		}
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String draws the cell. Rows with a C in them get a faint line, to read pitches by.
+func (c Cell) String(cRow bool) string {
+	switch {
+	case c.Top == "" && c.Bottom == "":
+		if cRow {
+			return gridStyle.Render("·")
+		}
+		return " "
+	case c.Bottom == "":
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(c.Top)).Render("▀")
+	case c.Top == "":
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(c.Bottom)).Render("▄")
+	case c.Top == c.Bottom:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(c.Top)).Render("█")
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(c.Top)).Background(lipgloss.Color(c.Bottom)).Render("▀")
+}
+
+// Forget drops notes that have scrolled off.
+func (r *Roll) forget(now time.Time) {
+	i := 0
+	for i < len(r.notes) && now.Sub(r.notes[i].End) > r.Span {
+		i++
+	}
+	r.notes = r.notes[i:]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
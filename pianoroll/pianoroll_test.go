@@ -0,0 +1,120 @@
+package pianoroll_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/pianoroll"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2022, 12, 1, 20, 0, 0, 0, time.UTC)
+
+func at(ms int) time.Time {
+	return start.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// Draw shows a frame as text: r and b for each player's halves, # for both and . for empty.
+func draw(f pianoroll.Frame) []string {
+	lines := make([]string, len(f.Rows))
+	for i, row := range f.Rows {
+		var b strings.Builder
+		for _, c := range row {
+			switch {
+			case c.Top == "" && c.Bottom == "":
+				b.WriteByte('.')
+			case c.Top != "" && c.Bottom != "":
+				b.WriteByte('#')
+			case c.Top != "":
+				b.WriteString(strings.ToUpper(c.Top[:1]))
+			default:
+				b.WriteString(c.Bottom[:1])
+			}
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+func TestFrame(t *testing.T) {
+	r := pianoroll.New()
+	r.Span = time.Second
+	r.Length = 200 * time.Millisecond
+
+	// A rising line from one player and a held note from another.
+	r.NoteOn("alice", 60, "red", at(0))
+	r.NoteOn("alice", 61, "red", at(200))
+	r.NoteOn("alice", 62, "red", at(400))
+	r.NoteOn("bob", 60, "blue", at(500))
+	r.NoteOff("bob", 60, at(900))
+
+	f := r.Frame(10, 2, at(1000))
+	// Four pitches from 60.
+	require.Equal(t, 60, f.Low)
+	require.Equal(t, []string{
+		"....rr....",
+		"rrRR.bbbb.",
+	}, draw(f))
+}
+
+func TestFrameScrolls(t *testing.T) {
+	r := pianoroll.New()
+	r.Span = time.Second
+	r.Length = 250 * time.Millisecond
+	r.NoteOn("alice", 64, "red", at(0))
+
+	require.Equal(t, []string{".........r"}, draw(r.Frame(10, 1, at(100))))
+	require.Equal(t, []string{"rrr......."}, draw(r.Frame(10, 1, at(950))))
+	require.True(t, r.Active(at(1200)))
+	// Gone once it scrolls off the left.
+	require.Equal(t, []string{".........."}, draw(r.Frame(10, 1, at(1300))))
+	require.False(t, r.Active(at(1300)))
+}
+
+func TestFrameHeldNote(t *testing.T) {
+	r := pianoroll.New()
+	r.Span = time.Second
+
+	// A held note grows until it's let go, however long that is.
+	r.NoteOn("alice", 64, "red", at(0))
+	require.Equal(t, []string{"......rrrr"}, draw(r.Frame(10, 1, at(400))))
+	require.Equal(t, []string{".rrrrrrrrr"}, draw(r.Frame(10, 1, at(900))))
+	r.NoteOff("alice", 64, at(900))
+	require.Equal(t, []string{"rrrrrrr..."}, draw(r.Frame(10, 1, at(1200))))
+
+	// One that's never let go stops once the synth would have.
+	r.NoteOn("bob", 64, "blue", at(2000))
+	require.Equal(t, []string{"bbbbbbbbbb"}, draw(r.Frame(10, 1, at(3500))))
+	require.Equal(t, []string{"bbbbb....."}, draw(r.Frame(10, 1, at(4500))))
+}
+
+func TestFrameFitsPitches(t *testing.T) {
+	r := pianoroll.New()
+	// Nothing played shows middle C.
+	require.Equal(t, 56, r.Frame(10, 4, at(0)).Low)
+
+	// More than fits keeps the highest notes.
+	r.NoteOn("alice", 30, "red", at(0))
+	r.NoteOn("alice", 90, "red", at(0))
+	f := r.Frame(10, 4, at(100))
+	require.Equal(t, 83, f.Low)
+	require.Equal(t, ".........R", draw(f)[0])
+	require.Equal(t, "..........", draw(f)[3])
+
+	// Never past the top of the MIDI range.
+	r.Clear()
+	r.NoteOn("alice", 127, "red", at(0))
+	require.Equal(t, 120, r.Frame(10, 4, at(100)).Low)
+}
+
+func TestRender(t *testing.T) {
+	r := pianoroll.New()
+	r.NoteOn("alice", 60, "", at(0))
+	out := r.Render(20, 3, at(100))
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 3)
+	// The row with middle C is labelled.
+	require.Contains(t, out, "C4")
+	require.Empty(t, r.Frame(0, 3, at(0)).Rows)
+}
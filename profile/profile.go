@@ -0,0 +1,96 @@
+// Package profile contains the local user identity presented to RMX servers.
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/config"
+)
+
+// FileName is the name of the profile file in the rmxtui config directory.
+const FileName = "profile.json"
+
+// MaxNameLen is the maximum number of characters in a display name.
+const MaxNameLen = 24
+
+type Profile struct {
+	// Stable user identifier. Generated once on first run.
+	ID uuid.UUID `json:"id"`
+	// Name shown to other players.
+	DisplayName string `json:"displayName"`
+	// Color used to render the user's name, ex: "#7D56F4".
+	Color string `json:"color"`
+}
+
+// Colors are the display colors offered when creating a profile.
+var Colors = []string{
+	"#7D56F4",
+	"#FF75B7",
+	"#73F59F",
+	"#D3A347",
+	"#4AB9F0",
+	"#F25D5D",
+	"#C1C6B2",
+}
+
+// New creates a profile with a freshly generated ID.
+func New(displayName, color string) (Profile, error) {
+	name, err := ValidateName(displayName)
+	if err != nil {
+		return Profile{}, err
+	}
+	return Profile{
+		ID:          uuid.New(),
+		DisplayName: name,
+		Color:       color,
+	}, nil
+}
+
+// ValidateName trims the given display name and checks it is usable.
+func ValidateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("display name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > MaxNameLen {
+		return "", fmt.Errorf("display name cannot be longer than %d characters", MaxNameLen)
+	}
+	return name, nil
+}
+
+// DefaultPath returns the location of the profile file in the rmxtui config directory.
+func DefaultPath() (string, error) {
+	return config.Path(FileName)
+}
+
+// Load reads the profile stored at path.
+// If no profile has been saved yet the returned error wraps os.ErrNotExist.
+func Load(path string) (Profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	var p Profile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Profile{}, fmt.Errorf("unmarshal profile: %w", err)
+	}
+	if p.ID == uuid.Nil {
+		return Profile{}, fmt.Errorf("profile %q has no ID", path)
+	}
+	return p, nil
+}
+
+// Save writes the profile to path, replacing any existing profile.
+func (p Profile) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal profile: %w", err)
+	}
+	return os.WriteFile(path, b, 0o600)
+}
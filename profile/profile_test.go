@@ -0,0 +1,50 @@
+package profile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), profile.FileName)
+
+	t.Run("reports a missing profile as not existing", func(t *testing.T) {
+		_, err := profile.Load(path)
+		require.True(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("keeps the same ID across saves", func(t *testing.T) {
+		p, err := profile.New("  Jazzy Jeff ", profile.Colors[1])
+		require.NoError(t, err)
+		require.NotEqual(t, uuid.Nil, p.ID)
+		require.Equal(t, "Jazzy Jeff", p.DisplayName)
+		require.NoError(t, p.Save(path))
+
+		got, err := profile.Load(path)
+		require.NoError(t, err)
+		require.Equal(t, p, got)
+
+		got.DisplayName = "DJ Jeff"
+		require.NoError(t, got.Save(path))
+
+		renamed, err := profile.Load(path)
+		require.NoError(t, err)
+		require.Equal(t, p.ID, renamed.ID)
+		require.Equal(t, "DJ Jeff", renamed.DisplayName)
+	})
+
+	t.Run("rejects bad display names", func(t *testing.T) {
+		_, err := profile.New("   ", profile.Colors[0])
+		require.Error(t, err)
+
+		_, err = profile.New(strings.Repeat("a", profile.MaxNameLen+1), profile.Colors[0])
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,125 @@
+// Package profileui contains the first-run prompt for choosing a display name and color.
+package profileui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidmidiex/rmxtui/profile"
+	"github.com/rapidmidiex/rmxtui/styles"
+	"golang.org/x/term"
+)
+
+var (
+	docStyle   = styles.DocStyle
+	titleStyle = styles.BoldStyle.Copy().MarginBottom(1)
+	hintStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+)
+
+type (
+	// CreatedMsg is sent once the user has confirmed their display name and color.
+	CreatedMsg struct {
+		Profile profile.Profile
+	}
+
+	keyMap struct {
+		NextColor key.Binding
+		PrevColor key.Binding
+		Confirm   key.Binding
+	}
+
+	model struct {
+		keys      keyMap
+		nameInput textinput.Model
+		colorIdx  int
+		err       error
+	}
+)
+
+var keys = keyMap{
+	NextColor: key.NewBinding(
+		key.WithKeys("down"),
+		key.WithHelp("↓", "next color"),
+	),
+	PrevColor: key.NewBinding(
+		key.WithKeys("up"),
+		key.WithHelp("↑", "previous color"),
+	),
+	Confirm: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "save"),
+	),
+}
+
+func New() model {
+	ti := textinput.New()
+	ti.Placeholder = "Your name"
+	ti.CharLimit = profile.MaxNameLen
+	ti.Width = profile.MaxNameLen
+	ti.Focus()
+
+	return model{
+		keys:      keys,
+		nameInput: ti,
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.NextColor):
+			m.colorIdx = (m.colorIdx + 1) % len(profile.Colors)
+			return m, nil
+		case key.Matches(msg, m.keys.PrevColor):
+			m.colorIdx = (m.colorIdx - 1 + len(profile.Colors)) % len(profile.Colors)
+			return m, nil
+		case key.Matches(msg, m.keys.Confirm):
+			p, err := profile.New(m.nameInput.Value(), profile.Colors[m.colorIdx])
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			return m, func() tea.Msg { return CreatedMsg{Profile: p} }
+		}
+	}
+
+	m.nameInput, cmd = m.nameInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) View() string {
+	physicalWidth, _, _ := term.GetSize(int(os.Stdout.Fd()))
+	doc := strings.Builder{}
+
+	color := profile.Colors[m.colorIdx]
+	swatch := lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+	name := m.nameInput.Value()
+	if name == "" {
+		name = "Your name"
+	}
+
+	doc.WriteString(titleStyle.Render("Welcome to RMX! Who's jamming?") + "\n")
+	doc.WriteString("Display name\n" + m.nameInput.View() + "\n\n")
+	doc.WriteString(fmt.Sprintf("Color  %s %s\n\n", swatch.Render("●"), swatch.Render(name)))
+	if m.err != nil {
+		doc.WriteString(styles.RenderError(m.err.Error()) + "\n\n")
+	}
+	doc.WriteString(hintStyle.Render("↑/↓ change color • enter save"))
+
+	if physicalWidth > 0 {
+		docStyle = styles.DocStyle.MaxWidth(physicalWidth)
+	}
+	return docStyle.Render(doc.String())
+}
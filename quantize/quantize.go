@@ -0,0 +1,135 @@
+// Package quantize pulls the notes we send onto the jam's rhythmic grid.
+//
+// Notes can only be held back, not sent early, so a note played ahead of the grid is delayed
+// until it lands on it and a late note is sent right away.
+package quantize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/tempo"
+)
+
+const (
+	// Swing percentages. Straight plays every note of the grid evenly, 66 is a triplet feel.
+	Straight = 50
+	MaxSwing = 75
+)
+
+// Settings is how notes are quantized.
+type Settings struct {
+	// Grid note value, ex: 16 for sixteenths, 12 for eighth note triplets. 0 turns quantizing off.
+	Value int
+	// Where the second note of each pair on the grid falls, as a percentage of the pair.
+	Swing int
+	// How far, in percent, a note is moved towards the grid.
+	Strength int
+}
+
+// Off doesn't quantize, with straight timing and full strength ready for when it's turned on.
+func Off() Settings {
+	return Settings{Swing: Straight, Strength: 100}
+}
+
+// Enabled reports whether notes are quantized.
+func (s Settings) Enabled() bool {
+	return s.Value > 0
+}
+
+// Quantize returns how long to hold a note played at time now, and how far it was from the grid:
+// negative when early, positive when late.
+func (s Settings) Quantize(c tempo.Clock, now time.Time) (delay, off time.Duration) {
+	if !s.Enabled() {
+		return 0, 0
+	}
+	off = now.Sub(s.Nearest(c, now))
+	delay = -off * time.Duration(s.Strength) / 100
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, off
+}
+
+// Nearest returns the point of the grid closest to t.
+func (s Settings) Nearest(c tempo.Clock, t time.Time) time.Time {
+	pair := 2 * c.NoteLength(s.Value)
+	swung := pair * time.Duration(s.Swing) / 100
+	n := t.Sub(c.Origin) / pair
+	if t.Before(c.Origin) && t.Sub(c.Origin)%pair != 0 {
+		n--
+	}
+	start := c.Origin.Add(n * pair)
+
+	best := start
+	for _, p := range []time.Time{start.Add(swung), start.Add(pair)} {
+		if abs(p.Sub(t)) < abs(best.Sub(t)) {
+			best = p
+		}
+	}
+	return best
+}
+
+// Parse reads settings from words, ex: "1/16 swing 60 strength 80" or "off". Anything left out
+// keeps its value from s.
+func Parse(s Settings, words []string) (Settings, error) {
+	for i := 0; i < len(words); i++ {
+		w := strings.ToLower(words[i])
+		switch w {
+		case "off":
+			s.Value = 0
+			continue
+		case "swing", "strength":
+			if i+1 == len(words) {
+				return s, fmt.Errorf("%s needs a percentage", w)
+			}
+			i++
+			n, err := strconv.Atoi(strings.TrimSuffix(words[i], "%"))
+			if err != nil {
+				return s, fmt.Errorf("%s %q should be a percentage", w, words[i])
+			}
+			if w == "swing" {
+				if n < Straight || n > MaxSwing {
+					return s, fmt.Errorf("swing should be %d%% to %d%%", Straight, MaxSwing)
+				}
+				s.Swing = n
+				continue
+			}
+			if n < 1 || n > 100 {
+				return s, fmt.Errorf("strength should be 1%% to 100%%")
+			}
+			s.Strength = n
+			continue
+		}
+		value, err := tempo.ParseNoteValue(w)
+		if err != nil {
+			return s, err
+		}
+		s.Value = value
+	}
+	return s, nil
+}
+
+// String describes the settings, ex: "1/16 swing 60% strength 80%".
+func (s Settings) String() string {
+	if !s.Enabled() {
+		return "off"
+	}
+	parts := []string{tempo.NoteValueName(s.Value)}
+	if s.Swing != Straight {
+		parts = append(parts, fmt.Sprintf("swing %d%%", s.Swing))
+	}
+	if s.Strength != 100 {
+		parts = append(parts, fmt.Sprintf("strength %d%%", s.Strength))
+	}
+	return strings.Join(parts, " ")
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
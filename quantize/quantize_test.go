@@ -0,0 +1,119 @@
+package quantize_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/quantize"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/stretchr/testify/require"
+)
+
+var origin = time.Date(2022, 12, 1, 20, 0, 0, 0, time.UTC)
+
+// 120 BPM in 4/4: a beat is 500ms and a sixteenth 125ms.
+var clock = tempo.NewClock(120, tempo.CommonTime, origin)
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+func TestQuantize(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  quantize.Settings
+		at        time.Duration
+		wantDelay time.Duration
+		wantOff   time.Duration
+	}{
+		{
+			name:     "off",
+			settings: quantize.Off(),
+			at:       ms(40),
+		},
+		{
+			name:      "early sixteenth waits for the grid",
+			settings:  quantize.Settings{Value: 16, Swing: 50, Strength: 100},
+			at:        ms(100),
+			wantDelay: ms(25),
+			wantOff:   ms(-25),
+		},
+		{
+			name:     "late sixteenth goes right away",
+			settings: quantize.Settings{Value: 16, Swing: 50, Strength: 100},
+			at:       ms(140),
+			wantOff:  ms(15),
+		},
+		{
+			name:     "on the grid",
+			settings: quantize.Settings{Value: 4, Swing: 50, Strength: 100},
+			at:       ms(1000),
+		},
+		{
+			name:      "half strength moves half way",
+			settings:  quantize.Settings{Value: 8, Swing: 50, Strength: 50},
+			at:        ms(200),
+			wantDelay: ms(25),
+			wantOff:   ms(-50),
+		},
+		{
+			// Eighth note triplets are 1000/6ms apart.
+			name:      "triplets",
+			settings:  quantize.Settings{Value: 12, Swing: 50, Strength: 100},
+			at:        ms(150),
+			wantDelay: time.Second/6 - ms(150),
+			wantOff:   ms(150) - time.Second/6,
+		},
+		{
+			// With 60% swing the second eighth of a beat moves from 250ms to 300ms.
+			name:      "swing",
+			settings:  quantize.Settings{Value: 8, Swing: 60, Strength: 100},
+			at:        ms(260),
+			wantDelay: ms(40),
+			wantOff:   ms(-40),
+		},
+		{
+			name:      "swing leaves the downbeat alone",
+			settings:  quantize.Settings{Value: 8, Swing: 60, Strength: 100},
+			at:        ms(480),
+			wantDelay: ms(20),
+			wantOff:   ms(-20),
+		},
+		{
+			name:      "before the clock's origin",
+			settings:  quantize.Settings{Value: 4, Swing: 50, Strength: 100},
+			at:        ms(-520),
+			wantDelay: ms(20),
+			wantOff:   ms(-20),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, off := tt.settings.Quantize(clock, origin.Add(tt.at))
+			require.Equal(t, tt.wantDelay, delay)
+			require.Equal(t, tt.wantOff, off)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := quantize.Parse(quantize.Off(), []string{"1/16", "swing", "60", "strength", "80%"})
+	require.NoError(t, err)
+	require.Equal(t, quantize.Settings{Value: 16, Swing: 60, Strength: 80}, s)
+	require.Equal(t, "1/16 swing 60% strength 80%", s.String())
+
+	s, err = quantize.Parse(s, []string{"1/8t"})
+	require.NoError(t, err)
+	require.Equal(t, "1/8t swing 60% strength 80%", s.String())
+
+	s, err = quantize.Parse(s, []string{"off"})
+	require.NoError(t, err)
+	require.False(t, s.Enabled())
+	require.Equal(t, "off", s.String())
+
+	for _, bad := range [][]string{{"1/5"}, {"swing"}, {"swing", "90"}, {"strength", "0"}, {"swing", "lots"}} {
+		_, err := quantize.Parse(quantize.Off(), bad)
+		require.Error(t, err, "%v", bad)
+	}
+}
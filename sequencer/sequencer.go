@@ -0,0 +1,252 @@
+// Package sequencer is a 16 step drum machine playing General MIDI percussion.
+package sequencer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rapidmidiex/rmxtui/config"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// Steps in a pattern. Each step is a sixteenth note.
+	Steps = 16
+	// Note value of a step.
+	StepValue = 16
+)
+
+const (
+	Off Step = iota
+	On
+	Accent
+)
+
+// Velocities of normal and accented hits.
+const (
+	onVelocity     = 96
+	accentVelocity = 127
+)
+
+type (
+	// Step is a single cell of the grid.
+	Step uint8
+
+	// Row is one drum's steps. It's saved as text, ex: "x...x...X...x...".
+	Row [Steps]Step
+
+	// Track is a drum voice and its steps.
+	Track struct {
+		Name string `json:"name"`
+		// General MIDI percussion note, ex: 36 for a kick drum.
+		Note  int `json:"note"`
+		Steps Row `json:"steps"`
+	}
+
+	// Pattern is a bar of drums.
+	Pattern struct {
+		Name   string  `json:"name"`
+		Tracks []Track `json:"tracks"`
+	}
+)
+
+// Default drum kit, from the General MIDI percussion map.
+var defaultKit = []Track{
+	{Name: "Kick", Note: 36},
+	{Name: "Snare", Note: 38},
+	{Name: "Clap", Note: 39},
+	{Name: "Closed Hat", Note: 42},
+	{Name: "Open Hat", Note: 46},
+	{Name: "Low Tom", Note: 45},
+	{Name: "High Tom", Note: 50},
+	{Name: "Crash", Note: 49},
+}
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ErrBadName is returned for pattern names that can't be used as file names.
+var ErrBadName = errors.New("pattern names may only use letters, numbers, - and _ (up to 32)")
+
+// NewPattern returns an empty pattern for the default drum kit.
+func NewPattern(name string) Pattern {
+	tracks := make([]Track, len(defaultKit))
+	copy(tracks, defaultKit)
+	return Pattern{Name: name, Tracks: tracks}
+}
+
+// Cycle moves a step from off, to on, to accented and back to off.
+func (p *Pattern) Cycle(track, step int) {
+	s := &p.Tracks[track].Steps[step]
+	*s = (*s + 1) % (Accent + 1)
+}
+
+// Toggle turns a step on, or off if it was on or accented.
+func (p *Pattern) Toggle(track, step int) {
+	s := &p.Tracks[track].Steps[step]
+	if *s == Off {
+		*s = On
+		return
+	}
+	*s = Off
+}
+
+// Clear turns every step off.
+func (p *Pattern) Clear() {
+	for i := range p.Tracks {
+		p.Tracks[i].Steps = Row{}
+	}
+}
+
+// Hits returns the notes played on a step.
+func (p Pattern) Hits(step int) []wsmsg.MIDIMsg {
+	hits := make([]wsmsg.MIDIMsg, 0)
+	for _, t := range p.Tracks {
+		if hit, ok := t.Hit(step); ok {
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
+
+// Hit returns the track's note on a step. It reports false if the step is off.
+func (t Track) Hit(step int) (wsmsg.MIDIMsg, bool) {
+	vel := onVelocity
+	switch t.Steps[step] {
+	case On:
+	case Accent:
+		vel = accentVelocity
+	default:
+		return wsmsg.MIDIMsg{}, false
+	}
+	return wsmsg.MIDIMsg{
+		State:    wsmsg.NOTE_ON,
+		Number:   t.Note,
+		Velocity: vel,
+		Channel:  midi.PercussionChannel,
+	}, true
+}
+
+// StepAt returns the step playing at a song position. Patterns repeat every Steps sixteenths
+// from the top of the song, so they line up for every player following the transport.
+func StepAt(sig tempo.Signature, pos tempo.Position) int {
+	n := Sixteenth(sig, pos)
+	return ((n % Steps) + Steps) % Steps
+}
+
+// Sixteenth counts the whole sixteenth notes from the top of the song to a position.
+func Sixteenth(sig tempo.Signature, pos tempo.Position) int {
+	// Steps are scheduled on the grid, don't let rounding put us on the previous one.
+	return int(math.Floor(sig.Notes(pos, StepValue) + 1e-6))
+}
+
+func (r Row) MarshalText() ([]byte, error) {
+	b := make([]byte, Steps)
+	for i, s := range r {
+		switch s {
+		case On:
+			b[i] = 'x'
+		case Accent:
+			b[i] = 'X'
+		default:
+			b[i] = '.'
+		}
+	}
+	return b, nil
+}
+
+func (r *Row) UnmarshalText(text []byte) error {
+	if len(text) != Steps {
+		return fmt.Errorf("row %q should have %d steps", text, Steps)
+	}
+	for i, c := range text {
+		switch c {
+		case 'x':
+			r[i] = On
+		case 'X':
+			r[i] = Accent
+		case '.', '-':
+			r[i] = Off
+		default:
+			return fmt.Errorf("row %q: unknown step %q, use x, X or .", text, c)
+		}
+	}
+	return nil
+}
+
+// ValidateName checks the pattern name can be used as a file name.
+func ValidateName(name string) error {
+	if !nameRe.MatchString(name) {
+		return ErrBadName
+	}
+	return nil
+}
+
+// Path returns where a pattern is saved in the rmxtui config directory.
+func Path(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	return config.Path("patterns", name+".json")
+}
+
+// List returns the names of the saved patterns.
+func List() ([]string, error) {
+	dir, err := config.Path("patterns")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads the pattern stored at path.
+func Load(path string) (Pattern, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Pattern{}, err
+	}
+	var p Pattern
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Pattern{}, fmt.Errorf("unmarshal pattern: %w", err)
+	}
+	if len(p.Tracks) == 0 {
+		return Pattern{}, fmt.Errorf("pattern %q has no tracks", filepath.Base(path))
+	}
+	for _, t := range p.Tracks {
+		if t.Note < 0 || t.Note > 127 {
+			return Pattern{}, fmt.Errorf("pattern %q: track %q has bad note %d", filepath.Base(path), t.Name, t.Note)
+		}
+	}
+	return p, nil
+}
+
+// Save writes the pattern to path, replacing any existing pattern.
+func (p Pattern) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal pattern: %w", err)
+	}
+	return os.WriteFile(path, b, 0o600)
+}
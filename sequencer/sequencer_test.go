@@ -0,0 +1,87 @@
+package sequencer_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/sequencer"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditing(t *testing.T) {
+	p := sequencer.NewPattern("beat")
+
+	p.Cycle(0, 4)
+	require.Equal(t, sequencer.On, p.Tracks[0].Steps[4])
+	p.Cycle(0, 4)
+	require.Equal(t, sequencer.Accent, p.Tracks[0].Steps[4])
+	p.Cycle(0, 4)
+	require.Equal(t, sequencer.Off, p.Tracks[0].Steps[4])
+
+	p.Toggle(1, 4)
+	p.Cycle(0, 4)
+	p.Cycle(0, 4)
+	require.Equal(t, []wsmsg.MIDIMsg{
+		{State: wsmsg.NOTE_ON, Number: 36, Velocity: 127, Channel: midi.PercussionChannel},
+		{State: wsmsg.NOTE_ON, Number: 38, Velocity: 96, Channel: midi.PercussionChannel},
+	}, p.Hits(4))
+	require.Empty(t, p.Hits(5))
+
+	p.Clear()
+	require.Empty(t, p.Hits(4))
+}
+
+func TestStepAt(t *testing.T) {
+	tests := []struct {
+		sig  tempo.Signature
+		pos  tempo.Position
+		want int
+	}{
+		{sig: tempo.CommonTime, pos: tempo.Position{Bar: 1, Beat: 1}, want: 0},
+		{sig: tempo.CommonTime, pos: tempo.Position{Bar: 1, Beat: 2, Fraction: 0.25}, want: 5},
+		{sig: tempo.CommonTime, pos: tempo.Position{Bar: 3, Beat: 4, Fraction: 0.75}, want: 15},
+		// Just short of a step because of floating point error still counts as that step.
+		{sig: tempo.CommonTime, pos: tempo.Position{Bar: 2, Beat: 1, Fraction: 0.9999999999}, want: 4},
+		// A pattern spans a bar and a third of 3/4.
+		{sig: tempo.Signature{Beats: 3, Unit: 4}, pos: tempo.Position{Bar: 2, Beat: 2}, want: 0},
+		// Steps are still sixteenths in 6/8.
+		{sig: tempo.Signature{Beats: 6, Unit: 8}, pos: tempo.Position{Bar: 1, Beat: 4}, want: 6},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, sequencer.StepAt(tt.sig, tt.pos), "%s in %s", tt.pos, tt.sig)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beat.json")
+	p := sequencer.NewPattern("beat")
+	p.Toggle(0, 0)
+	p.Cycle(3, 2)
+	p.Cycle(3, 2)
+	require.NoError(t, p.Save(path))
+
+	got, err := sequencer.Load(path)
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+
+	text, err := got.Tracks[3].Steps.MarshalText()
+	require.NoError(t, err)
+	require.Equal(t, "..X.............", string(text))
+}
+
+func TestLoadRejectsBadRows(t *testing.T) {
+	var row sequencer.Row
+	require.Error(t, row.UnmarshalText([]byte("x...")))
+	require.Error(t, row.UnmarshalText([]byte("x...o...x...x...")))
+	require.NoError(t, row.UnmarshalText([]byte("x---x---X---x---")))
+}
+
+func TestValidateName(t *testing.T) {
+	require.NoError(t, sequencer.ValidateName("four-on-the_floor2"))
+	require.ErrorIs(t, sequencer.ValidateName("../secrets"), sequencer.ErrBadName)
+	require.ErrorIs(t, sequencer.ValidateName(""), sequencer.ErrBadName)
+}
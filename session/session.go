@@ -0,0 +1,155 @@
+// Package session records the MIDI notes played in a Jam Session so they can be rendered later.
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/config"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+type (
+	// Event is a single MIDI message played by a user during a recording, or a change to their
+	// mixer strip.
+	Event struct {
+		// Time since the recording started.
+		At     time.Duration `json:"at"`
+		UserID uuid.UUID     `json:"userId"`
+		// User's display name when the note was played.
+		Name string        `json:"name,omitempty"`
+		MIDI wsmsg.MIDIMsg `json:"midi"`
+		// Set when the event is the user's volume, pan and mute from now on, rather than a note.
+		// The master fader's is recorded as uuid.Nil.
+		Strip *audio.Strip `json:"strip,omitempty"`
+	}
+
+	// Recorder appends events to a recording file as they are played.
+	Recorder struct {
+		mu    sync.Mutex
+		f     *os.File
+		enc   *json.Encoder
+		start time.Time
+		count int
+		// Strips as last recorded, so only changes are.
+		strips map[uuid.UUID]audio.Strip
+	}
+)
+
+// NewPath returns a path for a new recording of the given Jam Session in the rmxtui config directory.
+func NewPath(roomID string, now time.Time) (string, error) {
+	return config.Path("recordings", fmt.Sprintf("%s-%s.jsonl", config.FileName(roomID), now.Format("20060102-150405")))
+}
+
+// NewRecorder creates the recording file at path. The recording's clock starts now.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		f:      f,
+		enc:    json.NewEncoder(f),
+		start:  time.Now(),
+		strips: make(map[uuid.UUID]audio.Strip),
+	}, nil
+}
+
+// Record appends a MIDI message played by the user.
+func (r *Recorder) Record(userID uuid.UUID, name string, msg wsmsg.MIDIMsg) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return os.ErrClosed
+	}
+	if err := r.write(Event{UserID: userID, Name: name, MIDI: msg}); err != nil {
+		return err
+	}
+	r.count++
+	return nil
+}
+
+// RecordStrip records the user's mixer strip, uuid.Nil for the master fader, if it's changed
+// since it was last recorded. Solo isn't recorded, it's only for the moment.
+func (r *Recorder) RecordStrip(userID uuid.UUID, name string, s audio.Strip) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return os.ErrClosed
+	}
+	s.Solo = false
+	if last, ok := r.strips[userID]; ok && last == s {
+		return nil
+	}
+	if err := r.write(Event{UserID: userID, Name: name, Strip: &s}); err != nil {
+		return err
+	}
+	r.strips[userID] = s
+	return nil
+}
+
+func (r *Recorder) write(e Event) error {
+	e.At = time.Since(r.start)
+	if err := r.enc.Encode(e); err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	return nil
+}
+
+// Elapsed returns the time since the recording started.
+func (r *Recorder) Elapsed() time.Duration {
+	return time.Since(r.start)
+}
+
+// Count returns the number of notes recorded so far.
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Path returns the location of the recording file.
+func (r *Recorder) Path() string {
+	return r.f.Name()
+}
+
+// Close finishes the recording.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// Load reads every event of the recording at path, in the order they were played.
+func Load(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := make([]Event, 0)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		events = append(events, e)
+	}
+	return events, sc.Err()
+}
@@ -0,0 +1,93 @@
+package session_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/config"
+	"github.com/rapidmidiex/rmxtui/session"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jam.jsonl")
+	rec, err := session.NewRecorder(path)
+	require.NoError(t, err)
+
+	jeff, jen := uuid.New(), uuid.New()
+	notes := []struct {
+		user uuid.UUID
+		name string
+		msg  wsmsg.MIDIMsg
+	}{
+		{jeff, "Jeff", wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 60, Velocity: 127}},
+		{jen, "Jen", wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 43, Velocity: 90, Program: 33}},
+		{jeff, "Jeff", wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 64, Velocity: 100}},
+	}
+	for _, n := range notes {
+		require.NoError(t, rec.Record(n.user, n.name, n.msg))
+	}
+	require.Equal(t, 3, rec.Count())
+	require.NoError(t, rec.Close())
+	require.Error(t, rec.Record(jeff, "Jeff", notes[0].msg), "recording after close")
+
+	_, err = session.NewRecorder(path)
+	require.Error(t, err, "should not overwrite an existing recording")
+
+	events, err := session.Load(path)
+	require.NoError(t, err)
+	require.Len(t, events, len(notes))
+	for i, e := range events {
+		require.Equal(t, notes[i].user, e.UserID)
+		require.Equal(t, notes[i].name, e.Name)
+		require.Equal(t, notes[i].msg, e.MIDI)
+		if i > 0 {
+			require.GreaterOrEqual(t, e.At, events[i-1].At)
+		}
+	}
+}
+
+func TestRecordStrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jam.jsonl")
+	rec, err := session.NewRecorder(path)
+	require.NoError(t, err)
+
+	jeff := uuid.New()
+	loud := audio.Strip{Volume: 3, Pan: -0.5}
+	require.NoError(t, rec.RecordStrip(jeff, "Jeff", loud))
+	require.NoError(t, rec.RecordStrip(jeff, "Jeff", loud), "unchanged strips aren't recorded again")
+	loud.Solo = true
+	require.NoError(t, rec.RecordStrip(jeff, "Jeff", loud), "nor is solo")
+	require.NoError(t, rec.Record(jeff, "Jeff", wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 60, Velocity: 127}))
+	require.NoError(t, rec.RecordStrip(jeff, "Jeff", audio.Strip{Mute: true}))
+	require.NoError(t, rec.RecordStrip(uuid.Nil, "", audio.Strip{Volume: -6}))
+	require.Equal(t, 1, rec.Count(), "strips aren't notes")
+	require.NoError(t, rec.Close())
+	require.Error(t, rec.RecordStrip(jeff, "Jeff", loud), "recording after close")
+
+	events, err := session.Load(path)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	require.Equal(t, &audio.Strip{Volume: 3, Pan: -0.5}, events[0].Strip)
+	require.Nil(t, events[1].Strip)
+	require.Equal(t, 60, events[1].MIDI.Number)
+	require.Equal(t, &audio.Strip{Mute: true}, events[2].Strip)
+	require.Equal(t, uuid.Nil, events[3].UserID)
+	require.Equal(t, &audio.Strip{Volume: -6}, events[3].Strip)
+}
+
+func TestNewPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir, err := config.Dir()
+	require.NoError(t, err)
+	at := time.Date(2023, 1, 31, 20, 15, 0, 0, time.UTC)
+
+	// A room ID from the server can't reach outside the recordings directory.
+	path, err := session.NewPath("../../escape", at)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "recordings", "escape-20230131-201500.jsonl"), path)
+}
@@ -0,0 +1,342 @@
+// Package stems renders a recorded Jam Session to audio: a stem for each player, and a
+// mixdown of them all as the jam sounded.
+package stems
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/session"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// NoteLength is how long each note is rendered, as long as the jam's synth plays it.
+	NoteLength = 2 * time.Second
+	// MixdownFile is the name of the mixdown in the output directory.
+	MixdownFile = "mixdown.wav"
+	// Frames rendered at a time between events.
+	chunk = 4096
+)
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type (
+	// Synth renders notes, like a midi.Engine.
+	Synth interface {
+		SampleRate() int
+		Note(msg wsmsg.MIDIMsg, length time.Duration) (beep.Streamer, error)
+	}
+
+	Opts struct {
+		// Renders the notes, at its sample rate.
+		Synth Synth
+		// Directory the WAV files are written to. It's created if need be.
+		Dir string
+	}
+
+	// Stem is a player's part of the session.
+	Stem struct {
+		UserID uuid.UUID
+		Name   string
+		Path   string
+		Notes  int
+	}
+
+	// Result is what was rendered.
+	Result struct {
+		// In the order the players first played.
+		Stems   []Stem
+		Mixdown string
+		// Every file is this long, from the start of the recording.
+		Length time.Duration
+	}
+
+	// Track renders one file: a timeline of the player's events, or everyone's for the mixdown.
+	track struct {
+		timeline *timeline
+		// What's written, the timeline or something on it.
+		out beep.Streamer
+		w   *audio.WAVWriter
+		// Frames to throw away from the start of out, to line it up with the other tracks.
+		skip int
+	}
+
+	// Timeline is a beep.Streamer playing a mixer, changing it as the recording did at the
+	// moment each event comes up.
+	timeline struct {
+		mixer  *audio.Mixer
+		sr     beep.SampleRate
+		events []session.Event
+		// Applies an event to the mixer.
+		apply func(i int, e session.Event) error
+		pos   int
+		next  int
+		err   error
+	}
+
+	// Notes renders each note once, for the stem and the mixdown both.
+	notes struct {
+		synth  Synth
+		sr     beep.SampleRate
+		clips  map[int]clip
+		played map[int]int
+	}
+)
+
+// Render writes a WAV file for each player in the recording, and a mixdown. Every file starts
+// at the start of the recording, so they line up in an editor.
+//
+// Each stem has the player's instrument, volume and pan as the recording went, but not mute,
+// so a muted player's part isn't lost. The mixdown mutes them, and goes through the master
+// fader and a limiter, like the jam did.
+func Render(events []session.Event, o Opts) (Result, error) {
+	sr := beep.SampleRate(o.Synth.SampleRate())
+	stems, length := players(events)
+	if len(stems) == 0 {
+		return Result{}, fmt.Errorf("no notes to render")
+	}
+	if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+		return Result{}, err
+	}
+	result := Result{Stems: stems, Mixdown: filepath.Join(o.Dir, MixdownFile), Length: length}
+	for i, file := range fileNames(stems) {
+		stems[i].Path = filepath.Join(o.Dir, file)
+	}
+
+	n := &notes{synth: o.Synth, sr: sr, clips: make(map[int]clip), played: make(map[int]int)}
+	tracks := make([]*track, 0, len(stems)+1)
+	defer func() {
+		// Only left open if rendering failed.
+		for _, t := range tracks {
+			t.w.Close()
+		}
+	}()
+	for _, s := range stems {
+		id := s.UserID
+		t, err := newTrack(s.Path, sr, events, func(i int, e session.Event, m *audio.Mixer) error {
+			if e.UserID != id {
+				return nil
+			}
+			if e.Strip != nil {
+				strip := *e.Strip
+				strip.Mute = false
+				m.SetStrip(id, strip)
+				return nil
+			}
+			return n.play(i, e, m)
+		})
+		if err != nil {
+			return Result{}, err
+		}
+		tracks = append(tracks, t)
+	}
+	mix, err := newTrack(result.Mixdown, sr, events, func(i int, e session.Event, m *audio.Mixer) error {
+		switch {
+		case e.Strip != nil && e.UserID == uuid.Nil:
+			m.SetMaster(*e.Strip)
+		case e.Strip != nil:
+			m.SetStrip(e.UserID, *e.Strip)
+		default:
+			return n.play(i, e, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	l := audio.NewLimiter(audio.NewLimiterOpts{Streamer: mix.timeline, SampleRate: sr})
+	mix.out, mix.skip = l, sr.N(l.Latency())
+	tracks = append(tracks, mix)
+
+	// Every track is rendered a chunk at a time, so each note is only kept while it's playing.
+	buf := make([][2]float64, chunk)
+	for left := sr.N(length); left > 0; left -= min(left, chunk) {
+		for _, t := range tracks {
+			if err := t.render(buf[:min(left, chunk)]); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+
+	for _, t := range tracks {
+		if err := t.w.Close(); err != nil {
+			return Result{}, fmt.Errorf("close WAV: %w", err)
+		}
+	}
+	tracks = nil
+	return result, nil
+}
+
+// Players returns a stem for each player who played a note, and how long the recording is
+// with its last note rung out.
+func players(events []session.Event) ([]Stem, time.Duration) {
+	var (
+		stems  []Stem
+		index  = make(map[uuid.UUID]int)
+		length time.Duration
+	)
+	for _, e := range events {
+		if e.Strip != nil || !sounds(e.MIDI) {
+			continue
+		}
+		if e.At+NoteLength > length {
+			length = e.At + NoteLength
+		}
+		if i, ok := index[e.UserID]; ok {
+			stems[i].Notes++
+			continue
+		}
+		index[e.UserID] = len(stems)
+		stems = append(stems, Stem{UserID: e.UserID, Name: e.Name, Notes: 1})
+	}
+	return stems, length
+}
+
+// FileNames names each stem's file after its player, ex: "DJ Jeff!" is "DJ-Jeff.wav". Players
+// with the same name are told apart by the start of their ID.
+func fileNames(stems []Stem) []string {
+	names := make([]string, len(stems))
+	taken := map[string]int{strings.TrimSuffix(MixdownFile, ".wav"): 1}
+	for i, s := range stems {
+		names[i] = strings.Trim(unsafeChars.ReplaceAllString(s.Name, "-"), "-")
+		if names[i] == "" {
+			names[i] = "player"
+		}
+		taken[names[i]]++
+	}
+	for i, s := range stems {
+		if taken[names[i]] > 1 {
+			names[i] += "-" + s.UserID.String()[:8]
+		}
+		names[i] += ".wav"
+	}
+	return names
+}
+
+// Sounds reports whether a message plays a note. The jam's synth renders note offs as silence.
+func sounds(msg wsmsg.MIDIMsg) bool {
+	return msg.State == wsmsg.NOTE_ON && msg.Velocity > 0
+}
+
+func newTrack(path string, sr beep.SampleRate, events []session.Event, apply func(int, session.Event, *audio.Mixer) error) (*track, error) {
+	w, err := audio.CreateWAV(path, sr)
+	if err != nil {
+		return nil, fmt.Errorf("create WAV: %w", err)
+	}
+	tl := &timeline{mixer: audio.NewMixer(audio.NewSettings()), sr: sr, events: events}
+	tl.apply = func(i int, e session.Event) error { return apply(i, e, tl.mixer) }
+	return &track{timeline: tl, out: tl, w: w}, nil
+}
+
+// Render writes the next len(buf) frames of the track.
+func (t *track) render(buf [][2]float64) error {
+	// The first frames out of a limiter are its lookahead, from before the recording.
+	for t.skip > 0 {
+		n := min(t.skip, len(buf))
+		t.out.Stream(buf[:n])
+		t.skip -= n
+	}
+	t.out.Stream(buf)
+	if t.timeline.err != nil {
+		return t.timeline.err
+	}
+	if err := t.w.Write(buf); err != nil {
+		return fmt.Errorf("write WAV: %w", err)
+	}
+	return nil
+}
+
+// Stream plays the mixer, stopping at each event due to apply it. It plays silence after an
+// error, which Render checks for.
+func (tl *timeline) Stream(samples [][2]float64) (n int, ok bool) {
+	for len(samples) > 0 {
+		for tl.next < len(tl.events) && tl.sr.N(tl.events[tl.next].At) <= tl.pos {
+			if err := tl.apply(tl.next, tl.events[tl.next]); err != nil && tl.err == nil {
+				tl.err = err
+			}
+			tl.next++
+		}
+		part := samples
+		if tl.next < len(tl.events) {
+			part = samples[:min(len(samples), tl.sr.N(tl.events[tl.next].At)-tl.pos)]
+		}
+		tl.mixer.Stream(part)
+		tl.pos += len(part)
+		n += len(part)
+		samples = samples[len(part):]
+	}
+	return n, true
+}
+
+func (tl *timeline) Err() error {
+	return tl.err
+}
+
+// Play adds event i's note to the mixer. Each note is rendered when the first track needs it,
+// and forgotten once the second, the mixdown or the player's stem, has it too.
+func (n *notes) play(i int, e session.Event, m *audio.Mixer) error {
+	if !sounds(e.MIDI) {
+		return nil
+	}
+	c, ok := n.clips[i]
+	if !ok {
+		var err error
+		if c, err = renderNote(n.synth, e.MIDI, n.sr); err != nil {
+			return err
+		}
+		n.clips[i] = c
+	}
+	n.played[i]++
+	if n.played[i] == 2 {
+		delete(n.clips, i)
+		delete(n.played, i)
+	}
+	m.Add(e.UserID, c.streamer())
+	return nil
+}
+
+// Clip is a rendered note, streamed by as many mixers as need it.
+type clip [][2]float64
+
+func renderNote(synth Synth, msg wsmsg.MIDIMsg, sr beep.SampleRate) (clip, error) {
+	s, err := synth.Note(msg, NoteLength)
+	if err != nil {
+		return nil, fmt.Errorf("render note: %w", err)
+	}
+	c := make(clip, sr.N(NoteLength))
+	n := 0
+	for n < len(c) {
+		k, ok := s.Stream(c[n:])
+		n += k
+		if !ok {
+			break
+		}
+	}
+	return c[:n], nil
+}
+
+func (c clip) streamer() beep.Streamer {
+	return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		if len(c) == 0 {
+			return 0, false
+		}
+		n := copy(samples, c)
+		c = c[n:]
+		return n, true
+	})
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,172 @@
+package stems_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/session"
+	"github.com/rapidmidiex/rmxtui/stems"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+const sr = 48000
+
+// SineSynth plays every note as a sine at half the velocity's level, so tests don't need the
+// SoundFont.
+type sineSynth struct{}
+
+func (sineSynth) SampleRate() int { return sr }
+
+func (sineSynth) Note(msg wsmsg.MIDIMsg, length time.Duration) (beep.Streamer, error) {
+	freq := 440 * math.Pow(2, float64(msg.Number-69)/12)
+	level := 0.5 * float64(msg.Velocity) / 127
+	i := 0
+	return beep.Take(beep.SampleRate(sr).N(length), beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for j := range samples {
+			v := level * math.Sin(2*math.Pi*freq*float64(i)/sr)
+			samples[j] = [2]float64{v, v}
+			i++
+		}
+		return len(samples), true
+	})), nil
+}
+
+func note(at time.Duration, user uuid.UUID, name string, number int) session.Event {
+	return session.Event{At: at, UserID: user, Name: name, MIDI: wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: number, Velocity: 127}}
+}
+
+func strip(at time.Duration, user uuid.UUID, s audio.Strip) session.Event {
+	return session.Event{At: at, UserID: user, Strip: &s}
+}
+
+// ReadWAV returns a WAV file's samples.
+func readWAV(t *testing.T, path string) [][2]float64 {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	format := beep.Format{SampleRate: sr, NumChannels: 2, Precision: 2}
+	data := b[44:]
+	frames := make([][2]float64, 0, len(data)/format.Width())
+	for len(data) > 0 {
+		frame, n := format.DecodeSigned(data)
+		frames = append(frames, frame)
+		data = data[n:]
+	}
+	return frames
+}
+
+// Peak returns the loudest sample of each channel between from and to.
+func peak(frames [][2]float64, from, to time.Duration) [2]float64 {
+	var p [2]float64
+	for _, f := range frames[beep.SampleRate(sr).N(from):beep.SampleRate(sr).N(to)] {
+		for c := range f {
+			p[c] = math.Max(p[c], math.Abs(f[c]))
+		}
+	}
+	return p
+}
+
+func TestRender(t *testing.T) {
+	jeff, jen := uuid.New(), uuid.New()
+	events := []session.Event{
+		strip(0, uuid.Nil, audio.Strip{}),
+		strip(0, jeff, audio.Strip{Volume: -6}),
+		note(0, jeff, "Jeff", 69),
+		strip(time.Second/4, jen, audio.Strip{Volume: -6, Pan: -0.5}),
+		note(time.Second/2, jen, "Jen!", 57),
+		note(time.Second, jeff, "Jeff", 72),
+		{At: time.Second, UserID: jeff, Name: "Jeff", MIDI: wsmsg.MIDIMsg{State: wsmsg.NOTE_OFF, Number: 69}},
+		strip(3*time.Second/2, jen, audio.Strip{Volume: -6, Pan: -0.5, Mute: true}),
+	}
+	dir := filepath.Join(t.TempDir(), "stems")
+	res, err := stems.Render(events, stems.Opts{Synth: sineSynth{}, Dir: dir})
+	require.NoError(t, err)
+
+	require.Equal(t, 3*time.Second, res.Length, "the last note rung out")
+	require.Len(t, res.Stems, 2)
+	require.Equal(t, filepath.Join(dir, "Jeff.wav"), res.Stems[0].Path)
+	require.Equal(t, 2, res.Stems[0].Notes)
+	require.Equal(t, filepath.Join(dir, "Jen.wav"), res.Stems[1].Path)
+	require.Equal(t, filepath.Join(dir, "mixdown.wav"), res.Mixdown)
+
+	jeffs, jens, mix := readWAV(t, res.Stems[0].Path), readWAV(t, res.Stems[1].Path), readWAV(t, res.Mixdown)
+	for _, frames := range [][][2]float64{jeffs, jens, mix} {
+		require.Len(t, frames, 3*sr, "every file is the whole recording")
+	}
+
+	// Jeff is 6 dB down.
+	require.InDelta(t, 0.5*math.Pow(10, -6.0/20), peak(jeffs, 0, time.Second)[0], 0.01)
+	// Jen starts half a second in, 6 dB down and panned half left, and her stem keeps playing
+	// once she's muted.
+	require.Equal(t, [2]float64{}, peak(jens, 0, time.Second/2))
+	p := peak(jens, time.Second/2, 5*time.Second/2)
+	jenLevel := 0.5 * math.Pow(10, -6.0/20)
+	require.InDelta(t, jenLevel*1.5, p[0], 0.01)
+	require.InDelta(t, jenLevel*0.5, p[1], 0.01)
+
+	// The mixdown is the stems added up, in line with them, until Jen is muted.
+	for i := range mix[:sr*3/2] {
+		for c := range mix[i] {
+			want := jeffs[i][c] + jens[i][c]
+			if math.Abs(mix[i][c]-want) > 0.001 {
+				t.Fatalf("mixdown frame %d channel %d: got %f, want %f", i, c, mix[i][c], want)
+			}
+		}
+	}
+	after := sr*3/2 + 100
+	require.InDelta(t, jeffs[after][1], mix[after][1], 0.001)
+	require.InDelta(t, jeffs[after][0], mix[after][0], 0.001)
+}
+
+func TestRenderMixdownLimited(t *testing.T) {
+	var events []session.Event
+	for i := 0; i < 6; i++ {
+		events = append(events, note(0, uuid.New(), "Player", 69))
+	}
+	res, err := stems.Render(events, stems.Opts{Synth: sineSynth{}, Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	// Six players with the same name, told apart by ID.
+	seen := make(map[string]bool)
+	for _, s := range res.Stems {
+		require.NotEqual(t, "Player.wav", filepath.Base(s.Path))
+		seen[s.Path] = true
+	}
+	require.Len(t, seen, 6)
+
+	// Six notes at half scale would be 3, the limiter keeps the mixdown under -1 dBFS.
+	p := peak(readWAV(t, res.Mixdown), 0, 2*time.Second)
+	require.LessOrEqual(t, p[0], math.Pow(10, -1.0/20)+0.001)
+	require.Greater(t, p[0], 0.8)
+}
+
+func TestRenderNothing(t *testing.T) {
+	_, err := stems.Render([]session.Event{strip(0, uuid.New(), audio.Strip{})}, stems.Opts{Synth: sineSynth{}, Dir: t.TempDir()})
+	require.Error(t, err)
+}
+
+func TestRenderOsc(t *testing.T) {
+	// The built-in synth renders the same recording the same every time.
+	jeff, jen := uuid.New(), uuid.New()
+	bass := note(0, jen, "Jen", 40)
+	bass.MIDI.Preset = "bass"
+	events := []session.Event{note(0, jeff, "Jeff", 60), bass}
+	synth := midi.NewOsc(midi.OscOpts{SampleRate: sr})
+
+	var mixes [][][2]float64
+	for i := 0; i < 2; i++ {
+		res, err := stems.Render(events, stems.Opts{Synth: synth, Dir: t.TempDir()})
+		require.NoError(t, err)
+		mixes = append(mixes, readWAV(t, res.Mixdown))
+	}
+	require.Equal(t, mixes[0], mixes[1])
+	require.Greater(t, peak(mixes[0], 0, time.Second)[0], 0.1)
+}
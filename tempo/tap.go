@@ -0,0 +1,53 @@
+package tempo
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Taps further apart than this start a new measurement.
+	TapTimeout = 2 * time.Second
+	// Only the latest taps are averaged so the tempo can follow the tapper.
+	maxTaps = 8
+)
+
+// Tapper works out a tempo from taps on a key.
+type Tapper struct {
+	taps []time.Time
+}
+
+// Tap records a tap and returns the tempo of the taps so far. It reports false until there are two taps.
+func (t *Tapper) Tap(at time.Time) (int, bool) {
+	if n := len(t.taps); n > 0 && at.Sub(t.taps[n-1]) > TapTimeout {
+		t.taps = t.taps[:0]
+	}
+	t.taps = append(t.taps, at)
+	if len(t.taps) > maxTaps {
+		t.taps = t.taps[len(t.taps)-maxTaps:]
+	}
+	if len(t.taps) < 2 {
+		return 0, false
+	}
+
+	avg := t.taps[len(t.taps)-1].Sub(t.taps[0]) / time.Duration(len(t.taps)-1)
+	if avg <= 0 {
+		return 0, false
+	}
+	bpm := int(math.Round(float64(time.Minute) / float64(avg)))
+	if bpm < MinBPM {
+		bpm = MinBPM
+	}
+	if bpm > MaxBPM {
+		bpm = MaxBPM
+	}
+	return bpm, true
+}
+
+// Last returns the time of the latest tap.
+func (t *Tapper) Last() time.Time {
+	if len(t.taps) == 0 {
+		return time.Time{}
+	}
+	return t.taps[len(t.taps)-1]
+}
@@ -0,0 +1,243 @@
+// Package tempo keeps a jam's musical time: the tempo, time signature and beat clock shared by the players.
+package tempo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBPM = 120
+	MinBPM     = 20
+	MaxBPM     = 300
+	// Most beats allowed in a bar.
+	MaxBeats = 16
+	// Resolution of a beat in bar:beat:tick positions.
+	TicksPerBeat = 480
+)
+
+type (
+	// Signature is a time signature, ex: 3/4 is three quarter note beats per bar.
+	Signature struct {
+		Beats int
+		Unit  int
+	}
+
+	// Clock maps wall time to bars and beats.
+	Clock struct {
+		// Beats per minute, counted in the signature's unit.
+		BPM       int
+		Signature Signature
+		// Time of the first beat of bar 1.
+		Origin time.Time
+	}
+
+	// Position is a place in the music. Bars and beats count from 1.
+	Position struct {
+		Bar  int
+		Beat int
+		// How far we are through the beat, from 0 up to 1.
+		Fraction float64
+	}
+)
+
+// CommonTime is 4/4.
+var CommonTime = Signature{Beats: 4, Unit: 4}
+
+// ParseSignature parses a time signature like "6/8".
+func ParseSignature(s string) (Signature, error) {
+	beats, unit, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Signature{}, fmt.Errorf("time signature %q should look like 3/4", s)
+	}
+	b, err := strconv.Atoi(beats)
+	if err != nil {
+		return Signature{}, fmt.Errorf("time signature %q: bad beat count", s)
+	}
+	u, err := strconv.Atoi(unit)
+	if err != nil {
+		return Signature{}, fmt.Errorf("time signature %q: bad beat unit", s)
+	}
+	sig := Signature{Beats: b, Unit: u}
+	return sig, sig.Validate()
+}
+
+// Validate checks the signature has between 1 and MaxBeats beats of a whole, half, quarter, eighth or sixteenth note.
+func (s Signature) Validate() error {
+	if s.Beats < 1 || s.Beats > MaxBeats {
+		return fmt.Errorf("time signature %s: beats must be between 1 and %d", s, MaxBeats)
+	}
+	switch s.Unit {
+	case 1, 2, 4, 8, 16:
+		return nil
+	}
+	return fmt.Errorf("time signature %s: beat unit must be 1, 2, 4, 8 or 16", s)
+}
+
+func (s Signature) String() string {
+	return fmt.Sprintf("%d/%d", s.Beats, s.Unit)
+}
+
+// ValidateBPM checks the tempo is between MinBPM and MaxBPM.
+func ValidateBPM(bpm int) error {
+	if bpm < MinBPM || bpm > MaxBPM {
+		return fmt.Errorf("BPM must be between %d and %d", MinBPM, MaxBPM)
+	}
+	return nil
+}
+
+// NewClock starts a clock with bar 1 at the origin.
+func NewClock(bpm int, sig Signature, origin time.Time) Clock {
+	return Clock{BPM: bpm, Signature: sig, Origin: origin}
+}
+
+// BeatLength is the duration of one beat.
+func (c Clock) BeatLength() time.Duration {
+	return time.Minute / time.Duration(c.BPM)
+}
+
+// BarLength is the duration of one bar.
+func (c Clock) BarLength() time.Duration {
+	return c.BeatLength() * time.Duration(c.Signature.Beats)
+}
+
+// beats returns the number of beats, including the fraction of the current one, since the origin.
+func (c Clock) beats(t time.Time) float64 {
+	return float64(t.Sub(c.Origin)) / float64(c.BeatLength())
+}
+
+// At returns the position at time t. Times before the origin count back from bar 0.
+func (c Clock) At(t time.Time) Position {
+	return c.Signature.Position(c.beats(t))
+}
+
+// Position converts a number of beats since the start of bar 1 into a position.
+func (s Signature) Position(beats float64) Position {
+	whole := math.Floor(beats)
+	n := int(whole)
+	bar := floorDiv(n, s.Beats)
+	return Position{
+		Bar:      bar + 1,
+		Beat:     n - bar*s.Beats + 1,
+		Fraction: beats - whole,
+	}
+}
+
+// BarStart returns the number of beats from the start of bar 1 to the start of the given bar.
+func (s Signature) BarStart(bar int) float64 {
+	return float64((bar - 1) * s.Beats)
+}
+
+// Offset converts a position into the number of beats since the start of bar 1.
+func (s Signature) Offset(p Position) float64 {
+	return s.BarStart(p.Bar) + float64(p.Beat-1) + p.Fraction
+}
+
+// Notes counts the notes of a value, ex: 16 for sixteenths, from the start of bar 1 to a position.
+func (s Signature) Notes(p Position, value int) float64 {
+	return s.Offset(p) * float64(value) / float64(s.Unit)
+}
+
+// Tick is the position within the beat, from 0 up to TicksPerBeat.
+func (p Position) Tick() int {
+	return int(p.Fraction * TicksPerBeat)
+}
+
+// String formats the position as bar:beat:tick, ex: "5:3:240".
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d:%03d", p.Bar, p.Beat, p.Tick())
+}
+
+// NextBeat returns the time and position of the first beat after t.
+func (c Clock) NextBeat(t time.Time) (time.Time, Position) {
+	n := math.Floor(c.beats(t)) + 1
+	at := c.Origin.Add(time.Duration(n * float64(c.BeatLength())))
+	return at, c.At(at)
+}
+
+// NextBar returns the time of the first downbeat after t.
+func (c Clock) NextBar(t time.Time) time.Time {
+	bars := math.Floor(float64(t.Sub(c.Origin))/float64(c.BarLength())) + 1
+	return c.Origin.Add(time.Duration(bars * float64(c.BarLength())))
+}
+
+// NoteLength is the duration of a note value at this tempo, ex: 16 for a sixteenth note.
+func (c Clock) NoteLength(value int) time.Duration {
+	return c.BeatLength() * time.Duration(c.Signature.Unit) / time.Duration(value)
+}
+
+// NextNote returns the first time after t on the grid of the given note value, ex: the next sixteenth.
+func (c Clock) NextNote(t time.Time, value int) time.Time {
+	length := c.NoteLength(value)
+	n := math.Floor(float64(t.Sub(c.Origin))/float64(length)) + 1
+	return c.Origin.Add(time.Duration(n * float64(length)))
+}
+
+// Note values by name. A "t" makes a triplet, three in the time of two.
+var noteValues = []struct {
+	name  string
+	value int
+}{
+	{"1/4", 4}, {"1/8", 8}, {"1/16", 16}, {"1/32", 32},
+	{"1/4t", 6}, {"1/8t", 12}, {"1/16t", 24},
+}
+
+// ParseNoteValue reads a note value like "1/16" or "1/8t" and returns it as the notes in a whole note,
+// ex: 16 or 12.
+func ParseNoteValue(s string) (int, error) {
+	for _, v := range noteValues {
+		if v.name == s {
+			return v.value, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown note value %q, ex: 1/16 or 1/8t", s)
+}
+
+// NoteValueName names a note value, ex: "1/8t" for 12.
+func NoteValueName(value int) string {
+	for _, v := range noteValues {
+		if v.value == value {
+			return v.name
+		}
+	}
+	return fmt.Sprintf("1/%d", value)
+}
+
+// BarElapsed is how far into the current bar we are at time t.
+func (c Clock) BarElapsed(t time.Time) time.Duration {
+	bar := c.BarLength()
+	elapsed := t.Sub(c.Origin) % bar
+	if elapsed < 0 {
+		elapsed += bar
+	}
+	return elapsed
+}
+
+// Synced rebuilds a clock another player sent us. elapsed is how far into their bar they were when they
+// sent it and delay is how long the message took to reach us.
+func Synced(bpm int, sig Signature, elapsed, delay time.Duration, now time.Time) Clock {
+	return NewClock(bpm, sig, now.Add(-delay-elapsed))
+}
+
+// Delay estimates how long a message takes to travel from another player, through the server, to us
+// from the round trip times both of us measured to the server.
+func Delay(senderRTT, localRTT time.Duration) time.Duration {
+	if senderRTT < 0 {
+		senderRTT = 0
+	}
+	if localRTT < 0 {
+		localRTT = 0
+	}
+	return (senderRTT + localRTT) / 2
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,142 @@
+package tempo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/stretchr/testify/require"
+)
+
+var origin = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    tempo.Signature
+		wantErr bool
+	}{
+		{input: "4/4", want: tempo.CommonTime},
+		{input: " 6/8 ", want: tempo.Signature{Beats: 6, Unit: 8}},
+		{input: "7/16", want: tempo.Signature{Beats: 7, Unit: 16}},
+		{input: "4", wantErr: true},
+		{input: "0/4", wantErr: true},
+		{input: "17/4", wantErr: true},
+		{input: "3/5", wantErr: true},
+		{input: "x/4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tempo.ParseSignature(tt.input)
+		if tt.wantErr {
+			require.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		require.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestClockAt(t *testing.T) {
+	// 120 BPM is a beat every 500ms.
+	clock := tempo.NewClock(120, tempo.Signature{Beats: 3, Unit: 4}, origin)
+
+	tests := []struct {
+		offset time.Duration
+		want   tempo.Position
+	}{
+		{offset: 0, want: tempo.Position{Bar: 1, Beat: 1}},
+		{offset: 250 * time.Millisecond, want: tempo.Position{Bar: 1, Beat: 1, Fraction: 0.5}},
+		{offset: 1000 * time.Millisecond, want: tempo.Position{Bar: 1, Beat: 3}},
+		{offset: 1500 * time.Millisecond, want: tempo.Position{Bar: 2, Beat: 1}},
+		{offset: -500 * time.Millisecond, want: tempo.Position{Bar: 0, Beat: 3}},
+		{offset: -1500 * time.Millisecond, want: tempo.Position{Bar: 0, Beat: 1}},
+	}
+
+	for _, tt := range tests {
+		got := clock.At(origin.Add(tt.offset))
+		require.Equal(t, tt.want.Bar, got.Bar, tt.offset)
+		require.Equal(t, tt.want.Beat, got.Beat, tt.offset)
+		require.InDelta(t, tt.want.Fraction, got.Fraction, 1e-9, tt.offset)
+	}
+}
+
+func TestClockNextBeat(t *testing.T) {
+	clock := tempo.NewClock(120, tempo.CommonTime, origin)
+
+	at, pos := clock.NextBeat(origin.Add(1600 * time.Millisecond))
+	require.Equal(t, origin.Add(2*time.Second), at)
+	require.Equal(t, tempo.Position{Bar: 2, Beat: 1}, pos)
+
+	// Exactly on a beat moves on to the next one.
+	at, pos = clock.NextBeat(origin.Add(2 * time.Second))
+	require.Equal(t, origin.Add(2500*time.Millisecond), at)
+	require.Equal(t, tempo.Position{Bar: 2, Beat: 2}, pos)
+
+	require.Equal(t, origin.Add(4*time.Second), clock.NextBar(origin.Add(2100*time.Millisecond)))
+
+	// Sixteenths are 125ms at 120 BPM in 4/4, eighths in 6/8 are 500ms.
+	require.Equal(t, origin.Add(250*time.Millisecond), clock.NextNote(origin.Add(130*time.Millisecond), 16))
+	compound := tempo.NewClock(120, tempo.Signature{Beats: 6, Unit: 8}, origin)
+	require.Equal(t, 500*time.Millisecond, compound.NoteLength(8))
+}
+
+func TestSignatureOffset(t *testing.T) {
+	sig := tempo.Signature{Beats: 3, Unit: 4}
+	pos := tempo.Position{Bar: 3, Beat: 2, Fraction: 0.5}
+	require.InDelta(t, 7.5, sig.Offset(pos), 1e-9)
+	require.Equal(t, pos, sig.Position(sig.Offset(pos)))
+}
+
+func TestSynced(t *testing.T) {
+	sender := tempo.NewClock(90, tempo.CommonTime, origin)
+	sentAt := origin.Add(7 * time.Second)
+	elapsed := sender.BarElapsed(sentAt)
+
+	delay := tempo.Delay(60*time.Millisecond, 40*time.Millisecond)
+	require.Equal(t, 50*time.Millisecond, delay)
+
+	receivedAt := sentAt.Add(delay)
+	got := tempo.Synced(90, tempo.CommonTime, elapsed, delay, receivedAt)
+
+	// Both clocks put the same beats at the same instants.
+	later := origin.Add(30 * time.Second)
+	wantAt, wantPos := sender.NextBeat(later)
+	gotAt, gotPos := got.NextBeat(later)
+	require.Equal(t, wantAt, gotAt)
+	require.Equal(t, wantPos.Beat, gotPos.Beat)
+}
+
+func TestTapper(t *testing.T) {
+	var tapper tempo.Tapper
+
+	_, ok := tapper.Tap(origin)
+	require.False(t, ok, "one tap has no tempo")
+
+	var bpm int
+	for i := 1; i < 4; i++ {
+		bpm, ok = tapper.Tap(origin.Add(time.Duration(i) * 600 * time.Millisecond))
+	}
+	require.True(t, ok)
+	require.Equal(t, 100, bpm)
+
+	// A long pause starts over.
+	restart := origin.Add(10 * time.Second)
+	_, ok = tapper.Tap(restart)
+	require.False(t, ok)
+	bpm, ok = tapper.Tap(restart.Add(250 * time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, 240, bpm)
+	require.Equal(t, restart.Add(250*time.Millisecond), tapper.Last())
+}
+
+func TestParseNoteValue(t *testing.T) {
+	for name, want := range map[string]int{"1/4": 4, "1/16": 16, "1/8t": 12, "1/16t": 24} {
+		got, err := tempo.ParseNoteValue(name)
+		require.NoError(t, err, name)
+		require.Equal(t, want, got, name)
+		require.Equal(t, name, tempo.NoteValueName(got))
+	}
+	_, err := tempo.ParseNoteValue("1/3")
+	require.Error(t, err)
+}
@@ -0,0 +1,106 @@
+// Package theory names what the jam is playing: the chord that's sounding and the key it's in.
+package theory
+
+// PitchNames names pitch classes, from C.
+var PitchNames = [12]string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+
+// Chord shapes as semitones above the root. Earlier shapes win when notes could be named
+// more than one way.
+var chordShapes = []struct {
+	suffix    string
+	intervals []int
+}{
+	{"", []int{0, 4, 7}},
+	{"m", []int{0, 3, 7}},
+	{"7", []int{0, 4, 7, 10}},
+	{"maj7", []int{0, 4, 7, 11}},
+	{"m7", []int{0, 3, 7, 10}},
+	{"6", []int{0, 4, 7, 9}},
+	{"m6", []int{0, 3, 7, 9}},
+	{"dim", []int{0, 3, 6}},
+	{"dim7", []int{0, 3, 6, 9}},
+	{"m7b5", []int{0, 3, 6, 10}},
+	{"aug", []int{0, 4, 8}},
+	{"sus4", []int{0, 5, 7}},
+	{"sus2", []int{0, 2, 7}},
+	{"7sus4", []int{0, 5, 7, 10}},
+	{"mMaj7", []int{0, 3, 7, 11}},
+	{"add9", []int{0, 2, 4, 7}},
+	{"madd9", []int{0, 2, 3, 7}},
+	{"9", []int{0, 2, 4, 7, 10}},
+	{"maj9", []int{0, 2, 4, 7, 11}},
+	{"m9", []int{0, 2, 3, 7, 10}},
+	{"5", []int{0, 7}},
+}
+
+// PitchClass is a note's place in the octave, 0 for C up to 11 for B.
+func PitchClass(note int) int {
+	return (note%12 + 12) % 12
+}
+
+// NameChord names the chord made by a set of MIDI notes, ex: "Cmaj7/E" for E3 G3 B3 C4.
+// The lowest note is the bass, and is written after a slash when it isn't the root.
+// It reports false for fewer than two pitch classes or shapes it doesn't know.
+func NameChord(notes []int) (string, bool) {
+	if len(notes) == 0 {
+		return "", false
+	}
+	bass := notes[0]
+	var set uint16
+	for _, n := range notes {
+		if n < bass {
+			bass = n
+		}
+		set |= 1 << PitchClass(n)
+	}
+	bassPC := PitchClass(bass)
+
+	// Roots to try, the bass first.
+	roots := make([]int, 0, 12)
+	for i := 0; i < 12; i++ {
+		if pc := (bassPC + i) % 12; set&(1<<pc) != 0 {
+			roots = append(roots, pc)
+		}
+	}
+	if len(roots) < 2 {
+		return "", false
+	}
+
+	// Try every shape in full, then with the fifth left out, as it often is in bigger chords.
+	for _, noFifth := range []bool{false, true} {
+		// A chord on the bass note reads better than an inversion.
+		for _, s := range chordShapes {
+			if matches(set, bassPC, s.intervals, noFifth) {
+				return PitchNames[bassPC] + s.suffix, true
+			}
+		}
+		for _, s := range chordShapes {
+			for _, root := range roots[1:] {
+				if matches(set, root, s.intervals, noFifth) {
+					return PitchNames[root] + s.suffix + "/" + PitchNames[bassPC], true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// Matches reports whether a chord shape on root makes exactly the pitch class set.
+func matches(set uint16, root int, intervals []int, noFifth bool) bool {
+	if noFifth && len(intervals) < 4 {
+		return false
+	}
+	var shape uint16
+	hasFifth := false
+	for _, i := range intervals {
+		if noFifth && i == 7 {
+			hasFifth = true
+			continue
+		}
+		shape |= 1 << ((root + i) % 12)
+	}
+	if noFifth && !hasFifth {
+		return false
+	}
+	return shape == set
+}
@@ -0,0 +1,80 @@
+package theory
+
+import "math"
+
+// Key is a major or minor key.
+type Key struct {
+	// Pitch class of the tonic, 0 for C up to 11 for B.
+	Tonic int
+	Minor bool
+}
+
+// Krumhansl-Kessler key profiles: how well each degree of the scale fits the key, from the tonic up.
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// EstimateKey guesses the key from how much each pitch class was played, by correlating it with the
+// profile of every major and minor key. It returns the best key and its correlation, from -1 to 1.
+// It reports false when fewer than three pitch classes were played, which could be in any key.
+func EstimateKey(weights [12]float64) (Key, float64, bool) {
+	played := 0
+	for _, w := range weights {
+		if w > 0 {
+			played++
+		}
+	}
+	if played < 3 {
+		return Key{}, 0, false
+	}
+
+	best, bestScore := Key{}, math.Inf(-1)
+	for tonic := 0; tonic < 12; tonic++ {
+		for _, minor := range []bool{false, true} {
+			profile := majorProfile
+			if minor {
+				profile = minorProfile
+			}
+			var rotated [12]float64
+			for i := range rotated {
+				rotated[(tonic+i)%12] = profile[i]
+			}
+			if score := correlate(weights, rotated); score > bestScore {
+				best, bestScore = Key{Tonic: tonic, Minor: minor}, score
+			}
+		}
+	}
+	return best, bestScore, true
+}
+
+// String names the key, ex: "A minor".
+func (k Key) String() string {
+	if k.Minor {
+		return PitchNames[k.Tonic] + " minor"
+	}
+	return PitchNames[k.Tonic] + " major"
+}
+
+// Correlate is the Pearson correlation of two series.
+func correlate(a, b [12]float64) float64 {
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= 12
+	meanB /= 12
+
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
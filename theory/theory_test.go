@@ -0,0 +1,216 @@
+package theory_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/theory"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+	"github.com/stretchr/testify/require"
+)
+
+// MIDI notes of the fourth octave.
+const (
+	C4 = 60 + iota
+	Cs4
+	D4
+	Eb4
+	E4
+	F4
+	Fs4
+	G4
+	Ab4
+	A4
+	Bb4
+	B4
+)
+
+func TestNameChord(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []int
+		want  string
+	}{
+		// Triads in root position.
+		{name: "major", notes: []int{C4, E4, G4}, want: "C"},
+		{name: "minor", notes: []int{A4 - 12, C4, E4}, want: "Am"},
+		{name: "diminished", notes: []int{B4 - 12, D4, F4}, want: "Bdim"},
+		{name: "augmented", notes: []int{C4, E4, Ab4}, want: "Caug"},
+		{name: "sus4", notes: []int{D4, G4, A4}, want: "Dsus4"},
+		{name: "sus2", notes: []int{D4, E4, A4}, want: "Dsus2"},
+		{name: "power chord", notes: []int{E4 - 24, B4 - 24, E4 - 12}, want: "E5"},
+
+		// Sevenths and extensions.
+		{name: "dominant seventh", notes: []int{G4 - 12, B4 - 12, D4, F4}, want: "G7"},
+		{name: "major seventh", notes: []int{C4, E4, G4, B4}, want: "Cmaj7"},
+		{name: "minor seventh", notes: []int{D4, F4, A4, C4 + 12}, want: "Dm7"},
+		{name: "half diminished", notes: []int{B4 - 12, D4, F4, A4}, want: "Bm7b5"},
+		{name: "diminished seventh", notes: []int{B4 - 12, D4, F4, Ab4}, want: "Bdim7"},
+		{name: "minor major seventh", notes: []int{C4, Eb4, G4, B4}, want: "CmMaj7"},
+		{name: "sixth", notes: []int{F4, A4, C4 + 12, D4 + 12}, want: "F6"},
+		{name: "minor sixth", notes: []int{A4 - 12, C4, E4, Fs4}, want: "Am6"},
+		{name: "seventh sus4", notes: []int{G4 - 12, C4, D4, F4}, want: "G7sus4"},
+		{name: "add9", notes: []int{C4, E4, G4, D4 + 12}, want: "Cadd9"},
+		{name: "ninth", notes: []int{C4, E4, G4, Bb4, D4 + 12}, want: "C9"},
+		{name: "major ninth", notes: []int{F4, A4, C4 + 12, E4 + 12, G4 + 12}, want: "Fmaj9"},
+		{name: "minor ninth", notes: []int{A4 - 12, C4, E4, G4, B4}, want: "Am9"},
+
+		// Inversions are written over their bass note.
+		{name: "first inversion", notes: []int{E4, G4, C4 + 12}, want: "C/E"},
+		{name: "second inversion", notes: []int{G4 - 12, C4, E4}, want: "C/G"},
+		{name: "minor first inversion", notes: []int{C4, E4, A4}, want: "Am/C"},
+		{name: "major seventh over the third", notes: []int{E4 - 12, G4 - 12, B4 - 12, C4}, want: "Cmaj7/E"},
+		{name: "seventh in the bass", notes: []int{F4 - 12, G4 - 12, B4 - 12, D4}, want: "G7/F"},
+
+		// Voicings spread out or doubled across octaves.
+		{name: "open voicing", notes: []int{C4 - 12, G4 - 12, E4, C4 + 12}, want: "C"},
+		{name: "doubled notes", notes: []int{D4, A4, D4 + 12, Fs4 + 12, A4 + 12}, want: "D"},
+		{name: "unsorted", notes: []int{G4, C4, E4}, want: "C"},
+
+		// Jazz voicings often leave out the fifth.
+		{name: "shell seventh", notes: []int{G4 - 12, F4, B4}, want: "G7"},
+		{name: "shell minor seventh", notes: []int{D4, C4 + 12, F4 + 12}, want: "Dm7"},
+
+		// Shapes that read more than one way prefer the bass as the root.
+		{name: "sixth over its root", notes: []int{C4, E4, G4, A4}, want: "C6"},
+		{name: "minor seventh over its root", notes: []int{A4 - 12, C4, E4, G4}, want: "Am7"},
+		{name: "augmented is symmetric", notes: []int{E4, Ab4, C4 + 12}, want: "Eaug"},
+
+		// Sharps and flats.
+		{name: "sharp root", notes: []int{Fs4, Bb4, Cs4 + 12}, want: "F#"},
+		{name: "flat root", notes: []int{Bb4 - 12, D4, F4}, want: "Bb"},
+		{name: "flat minor", notes: []int{Eb4, Fs4, Bb4}, want: "Ebm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := theory.NameChord(tt.notes)
+			require.True(t, ok, "%v", tt.notes)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNameChordUnknown(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []int
+	}{
+		{name: "nothing"},
+		{name: "one note", notes: []int{C4}},
+		{name: "octave", notes: []int{C4, C4 + 12}},
+		{name: "cluster", notes: []int{C4, Cs4, D4}},
+		{name: "chromatic", notes: []int{C4, Cs4, D4, Eb4, E4, F4}},
+		{name: "third alone", notes: []int{C4, E4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := theory.NameChord(tt.notes)
+			require.False(t, ok, got)
+		})
+	}
+}
+
+// Counts the notes of a scale, with the tonic, third and fifth played more.
+func weigh(tonic int, intervals []int) [12]float64 {
+	var w [12]float64
+	for _, i := range intervals {
+		w[(tonic+i)%12]++
+	}
+	for _, i := range []int{0, intervals[2], intervals[4]} {
+		w[(tonic+i)%12]++
+	}
+	return w
+}
+
+var (
+	majorScale         = []int{0, 2, 4, 5, 7, 9, 11}
+	naturalMinorScale  = []int{0, 2, 3, 5, 7, 8, 10}
+	harmonicMinorScale = []int{0, 2, 3, 5, 7, 8, 11}
+)
+
+func TestEstimateKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights [12]float64
+		want    string
+	}{
+		{name: "C major scale", weights: weigh(0, majorScale), want: "C major"},
+		{name: "G major scale", weights: weigh(7, majorScale), want: "G major"},
+		{name: "Eb major scale", weights: weigh(3, majorScale), want: "Eb major"},
+		{name: "A natural minor", weights: weigh(9, naturalMinorScale), want: "A minor"},
+		{name: "E harmonic minor", weights: weigh(4, harmonicMinorScale), want: "E minor"},
+		{name: "F# minor", weights: weigh(6, naturalMinorScale), want: "F# minor"},
+		{
+			// I IV V I in D: D F# A, G B D, A C# E, D F# A.
+			name:    "progression in D",
+			weights: [12]float64{2: 4, 4: 1, 6: 2, 7: 1, 9: 3, 11: 1, 1: 1},
+			want:    "D major",
+		},
+		{
+			// i iv V i in C minor: C Eb G, F Ab C, G B D, C Eb G.
+			name:    "progression in C minor",
+			weights: [12]float64{0: 4, 3: 2, 7: 3, 5: 1, 8: 1, 11: 1, 2: 1},
+			want:    "C minor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, score, ok := theory.EstimateKey(tt.weights)
+			require.True(t, ok)
+			require.Equal(t, tt.want, got.String())
+			require.Greater(t, score, 0.5)
+		})
+	}
+
+	_, _, ok := theory.EstimateKey([12]float64{0: 3, 7: 2})
+	require.False(t, ok, "two pitch classes could be in any key")
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2022, 12, 1, 20, 0, 0, 0, time.UTC)
+	alice, bob := uuid.New(), uuid.New()
+	on := func(n int) wsmsg.MIDIMsg { return wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: n, Velocity: 100} }
+
+	tr := theory.NewTracker()
+	tr.Add(alice, on(C4), start)
+	tr.Add(bob, on(E4-12), start.Add(100*time.Millisecond))
+	tr.Add(alice, on(G4), start.Add(200*time.Millisecond))
+	tr.Add(bob, on(B4), start.Add(300*time.Millisecond))
+	// Drums aren't part of the harmony.
+	tr.Add(bob, wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: 38, Velocity: 100, Channel: midi.PercussionChannel}, start)
+
+	now := start.Add(time.Second)
+	require.Equal(t, []int{E4 - 12, C4, G4, B4}, tr.Sounding(now))
+	chord, ok := tr.Chord(now)
+	require.True(t, ok)
+	require.Equal(t, "Cmaj7/E", chord)
+
+	// A NOTE_OFF, or a NOTE_ON without velocity, stops a note.
+	tr.Add(bob, wsmsg.MIDIMsg{State: wsmsg.NOTE_OFF, Number: E4 - 12}, now)
+	tr.Add(bob, wsmsg.MIDIMsg{State: wsmsg.NOTE_ON, Number: B4}, now)
+	chord, _ = tr.Chord(now)
+	require.Equal(t, "C5", chord)
+
+	// Notes die away after the sustain.
+	require.Empty(t, tr.Sounding(start.Add(3*time.Second)))
+
+	// The key follows the notes played within the window.
+	for i, n := range []int{A4, B4, C4, D4, E4, F4, Ab4, A4, C4, E4, A4} {
+		tr.Add(alice, on(n), start.Add(time.Duration(i)*time.Second))
+	}
+	key, ok := tr.Key(start.Add(11 * time.Second))
+	require.True(t, ok)
+	require.Equal(t, "A minor", key.String())
+
+	later := start.Add(12*time.Second + theory.DefaultWindow)
+	_, ok = tr.Key(later)
+	require.False(t, ok)
+
+	tr.Add(alice, on(C4), later)
+	tr.Reset()
+	require.Empty(t, tr.Sounding(later))
+}
@@ -0,0 +1,118 @@
+package theory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/wsmsg"
+)
+
+const (
+	// DefaultSustain is how long a note sounds when no NOTE_OFF comes, the same as the jam's synth plays it.
+	DefaultSustain = 2 * time.Second
+	// DefaultWindow is how far back the key is estimated from.
+	DefaultWindow = 30 * time.Second
+)
+
+type (
+	// Tracker follows the notes every player in the jam sends.
+	Tracker struct {
+		Sustain time.Duration
+		Window  time.Duration
+		// When each player's notes started sounding.
+		sounding map[voice]time.Time
+		// Notes played within the window, oldest first.
+		history []played
+	}
+
+	voice struct {
+		user uuid.UUID
+		note int
+	}
+
+	played struct {
+		note int
+		at   time.Time
+	}
+)
+
+// NewTracker returns a tracker with the default sustain and window.
+func NewTracker() *Tracker {
+	return &Tracker{
+		Sustain:  DefaultSustain,
+		Window:   DefaultWindow,
+		sounding: make(map[voice]time.Time),
+	}
+}
+
+// Add follows a MIDI message a player sent at time at. Drums aren't pitched, so they're ignored.
+func (t *Tracker) Add(user uuid.UUID, msg wsmsg.MIDIMsg, at time.Time) {
+	if msg.Channel == midi.PercussionChannel {
+		return
+	}
+	v := voice{user: user, note: msg.Number}
+	// A NOTE_ON with no velocity is a NOTE_OFF.
+	if msg.State == wsmsg.NOTE_OFF || msg.Velocity == 0 {
+		delete(t.sounding, v)
+		return
+	}
+	t.sounding[v] = at
+	t.history = append(t.history, played{note: msg.Number, at: at})
+	t.forget(at)
+}
+
+// Sounding returns the notes sounding at time now, low to high.
+func (t *Tracker) Sounding(now time.Time) []int {
+	notes := make(map[int]bool)
+	for v, at := range t.sounding {
+		if now.Sub(at) >= t.Sustain {
+			delete(t.sounding, v)
+			continue
+		}
+		notes[v.note] = true
+	}
+	return sortedNotes(notes)
+}
+
+// Chord names the chord sounding at time now.
+func (t *Tracker) Chord(now time.Time) (string, bool) {
+	return NameChord(t.Sounding(now))
+}
+
+// Key estimates the key from the notes played within the window before now.
+func (t *Tracker) Key(now time.Time) (Key, bool) {
+	t.forget(now)
+	var weights [12]float64
+	for _, p := range t.history {
+		weights[PitchClass(p.note)]++
+	}
+	key, _, ok := EstimateKey(weights)
+	return key, ok
+}
+
+// Reset forgets every note.
+func (t *Tracker) Reset() {
+	t.sounding = make(map[voice]time.Time)
+	t.history = nil
+}
+
+// Forget drops notes played before the window.
+func (t *Tracker) forget(now time.Time) {
+	i := 0
+	for i < len(t.history) && now.Sub(t.history[i].at) > t.Window {
+		i++
+	}
+	t.history = t.history[i:]
+}
+
+// SortedNotes returns the notes low to high without repeats.
+func sortedNotes(notes map[int]bool) []int {
+	sorted := make([]int, 0, len(notes))
+	for n := range notes {
+		sorted = append(sorted, n)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
@@ -0,0 +1,113 @@
+// Package transport is a jam's shared play/stop control and song position.
+//
+// Song bars line up with the bars of the jam's tempo clock, so playback always starts on a downbeat
+// and everything that follows the transport, like loops and sequences, starts together.
+package transport
+
+import (
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/tempo"
+)
+
+type Transport struct {
+	playing bool
+	// Song bar playback starts from, or the bar it stopped in.
+	fromBar int
+	// Position shown until playback starts.
+	held tempo.Position
+	// Downbeat playback starts on.
+	start time.Time
+	// Song bar minus the tempo clock's bar while playing.
+	offset int
+}
+
+// New returns a stopped transport at the top of the song.
+func New() Transport {
+	return Transport{fromBar: 1, held: tempo.Position{Bar: 1, Beat: 1}}
+}
+
+// Playing reports whether playback has been started, even if it's waiting for its downbeat.
+func (t Transport) Playing() bool {
+	return t.playing
+}
+
+// Started reports whether the song position is moving at time now.
+func (t Transport) Started(now time.Time) bool {
+	return t.playing && !now.Before(t.start)
+}
+
+// Start returns the downbeat playback starts on.
+func (t Transport) Start() time.Time {
+	return t.start
+}
+
+// Bar returns the song bar playback starts from when stopped.
+func (t Transport) Bar() int {
+	return t.fromBar
+}
+
+// Position returns the song position at time now.
+func (t Transport) Position(c tempo.Clock, now time.Time) tempo.Position {
+	if !t.Started(now) {
+		return t.held
+	}
+	pos := c.At(now)
+	pos.Bar += t.offset
+	return pos
+}
+
+// Play starts playback on the first downbeat after now and returns when that is.
+func (t *Transport) Play(c tempo.Clock, now time.Time) time.Time {
+	t.PlayAt(c, t.fromBar, c.NextBar(now))
+	return t.start
+}
+
+// PlayAt plays from the top of the song bar at start, which should be one of the clock's downbeats.
+// A start in the past joins playback that is already going.
+func (t *Transport) PlayAt(c tempo.Clock, bar int, start time.Time) {
+	t.playing = true
+	t.fromBar = bar
+	t.held = tempo.Position{Bar: bar, Beat: 1}
+	t.start = start
+	t.offset = bar - barAt(c, start)
+}
+
+// Stop stops playback at time now. Playing again resumes from the top of the bar it stopped in.
+func (t *Transport) Stop(c tempo.Clock, now time.Time) {
+	if !t.playing {
+		return
+	}
+	t.held = t.Position(c, now)
+	t.fromBar = t.held.Bar
+	t.playing = false
+}
+
+// Locate moves to the top of a song bar. While playing, the jump happens on the next downbeat.
+func (t *Transport) Locate(c tempo.Clock, bar int, now time.Time) {
+	if t.playing {
+		t.PlayAt(c, bar, c.NextBar(now))
+		return
+	}
+	t.fromBar = bar
+	t.held = tempo.Position{Bar: bar, Beat: 1}
+}
+
+// Retime keeps the song bar going when the tempo clock is replaced while playing.
+// The new clock decides where in the bar we are.
+func (t *Transport) Retime(old, c tempo.Clock, now time.Time) {
+	if !t.Started(now) {
+		if t.playing {
+			// Still waiting for the downbeat, wait for the new clock's instead.
+			t.PlayAt(c, t.fromBar, c.NextBar(now))
+		}
+		return
+	}
+	bar := t.Position(old, now).Bar
+	t.offset = bar - c.At(now).Bar
+}
+
+// BarAt is the clock's bar at a downbeat, without floating point error putting us at the end of the previous bar.
+func barAt(c tempo.Clock, downbeat time.Time) int {
+	return c.At(downbeat.Add(c.BeatLength() / 2)).Bar
+}
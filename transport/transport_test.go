@@ -0,0 +1,106 @@
+package transport_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rapidmidiex/rmxtui/clock"
+	"github.com/rapidmidiex/rmxtui/tempo"
+	"github.com/rapidmidiex/rmxtui/transport"
+	"github.com/stretchr/testify/require"
+)
+
+var origin = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// 120 BPM in 4/4 is a beat every 500ms and a bar every 2s.
+var metronome = tempo.NewClock(120, tempo.CommonTime, origin)
+
+func TestPlayStartsOnDownbeat(t *testing.T) {
+	c := clock.NewFake(origin.Add(2700 * time.Millisecond))
+	tr := transport.New()
+
+	start := tr.Play(metronome, c.Now())
+	require.Equal(t, origin.Add(4*time.Second), start)
+	require.True(t, tr.Playing())
+	require.False(t, tr.Started(c.Now()))
+	require.Equal(t, "1:1:000", tr.Position(metronome, c.Now()).String())
+
+	c.Set(start)
+	require.True(t, tr.Started(c.Now()))
+	require.Equal(t, "1:1:000", tr.Position(metronome, c.Now()).String())
+
+	c.Advance(2750 * time.Millisecond)
+	require.Equal(t, "2:2:240", tr.Position(metronome, c.Now()).String())
+}
+
+func TestStopAndResume(t *testing.T) {
+	c := clock.NewFake(origin)
+	tr := transport.New()
+
+	c.Set(tr.Play(metronome, c.Now()))
+	c.Advance(5 * time.Second)
+	tr.Stop(metronome, c.Now())
+	require.False(t, tr.Playing())
+	require.Equal(t, "3:3:000", tr.Position(metronome, c.Now()).String())
+
+	// Time passes, the position doesn't.
+	c.Advance(time.Minute)
+	require.Equal(t, "3:3:000", tr.Position(metronome, c.Now()).String())
+
+	// Resumes from the top of the bar it stopped in.
+	start := tr.Play(metronome, c.Now())
+	c.Set(start.Add(500 * time.Millisecond))
+	require.Equal(t, "3:2:000", tr.Position(metronome, c.Now()).String())
+}
+
+func TestLocate(t *testing.T) {
+	c := clock.NewFake(origin)
+	tr := transport.New()
+
+	tr.Locate(metronome, 17, c.Now())
+	require.Equal(t, 17, tr.Bar())
+	require.Equal(t, "17:1:000", tr.Position(metronome, c.Now()).String())
+
+	c.Set(tr.Play(metronome, c.Now()))
+	c.Advance(3 * time.Second)
+	require.Equal(t, "18:3:000", tr.Position(metronome, c.Now()).String())
+
+	// While playing, the jump waits for the next downbeat.
+	tr.Locate(metronome, 5, c.Now())
+	require.True(t, tr.Playing())
+	require.Equal(t, "5:1:000", tr.Position(metronome, c.Now()).String())
+	c.Set(tr.Start().Add(time.Second))
+	require.Equal(t, "5:3:000", tr.Position(metronome, c.Now()).String())
+}
+
+func TestPlayersLineUp(t *testing.T) {
+	c := clock.NewFake(origin.Add(8 * time.Second))
+	host, guest := transport.New(), transport.New()
+
+	// The host started playing 3 bars ago, the guest joins from the host's message.
+	host.PlayAt(metronome, 1, origin.Add(2*time.Second))
+	startIn := host.Start().Sub(c.Now())
+	delay := 40 * time.Millisecond
+	c.Advance(delay)
+	guest.PlayAt(metronome, 1, c.Now().Add(-delay+startIn))
+
+	require.Equal(t, "4:1:038", guest.Position(metronome, c.Now()).String())
+	require.Equal(t, host.Position(metronome, c.Now()), guest.Position(metronome, c.Now()))
+}
+
+func TestRetime(t *testing.T) {
+	c := clock.NewFake(origin)
+	tr := transport.New()
+
+	c.Set(tr.Play(metronome, c.Now()))
+	c.Advance(6 * time.Second)
+	require.Equal(t, "4:1:000", tr.Position(metronome, c.Now()).String())
+
+	// Someone sets a new tempo with its downbeat now.
+	faster := tempo.NewClock(240, tempo.CommonTime, c.Now())
+	tr.Retime(metronome, faster, c.Now())
+	require.Equal(t, "4:1:000", tr.Position(faster, c.Now()).String())
+
+	c.Advance(time.Second)
+	require.Equal(t, "5:1:000", tr.Position(faster, c.Now()).String())
+}
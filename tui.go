@@ -1,22 +1,32 @@
 package rmxtui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/hyphengolang/prelude/types/suid"
 	"golang.org/x/term"
 
+	"github.com/rapidmidiex/rmxtui/audio"
+	"github.com/rapidmidiex/rmxtui/auth"
 	"github.com/rapidmidiex/rmxtui/jamui"
 	"github.com/rapidmidiex/rmxtui/keymap"
 	"github.com/rapidmidiex/rmxtui/lobbyui"
+	"github.com/rapidmidiex/rmxtui/loginui"
+	"github.com/rapidmidiex/rmxtui/midi"
+	"github.com/rapidmidiex/rmxtui/profile"
+	"github.com/rapidmidiex/rmxtui/profileui"
 	"github.com/rapidmidiex/rmxtui/rmxerr"
 	"github.com/rapidmidiex/rmxtui/rtt"
 	"github.com/rapidmidiex/rmxtui/styles"
@@ -36,6 +46,24 @@ type (
 
 	appView int
 
+	// Config holds the command line options for running the TUI.
+	Config struct {
+		// RMX server URL, ex: "https://rmx.fly.dev"
+		ServerURL string
+		// Write logs to `debug.log`
+		Debug bool
+		// Show the login prompt before the lobby.
+		Login bool
+		// Where and how the jam's sound is played.
+		Audio audio.Config
+		// How many notes play at once, and which ends when there are too many.
+		Voices midi.VoicesOpts
+		// Engine notes are played with.
+		Synth midi.EngineName
+		// The server delivers direct messages only to their recipient, which /dm needs.
+		PrivateDM bool
+	}
+
 	// Message types
 	mainModel struct {
 		loading      bool
@@ -43,40 +71,94 @@ type (
 		curView      appView
 		lobby        tea.Model
 		jam          tea.Model
+		profileSetup tea.Model
+		login        tea.Model
+		authClient   *auth.Client
+		audio        audio.Backend
+		profile      profile.Profile
+		profilePath  string
 		RESTendpoint string
 		WSendpoint   string
 		rttStats     rtt.Stats
-		log          log.Logger
+		log          *log.Logger
 	}
 )
 
 const (
 	jamView appView = iota
 	lobbyView
+	profileView
+	loginView
 )
 
 var (
 	docStyle = styles.DocStyle
 )
 
-func NewModel(serverHostURL string, debugMode bool) (mainModel, error) {
+func NewModel(cfg Config) (mainModel, error) {
+	serverHostURL := cfg.ServerURL
 	wsHostURL, err := url.Parse(serverHostURL)
 	if err != nil {
 		return mainModel{}, err
 	}
 
 	wsHostURL.Scheme = "ws" + strings.TrimPrefix(wsHostURL.Scheme, "http")
-	jamModel, err := jamui.New()
+
+	profilePath, err := profile.DefaultPath()
+	if err != nil {
+		return mainModel{}, err
+	}
+	// Ask for a display name on first run.
+	curView := lobbyView
+	p, err := profile.Load(profilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		curView = profileView
+	} else if err != nil {
+		return mainModel{}, err
+	}
+
+	tokenPath, err := auth.DefaultStorePath()
+	if err != nil {
+		return mainModel{}, err
+	}
+	// A token others can read isn't used, but shouldn't keep rmxtui from starting either.
+	authClient, err := auth.NewClient(serverHostURL+"/api/v1", auth.NewStore(tokenPath))
+	var authErr error
+	if errors.Is(err, auth.ErrInsecureFile) {
+		authErr, err = err, nil
+	}
 	if err != nil {
 		return mainModel{}, err
 	}
+	if cfg.Login {
+		curView = loginView
+	}
+
+	// Open the sound last, so nothing after it can fail and leave it open.
+	out, err := audio.Open(cfg.Audio)
+	if err != nil {
+		return mainModel{}, err
+	}
+	jamModel, err := jamui.New(out, jamui.Opts{Voices: cfg.Voices, Engine: cfg.Synth, PrivateDM: cfg.PrivateDM})
+	if err != nil {
+		out.Close()
+		return mainModel{}, err
+	}
+
 	return mainModel{
-		curView:      lobbyView,
-		lobby:        lobbyui.New(serverHostURL + "/api/v1"),
+		curView:      curView,
+		lobby:        lobbyui.New(serverHostURL+"/api/v1", authClient.HTTPClient()),
 		jam:          jamModel,
+		profileSetup: profileui.New(),
+		login:        loginui.New(authClient),
+		authClient:   authClient,
+		audio:        out,
+		profile:      p,
+		profilePath:  profilePath,
 		RESTendpoint: serverHostURL + "/api/v1",
 		WSendpoint:   wsHostURL.String() + "/ws",
-		log:          *log.Default(),
+		log:          log.Default(),
+		curError:     authErr,
 	}, nil
 }
 
@@ -84,6 +166,8 @@ func (m mainModel) Init() tea.Cmd {
 	return tea.Batch(
 		m.lobby.Init(),
 		m.jam.Init(),
+		m.profileSetup.Init(),
+		m.login.Init(),
 	)
 }
 
@@ -99,6 +183,13 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case jamui.StatsMsg:
 		m.rttStats = rtt.Stats(msg)
 
+	case tea.WindowSizeMsg:
+		// The jam view sizes its panes from the terminal, even while it is not on screen.
+		if m.curView != jamView {
+			m.jam, cmd = m.jam.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
 		// Was a key press
 	case tea.KeyMsg:
 		switch {
@@ -116,6 +207,27 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 	case jamui.LeaveRoomMsg:
 		m.curView = lobbyView
+
+	case loginui.LoggedInMsg, loginui.SkippedMsg:
+		// Logging in replaced a token file others could read.
+		if _, ok := msg.(loginui.LoggedInMsg); ok && errors.Is(m.curError, auth.ErrInsecureFile) {
+			m.curError = nil
+		}
+		m.curView = lobbyView
+		if m.profile.ID == uuid.Nil {
+			m.curView = profileView
+		}
+		// Fetch the jams again, this time with credentials.
+		cmds = append(cmds, m.lobby.Init())
+
+	case profileui.CreatedMsg:
+		m.profile = msg.Profile
+		m.curView = lobbyView
+		// The lobby missed its initial fetch while the profile prompt was shown.
+		cmds = append(cmds, m.saveProfile(), m.lobby.Init())
+	case jamui.NickChangedMsg:
+		m.profile.DisplayName = msg.DisplayName
+		cmds = append(cmds, m.saveProfile())
 	}
 
 	// Call sub-model Updates
@@ -124,6 +236,10 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.lobby, cmd = m.lobby.Update(msg)
 	case jamView:
 		m.jam, cmd = m.jam.Update(msg)
+	case profileView:
+		m.profileSetup, cmd = m.profileSetup.Update(msg)
+	case loginView:
+		m.login, cmd = m.login.Update(msg)
 	}
 
 	// Run all commands from sub-model Updates
@@ -155,6 +271,20 @@ func (m mainModel) View() string {
 		statusKeyText = "LOADING"
 	}
 
+	if m.authClient.LoggedIn() {
+		status += " · logged in"
+	}
+
+	if jam, ok := m.jam.(interface{ Status() string }); ok && m.curView == jamView {
+		if s := jam.Status(); s != "" {
+			status += " · " + s
+		}
+	}
+
+	if m.curView != profileView && m.profile.DisplayName != "" {
+		status += fmt.Sprintf(" · %s", lipgloss.NewStyle().Foreground(lipgloss.Color(m.profile.Color)).Render(m.profile.DisplayName))
+	}
+
 	if m.curError != nil {
 		status = styles.RenderError(fmt.Sprint(m.curError))
 		statusKeyText = "ERROR"
@@ -166,6 +296,12 @@ func (m mainModel) View() string {
 
 	case lobbyView:
 		doc.WriteString("\n" + m.lobby.View())
+
+	case profileView:
+		doc.WriteString("\n" + m.profileSetup.View())
+
+	case loginView:
+		doc.WriteString("\n" + m.login.View())
 	}
 
 	// Status bar
@@ -191,16 +327,27 @@ func (m mainModel) View() string {
 	return docStyle.Render(doc.String())
 }
 
-func Run(serverHostURL string, debugMode bool) {
-	m, err := NewModel(serverHostURL, debugMode)
+func Run(cfg Config) {
+	m, err := NewModel(cfg)
 	if err != nil {
 		bail(err)
 	}
 
 	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		bail(err)
+	final, err := p.Run()
+	// Finish writing the sound, even if the TUI failed. Quitting doesn't wait for the jam to
+	// leave its room, so it's closed here too.
+	if fm, ok := final.(mainModel); ok {
+		if jam, ok := fm.jam.(interface{ Close() error }); ok {
+			if closeErr := jam.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if closeErr := m.audio.Close(); err == nil {
+		err = closeErr
 	}
+	bail(err)
 }
 
 func bail(err error) {
@@ -213,14 +360,37 @@ func bail(err error) {
 func (m mainModel) jamConnect(jamID string) tea.Cmd {
 	return func() tea.Msg {
 		jURL := m.WSendpoint + "/jam/" + jamID
-		ws, _, err := websocket.DefaultDialer.Dial(jURL, nil)
+		// Present our stable identity so the server can recognize us across sessions.
+		q := url.Values{}
+		q.Set("userId", m.profile.ID.String())
+		q.Set("userName", m.profile.DisplayName)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		header, err := m.authClient.Header(ctx)
+		if err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("jamConnect: %w", err)}
+		}
+		ws, _, err := websocket.DefaultDialer.DialContext(ctx, jURL+"?"+q.Encode(), header)
 		if err != nil {
 			return rmxerr.ErrMsg{Err: fmt.Errorf("jamConnect: %v\n%v", jURL, err)}
 		}
 		return jamui.ConnectedMsg{
-			WS:    ws,
-			JamID: jamID,
+			WS:      ws,
+			JamID:   jamID,
+			Profile: m.profile,
+		}
+	}
+}
+
+// SaveProfile writes the current profile to disk.
+func (m mainModel) saveProfile() tea.Cmd {
+	p, path := m.profile, m.profilePath
+	return func() tea.Msg {
+		if err := p.Save(path); err != nil {
+			return rmxerr.ErrMsg{Err: fmt.Errorf("saveProfile: %w", err)}
 		}
+		return nil
 	}
 }
 
@@ -0,0 +1,119 @@
+package vpiano
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Scale is a set of notes, as semitones above the root.
+	Scale struct {
+		Name      string
+		Intervals []int
+	}
+
+	// Key is a scale played from a root note.
+	Key struct {
+		// Pitch class of the root, 0 for C up to 11 for B.
+		Root  int
+		Scale Scale
+	}
+)
+
+var (
+	Chromatic       = Scale{Name: "chromatic", Intervals: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}}
+	Major           = Scale{Name: "major", Intervals: []int{0, 2, 4, 5, 7, 9, 11}}
+	Minor           = Scale{Name: "minor", Intervals: []int{0, 2, 3, 5, 7, 8, 10}}
+	Dorian          = Scale{Name: "dorian", Intervals: []int{0, 2, 3, 5, 7, 9, 10}}
+	Phrygian        = Scale{Name: "phrygian", Intervals: []int{0, 1, 3, 5, 7, 8, 10}}
+	Lydian          = Scale{Name: "lydian", Intervals: []int{0, 2, 4, 6, 7, 9, 11}}
+	Mixolydian      = Scale{Name: "mixolydian", Intervals: []int{0, 2, 4, 5, 7, 9, 10}}
+	Locrian         = Scale{Name: "locrian", Intervals: []int{0, 1, 3, 5, 6, 8, 10}}
+	Pentatonic      = Scale{Name: "pentatonic", Intervals: []int{0, 2, 4, 7, 9}}
+	MinorPentatonic = Scale{Name: "minor-pentatonic", Intervals: []int{0, 3, 5, 7, 10}}
+	Blues           = Scale{Name: "blues", Intervals: []int{0, 3, 5, 6, 7, 10}}
+
+	// Scales a key can use.
+	Scales = []Scale{Major, Minor, Dorian, Phrygian, Lydian, Mixolydian, Locrian, Pentatonic, MinorPentatonic, Blues}
+)
+
+// Other names scales go by.
+var scaleAliases = map[string]string{
+	"ionian":  "major",
+	"aeolian": "minor",
+	"maj":     "major",
+	"min":     "minor",
+}
+
+// Pitch class names, sharps first so they're used when naming a root.
+var rootNames = map[string]int{
+	"C": 0, "C#": 1, "D": 2, "D#": 3, "E": 4, "F": 5, "F#": 6, "G": 7, "G#": 8, "A": 9, "A#": 10, "B": 11,
+	"Db": 1, "Eb": 3, "Gb": 6, "Ab": 8, "Bb": 10,
+}
+
+// LookupScale finds a scale by name, ex: "dorian".
+func LookupScale(name string) (Scale, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := scaleAliases[name]; ok {
+		name = alias
+	}
+	for _, s := range Scales {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scale{}, false
+}
+
+// ParseKey reads a key like "D dorian", "Bb blues" or "F#", which is F# major.
+func ParseKey(s string) (Key, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Key{}, fmt.Errorf("key %q should be a root and a scale, ex: D dorian", s)
+	}
+	root := strings.ToUpper(fields[0][:1]) + fields[0][1:]
+	pc, ok := rootNames[root]
+	if !ok {
+		return Key{}, fmt.Errorf("unknown root note %q", fields[0])
+	}
+	scale := Major
+	if len(fields) == 2 {
+		scale, ok = LookupScale(fields[1])
+		if !ok {
+			return Key{}, fmt.Errorf("unknown scale %q", fields[1])
+		}
+	}
+	return Key{Root: pc, Scale: scale}, nil
+}
+
+// IsZero reports whether no key is set.
+func (k Key) IsZero() bool {
+	return len(k.Scale.Intervals) == 0
+}
+
+// Contains reports whether a MIDI note is in the key.
+func (k Key) Contains(midi int) bool {
+	if k.IsZero() {
+		return true
+	}
+	pc := ((midi-k.Root)%12 + 12) % 12
+	for _, i := range k.Scale.Intervals {
+		if i == pc {
+			return true
+		}
+	}
+	return false
+}
+
+// String names the key, ex: "D dorian".
+func (k Key) String() string {
+	if k.IsZero() {
+		return "none"
+	}
+	return fmt.Sprintf("%s %s", noteName(k.Root), k.Scale.Name)
+}
+
+// NoteName names the pitch class of a MIDI note, ex: "C#/Db".
+func noteName(midi int) string {
+	return noteNames[(midi%12+12+3)%12].name
+}
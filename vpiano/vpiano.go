@@ -16,11 +16,12 @@ type (
 
 	NoteKeyMap map[string]Note
 
-	octave int
+	// Octave is the octave the virtual piano starts at, named after its C note.
+	Octave int
 )
 
 const (
-	Cneg2 octave = iota - 2
+	Cneg2 Octave = iota - 2
 	Cneg1
 	C0
 	C1
@@ -50,7 +51,16 @@ var noteNames = []struct {
 	{name: "G#/Ab", isAccidental: true}}
 
 // MakeOctaveNotes creates list of piano note, MIDI #, qwerty keyboard bindings given an octave name, for example "C4". The keybindings start a C, using the home row for naturals and q-row for accidentals, in an attempt to map close to actual piano fingerings.
-func MakeOctaveNotes(octave octave) Notes {
+func MakeOctaveNotes(octave Octave) Notes {
+	return MakeScaleNotes(octave, Key{Scale: Chromatic})
+}
+
+// MakeScaleNotes locks the piano to a key. The qwerty keys play the key's scale upwards from its root
+// in the given octave, so every key lands on a note in the scale.
+func MakeScaleNotes(octave Octave, key Key) Notes {
+	if key.IsZero() {
+		key.Scale = Chromatic
+	}
 	// qwerty keys ordered to allow for fingering similar to a real piano.
 	qwertyKeys := []string{"a", "w", "s", "e", "d", "f", "t", "g", "y", "h", "u", "j", "k", "o", "l", "p", ";", "'"}
 	// MIDI number for C0
@@ -58,11 +68,12 @@ func MakeOctaveNotes(octave octave) Notes {
 	// # of available qwerty keys to map to notes.
 	keyboardLen := 18
 	octaveLen := 12
+	scaleLen := len(key.Scale.Intervals)
 	notes := make([]Note, 0)
 
 	for i := 0; i < keyboardLen; i++ {
-		k := noteNames[(i+3)%octaveLen]
-		midi := midiC0 + (octaveLen * int(octave)) + i
+		midi := midiC0 + (octaveLen * (int(octave) + i/scaleLen)) + key.Root + key.Scale.Intervals[i%scaleLen]
+		k := noteNames[((midi+3)%octaveLen+octaveLen)%octaveLen]
 		kb := qwertyKeys[i]
 
 		note := Note{
@@ -34,3 +34,54 @@ func TestMakeOctaveNotes(t *testing.T) {
 		require.Equal(t, want, got[i])
 	}
 }
+
+func TestMakeScaleNotes(t *testing.T) {
+	key, err := vpiano.ParseKey("D dorian")
+	require.NoError(t, err)
+	got := vpiano.MakeScaleNotes(vpiano.C4, key)
+	require.Len(t, got, 18)
+
+	want := []int{62, 64, 65, 67, 69, 71, 72, 74, 76, 77, 79, 81, 83, 84, 86, 88, 89, 91}
+	for i, n := range got {
+		require.Equal(t, want[i], n.MIDI, "key %s", n.KeyBinding)
+		require.True(t, key.Contains(n.MIDI))
+	}
+	require.Equal(t, vpiano.Note{MIDI: 62, KeyBinding: "a", Name: "D"}, got[0])
+
+	// Five notes to the octave.
+	key, err = vpiano.ParseKey("Bb pentatonic")
+	require.NoError(t, err)
+	got = vpiano.MakeScaleNotes(vpiano.C3, key)
+	require.Equal(t, vpiano.Note{MIDI: 58, KeyBinding: "a", Name: "A#/Bb", IsAccidental: true}, got[0])
+	require.Equal(t, 70, got[5].MIDI)
+
+	// No key is the chromatic piano.
+	require.Equal(t, vpiano.MakeOctaveNotes(vpiano.C4), vpiano.MakeScaleNotes(vpiano.C4, vpiano.Key{}))
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "C", want: "C major"},
+		{in: "a minor", want: "A minor"},
+		{in: "F# Mixolydian", want: "F#/Gb mixolydian"},
+		{in: "Eb aeolian", want: "D#/Eb minor"},
+		{in: "e blues", want: "E blues"},
+	}
+	for _, tt := range tests {
+		key, err := vpiano.ParseKey(tt.in)
+		require.NoError(t, err, tt.in)
+		require.Equal(t, tt.want, key.String())
+	}
+
+	for _, bad := range []string{"", "H major", "C bebop", "C major please"} {
+		_, err := vpiano.ParseKey(bad)
+		require.Error(t, err, bad)
+	}
+
+	key, _ := vpiano.ParseKey("G major")
+	require.True(t, key.Contains(66))
+	require.False(t, key.Contains(65))
+}
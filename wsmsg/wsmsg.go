@@ -3,18 +3,20 @@ package wsmsg
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type (
-	MsgType   int
-	NoteState int
+	MsgType         int
+	NoteState       int
+	TransportAction int
 
 	Envelope struct {
 		// Message identifier
 		ID uuid.UUID `json:"id"`
-		// TextMsg | MIDIMsg | ConnectMsg
+		// TextMsg | MIDIMsg | ConnectMsg | NickMsg | JoinMsg | LeaveMsg | RosterMsg | DirectMsg | TempoMsg | TransportMsg | KeyMsg
 		Typ MsgType `json:"type"`
 		// RMX client identifier
 		UserID uuid.UUID `json:"userId"`
@@ -25,6 +27,26 @@ type (
 	TextMsg struct {
 		DisplayName string `json:"displayName"`
 		Body        string `json:"body"`
+		// Sender's display color, ex: "#7D56F4".
+		Color string `json:"color,omitempty"`
+		// Time the message was sent, according to the sender's clock.
+		SentAt time.Time `json:"sentAt,omitempty"`
+		// Sent with /me, ex: "* Jeff dances".
+		Action bool `json:"action,omitempty"`
+	}
+
+	// DirectMsg is a chat message to a single user. The server must deliver it only to To and
+	// back to the sender, never to the rest of the room. Clients only send it when told the
+	// server does.
+	DirectMsg struct {
+		// Recipient's user ID.
+		To uuid.UUID `json:"to"`
+		// Recipient's display name when the message was sent.
+		ToName      string    `json:"toName"`
+		DisplayName string    `json:"displayName"`
+		Body        string    `json:"body"`
+		Color       string    `json:"color,omitempty"`
+		SentAt      time.Time `json:"sentAt,omitempty"`
 	}
 
 	MIDIMsg struct {
@@ -33,18 +55,100 @@ type (
 		Number int `json:"number"`
 		// MIDI Velocity (0-127)
 		Velocity int `json:"velocity"`
+		// General MIDI program (instrument) to play the note with (0-127).
+		Program int `json:"program,omitempty"`
+		// Built-in synth preset to play the note with instead of the program, ex: "pluck".
+		Preset string `json:"preset,omitempty"`
+		// MIDI channel (0-15). Channel 9 plays General MIDI percussion.
+		Channel int `json:"channel,omitempty"`
 	}
 
 	ConnectMsg struct {
 		UserID   uuid.UUID `json:"userId"`
 		UserName string    `json:"userName"`
 	}
+
+	// NickMsg announces that the sending user changed their display name.
+	NickMsg struct {
+		OldName     string `json:"oldName"`
+		DisplayName string `json:"displayName"`
+	}
+
+	// UserInfo describes a player in a Jam Session.
+	UserInfo struct {
+		UserID      uuid.UUID `json:"userId"`
+		DisplayName string    `json:"displayName"`
+		Color       string    `json:"color,omitempty"`
+		// General MIDI program number of the user's instrument (0-127).
+		Instrument int `json:"instrument"`
+		// Built-in synth preset the user plays instead of their instrument, if any.
+		Preset string `json:"preset,omitempty"`
+		// User's latest average roundtrip time to the server, in milliseconds.
+		LatencyMS int64 `json:"latencyMs"`
+	}
+
+	// JoinMsg announces a user in the Jam Session.
+	// It is re-sent when the user's details change, so receivers should treat it as an upsert.
+	JoinMsg struct {
+		User UserInfo `json:"user"`
+		// Set on every JoinMsg but the one sent on arrival: replies to a newcomer, and changes to
+		// the user's details. Receivers only update their roster, without greeting the user again.
+		Update bool `json:"update,omitempty"`
+	}
+
+	// LeaveMsg announces that a user left the Jam Session.
+	LeaveMsg struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	// TempoMsg sets the Jam Session's tempo and time signature, and lines up everyone's bars with the sender's.
+	TempoMsg struct {
+		BPM         int `json:"bpm"`
+		BeatsPerBar int `json:"beatsPerBar"`
+		// Note value of a beat, ex: 4 for quarter notes.
+		BeatUnit int `json:"beatUnit"`
+		// How far into the current bar the sender was when the message was sent, in milliseconds.
+		BarElapsedMS float64 `json:"barElapsedMs"`
+	}
+
+	// TransportMsg starts, stops or moves playback for the whole Jam Session.
+	TransportMsg struct {
+		Action TransportAction `json:"action"`
+		// Song bar playback starts from, or stopped in.
+		Bar int `json:"bar"`
+		// Time from sending the message until playback starts on a downbeat, in milliseconds.
+		// Negative if playback already started.
+		StartInMS float64 `json:"startInMs,omitempty"`
+		// Whether playback continues after a TRANSPORT_LOCATE.
+		Playing bool `json:"playing,omitempty"`
+	}
+
+	// KeyMsg sets the Jam Session's musical key, so players can lock their pianos to it.
+	KeyMsg struct {
+		// Pitch class of the root, 0 for C up to 11 for B.
+		Root int `json:"root"`
+		// Scale name, ex: "dorian". Empty when the jam has no key.
+		Scale string `json:"scale,omitempty"`
+	}
+
+	// RosterMsg is a snapshot of every user currently in the Jam Session.
+	RosterMsg struct {
+		Users []UserInfo `json:"users"`
+	}
 )
 
 const (
 	TEXT MsgType = iota
 	MIDI
 	CONNECT
+	NICK
+	JOIN
+	LEAVE
+	ROSTER
+	DIRECT
+	TEMPO
+	TRANSPORT
+	KEY
 )
 
 const (
@@ -52,6 +156,12 @@ const (
 	NOTE_ON
 )
 
+const (
+	TRANSPORT_PLAY TransportAction = iota
+	TRANSPORT_STOP
+	TRANSPORT_LOCATE
+)
+
 func (e *Envelope) SetPayload(payload any) error {
 	p, err := json.Marshal(payload)
 	if err != nil {